@@ -0,0 +1,54 @@
+package lrserver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// AdvertisedHost gets the host clients are told to connect to,
+// or an empty string if not set
+func (s *Server) AdvertisedHost() string {
+	return s.settings().advertisedHost
+}
+
+// SetAdvertisedHost sets the host clients are told to connect to, when
+// browsers can't reach the server at the host it listens on, e.g. when it
+// listens on 0.0.0.0 inside a container. It keeps the listening port and
+// scheme; SetPublicURL changes those too, and takes precedence. An empty
+// host reverts to the default: the listening host, or when listening on
+// every interface, whichever host the page reached the server at.
+func (s *Server) SetAdvertisedHost(host string) error {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	if strings.ContainsAny(host, "/@ ") || (strings.Contains(host, ":") && net.ParseIP(host) == nil) {
+		return fmt.Errorf("lrserver: invalid advertised host %q", host)
+	}
+	s.update(func(cfg *settings) { cfg.advertisedHost = host })
+	return nil
+}
+
+// clientHost gets the host that clients of a page served in response to
+// req should connect to. req may be nil.
+func (s *Server) clientHost(req *http.Request) string {
+	if host := s.settings().advertisedHost; host != "" {
+		return host
+	}
+	if !unspecifiedHost(s.host) || req == nil {
+		return s.host
+	}
+	host, _ := s.requestHostPort(req, false)
+	return host
+}
+
+// unspecifiedHost reports whether listening on host means listening on
+// every interface
+func unspecifiedHost(host string) bool {
+	if host == "" {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsUnspecified()
+}
@@ -0,0 +1,88 @@
+package lrserver_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAdvertisedHost(t *testing.T) {
+	Convey("Given a server listening on every interface", t, func() {
+		srv, err := lrserver.NewServer(lrserver.WithHost("0.0.0.0"), lrserver.WithPort(0), lrserver.WithLogger(nil))
+		So(err, ShouldBeNil)
+		go srv.ListenAndServe()
+		defer srv.Close()
+		waitFor(func() bool { return srv.Port() != 0 })
+		scriptURL := fmt.Sprintf("http%s:%d/livereload.js", localhost, srv.Port())
+
+		Convey("the script should target the host it was loaded from", func() {
+			So(getBody(t, scriptURL), ShouldContainSubstring, `this.host = "127.0.0.1";`)
+		})
+
+		Convey("the script URL should default to localhost", func() {
+			So(srv.ScriptURL(), ShouldEqual, fmt.Sprintf("http://localhost:%d/livereload.js", srv.Port()))
+		})
+
+		Convey("with an advertised host", func() {
+			So(srv.SetAdvertisedHost("dev.example"), ShouldBeNil)
+			So(srv.AdvertisedHost(), ShouldEqual, "dev.example")
+
+			Convey("the script and its URL should target it", func() {
+				So(getBody(t, scriptURL), ShouldContainSubstring, `this.host = "dev.example";`)
+				So(srv.ScriptURL(), ShouldEqual, fmt.Sprintf("http://dev.example:%d/livereload.js", srv.Port()))
+			})
+		})
+
+		Convey("invalid advertised hosts should be refused", func() {
+			So(srv.SetAdvertisedHost("dev.example:8080"), ShouldNotBeNil)
+			So(srv.SetAdvertisedHost("http://dev.example"), ShouldNotBeNil)
+			So(srv.SetAdvertisedHost("[::1]"), ShouldBeNil)
+			So(srv.AdvertisedHost(), ShouldEqual, "::1")
+		})
+	})
+
+	Convey("Given a handler behind a trusted proxy", t, func() {
+		srv, err := lrserver.NewServer(lrserver.WithLogger(nil))
+		So(err, ShouldBeNil)
+		So(srv.TrustProxy("192.0.2.1"), ShouldBeNil)
+		h := srv.Handler()
+		forwardedHost := "dev.example:8443"
+
+		get := func(remoteAddr string) string {
+			req := httptest.NewRequest("GET", "http://lr.internal:35729/livereload.js", nil)
+			req.RemoteAddr = remoteAddr
+			req.Header.Set("X-Forwarded-Host", forwardedHost)
+			req.Header.Set("X-Forwarded-Proto", "https")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			So(rec.Code, ShouldEqual, http.StatusOK)
+			return rec.Body.String()
+		}
+
+		Convey("the script should target the forwarded host and scheme", func() {
+			body := get("192.0.2.1:4000")
+			So(body, ShouldContainSubstring, `this.host = "dev.example";`)
+			So(body, ShouldContainSubstring, `this.port = 8443;`)
+			So(body, ShouldContainSubstring, `this.https = true ||`)
+		})
+
+		Convey("forwarded hosts that aren't host names should be ignored", func() {
+			for _, host := range []string{`evil";alert(1);"`, "</script><script>alert(1)</script>", "dev.example:http"} {
+				forwardedHost = host
+				body := get("192.0.2.1:4000")
+				So(body, ShouldContainSubstring, `this.host = "lr.internal";`)
+				So(body, ShouldNotContainSubstring, "alert(1)")
+			}
+		})
+
+		Convey("forwarding headers from anyone else should be ignored", func() {
+			body := get("198.51.100.7:4000")
+			So(body, ShouldContainSubstring, `this.host = "lr.internal";`)
+			So(body, ShouldContainSubstring, `this.port = 35729;`)
+		})
+	})
+}
@@ -0,0 +1,68 @@
+package lrserver
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// endpoints tracks the muxes a server is mounted on and the paths it
+// serves, so aliases can be added to all of them
+type endpoints struct {
+	mu    sync.Mutex
+	muxes []*http.ServeMux
+	paths map[string]http.HandlerFunc
+}
+
+// AliasWebSocket serves the websocket endpoint at path as well as at
+// /livereload, e.g. /ws/livereload for pages written for other LiveReload
+// implementations. Clients connecting through any alias share the same
+// broadcasts.
+func (s *Server) AliasWebSocket(path string) error {
+	return s.alias(path, webSocketHandler(s))
+}
+
+// AliasScript serves the client JavaScript at path as well as at
+// /livereload.js
+func (s *Server) AliasScript(path string) error {
+	return s.alias(path, jsHandler(s))
+}
+
+// alias registers h at path on every mux the server is mounted on,
+// now and in future
+func (s *Server) alias(path string, h http.HandlerFunc) error {
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("lrserver: alias %q must start with /", path)
+	}
+
+	s.endpoints.mu.Lock()
+	defer s.endpoints.mu.Unlock()
+	if _, ok := s.endpoints.paths[path]; ok {
+		return fmt.Errorf("lrserver: %q is already served", path)
+	}
+	s.endpoints.paths[path] = h
+	for _, mux := range s.endpoints.muxes {
+		mux.HandleFunc(path, h)
+	}
+	return nil
+}
+
+// mount adds mux to the muxes served on, registering every
+// endpoint and alias added so far
+func (e *endpoints) mount(mux *http.ServeMux) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for path, h := range e.paths {
+		mux.HandleFunc(path, h)
+	}
+	e.muxes = append(e.muxes, mux)
+}
+
+// lookup gets the endpoint or alias served at path
+func (e *endpoints) lookup(path string) (http.HandlerFunc, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	h, ok := e.paths[path]
+	return h, ok
+}
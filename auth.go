@@ -0,0 +1,77 @@
+package lrserver
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AuthToken gets the token clients must present to connect, if any
+func (s *Server) AuthToken() string {
+	return s.settings().authToken
+}
+
+// SetAuthToken requires websocket and event stream clients to present
+// token, so others on a shared machine or network can't connect. There's
+// no token by default, and an empty one lifts the requirement. Tokens may
+// only contain letters, digits and -._~.
+//
+// Clients pass it in the token query parameter, or as a bearer token.
+// Pages load the script as livereload.js?token=..., as ScriptURL, ScriptTag
+// and Snippet do, and the served script then embeds the token; requests
+// for the script without it get one that can't connect. Browser
+// extensions and protocol 6 clients can't present a token.
+func (s *Server) SetAuthToken(token string) error {
+	for _, r := range token {
+		if !strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-._~", r) {
+			return errors.New("lrserver: auth tokens may only contain letters, digits and -._~")
+		}
+	}
+	s.update(func(cfg *settings) { cfg.authToken = token })
+	return nil
+}
+
+// GenerateAuthToken sets a random auth token, as SetAuthToken, and
+// returns it
+func (s *Server) GenerateAuthToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	return token, s.SetAuthToken(token)
+}
+
+// authorized reports whether req presents the auth token, if one is set
+func (s *Server) authorized(req *http.Request) bool {
+	token := s.AuthToken()
+	if token == "" {
+		return true
+	}
+	if given := req.URL.Query().Get("token"); given != "" {
+		return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+	}
+	return bearerTokenMatches(req, token)
+}
+
+// scriptToken gets the auth token to embed in the script served for req,
+// which is only given to requests that present it
+func (s *Server) scriptToken(req *http.Request) string {
+	if req == nil || s.AuthToken() == "" || !s.authorized(req) {
+		return ""
+	}
+	return s.AuthToken()
+}
+
+// tokenQuery gets the query string passing the auth token to the script,
+// starting with sep, or an empty string if there's no token
+func (s *Server) tokenQuery(sep string) string {
+	if token := s.AuthToken(); token != "" {
+		return sep + "token=" + url.QueryEscape(token)
+	}
+	return ""
+}
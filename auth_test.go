@@ -0,0 +1,74 @@
+package lrserver_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAuthToken(t *testing.T) {
+	Convey("Given a running server requiring a token", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		So(srv.SetAuthToken("s3cret"), ShouldBeNil)
+		So(srv.AuthToken(), ShouldEqual, "s3cret")
+		base := fmt.Sprintf("%s:%d/livereload", localhost, srv.Port())
+
+		Convey("websockets without it should be refused", func() {
+			_, resp, err := websocket.DefaultDialer.Dial("ws"+base, nil)
+			So(err, ShouldNotBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusUnauthorized)
+
+			_, resp, err = websocket.DefaultDialer.Dial("ws"+base+"?token=wrong", nil)
+			So(err, ShouldNotBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusUnauthorized)
+		})
+
+		Convey("websockets with it should connect", func() {
+			conn, _, err := websocket.DefaultDialer.Dial("ws"+base+"?token=s3cret", nil)
+			So(err, ShouldBeNil)
+			conn.Close()
+
+			conn, _, err = websocket.DefaultDialer.Dial("ws"+base, http.Header{"Authorization": {"Bearer s3cret"}})
+			So(err, ShouldBeNil)
+			conn.Close()
+		})
+
+		Convey("event streams without it should be refused", func() {
+			resp, err := http.Get("http" + base + "/sse")
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusUnauthorized)
+		})
+
+		Convey("the script should only embed it for requests presenting it", func() {
+			script := fmt.Sprintf("http%s:%d/livereload.js", localhost, srv.Port())
+			So(getBody(t, script+"?token=s3cret"), ShouldContainSubstring, `this.token = "s3cret";`)
+			So(getBody(t, script), ShouldContainSubstring, `this.token = "";`)
+		})
+
+		Convey("the endpoint's info page should only show it to requests presenting it", func() {
+			So(getBody(t, "http"+base), ShouldNotContainSubstring, "s3cret")
+			So(getBody(t, "http"+base+"?token=s3cret"), ShouldContainSubstring, "/livereload.js?token=s3cret")
+		})
+
+		Convey("script URLs should carry it", func() {
+			So(srv.ScriptURL(), ShouldEndWith, "/livereload.js?token=s3cret")
+		})
+	})
+
+	Convey("Tokens that can't be passed as they are should be rejected", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		So(srv.SetAuthToken(`a"b`), ShouldNotBeNil)
+		So(srv.SetAuthToken("a b"), ShouldNotBeNil)
+
+		token, err := srv.GenerateAuthToken()
+		So(err, ShouldBeNil)
+		So(token, ShouldHaveLength, 32)
+		So(srv.AuthToken(), ShouldEqual, token)
+	})
+}
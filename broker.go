@@ -0,0 +1,145 @@
+package lrserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+)
+
+// Broker carries broadcasts between servers, e.g. one dev server per
+// microfrontend, so reloading on any of them reloads the clients of all.
+// Implementations wrap a message bus such as Redis pub/sub or NATS, as in
+// the example; see MemoryBroker for servers in the same process.
+type Broker interface {
+	// Publish sends msg to every subscriber, the publisher included
+	Publish(msg []byte) error
+
+	// Subscribe calls f with each message published from then on,
+	// until unsubscribe is called
+	Subscribe(f func(msg []byte)) (unsubscribe func(), err error)
+}
+
+// brokerMessage is a broadcast as published to a broker
+type brokerMessage struct {
+	Origin  string          `json:"origin"`
+	Data    json.RawMessage `json:"data"`
+	Command string          `json:"command,omitempty"`
+	Path    string          `json:"path,omitempty"`
+	Message string          `json:"message,omitempty"`
+}
+
+// brokerLink is the server's subscription to a broker
+type brokerLink struct {
+	broker      Broker
+	id          string
+	unsubscribe func()
+}
+
+// SetBroker shares the server's broadcasts with the other servers on b:
+// reloads, alerts and commands sent to every client are published, and
+// those published by the others are sent to this server's clients. Paths
+// are sent as the publishing server maps them. Nil, the default, keeps
+// broadcasts to the server's own clients.
+func (s *Server) SetBroker(b Broker) error {
+	var link *brokerLink
+	if b != nil {
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			return err
+		}
+		link = &brokerLink{broker: b, id: hex.EncodeToString(id)}
+		unsubscribe, err := b.Subscribe(func(msg []byte) {
+			s.receiveBrokered(link.id, msg)
+		})
+		if err != nil {
+			return err
+		}
+		link.unsubscribe = unsubscribe
+	}
+
+	var old *brokerLink
+	s.update(func(cfg *settings) {
+		old, cfg.broker = cfg.broker, link
+	})
+	if old != nil {
+		old.unsubscribe()
+	}
+	return nil
+}
+
+// publish shares a broadcast with the servers on the broker, if any
+func (s *Server) publish(data []byte, delivered Event) {
+	link := s.settings().broker
+	if link == nil {
+		return
+	}
+	msg, err := json.Marshal(brokerMessage{
+		Origin:  link.id,
+		Data:    data,
+		Command: delivered.Command,
+		Path:    delivered.Path,
+		Message: delivered.Message,
+	})
+	if err == nil {
+		err = link.broker.Publish(msg)
+	}
+	if err != nil {
+		s.logError("broker:", err)
+	}
+}
+
+// receiveBrokered sends a broadcast published by another server to every
+// client of this one, skipping the server's own with id
+func (s *Server) receiveBrokered(id string, msg []byte) {
+	bm := new(brokerMessage)
+	if err := json.Unmarshal(msg, bm); err != nil {
+		s.logError("broker:", err)
+		return
+	}
+	if bm.Origin == id || len(bm.Data) == 0 {
+		return
+	}
+	s.sendAll(nil, bm.Data, Event{Command: bm.Command, Path: bm.Path, Message: bm.Message})
+}
+
+// MemoryBroker is a Broker between servers in the same process
+type MemoryBroker struct {
+	mu   sync.RWMutex
+	next int
+	subs map[int]func([]byte)
+}
+
+// NewMemoryBroker creates a broker between servers in the same process
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{subs: make(map[int]func([]byte))}
+}
+
+// Publish calls every subscriber with msg
+func (b *MemoryBroker) Publish(msg []byte) error {
+	b.mu.RLock()
+	subs := make([]func([]byte), 0, len(b.subs))
+	for _, f := range b.subs {
+		subs = append(subs, f)
+	}
+	b.mu.RUnlock()
+
+	for _, f := range subs {
+		f(msg)
+	}
+	return nil
+}
+
+// Subscribe calls f with each message published until unsubscribed
+func (b *MemoryBroker) Subscribe(f func(msg []byte)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.next
+	b.next++
+	b.subs[id] = f
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, id)
+	}, nil
+}
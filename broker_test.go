@@ -0,0 +1,56 @@
+package lrserver_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBroker(t *testing.T) {
+	Convey("Given two servers sharing a broker", t, func() {
+		broker := lrserver.NewMemoryBroker()
+		a, b := startServer(t), startServer(t)
+		defer a.Close()
+		defer b.Close()
+		So(a.SetBroker(broker), ShouldBeNil)
+		So(b.SetBroker(broker), ShouldBeNil)
+		connB := connect(t, b)
+		defer connB.Close()
+
+		Convey("reloads on one should reach the clients of the other", func() {
+			a.Reload("css/main.css")
+			sr, err := readReload(connB)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "css/main.css")
+		})
+
+		Convey("alerts on one should reach the clients of the other", func() {
+			a.Alert("build failed")
+			sa := new(serverAlert)
+			connB.SetReadDeadline(time.Now().Add(time.Second))
+			So(connB.ReadJSON(sa), ShouldBeNil)
+			So(sa.Message, ShouldEqual, "build failed")
+		})
+
+		Convey("a server's own clients should get its broadcasts once", func() {
+			b.Reload("index.html")
+			b.Reload("after.html")
+			for _, path := range []string{"index.html", "after.html"} {
+				sr, err := readReload(connB)
+				So(err, ShouldBeNil)
+				So(sr.Path, ShouldEqual, path)
+			}
+		})
+
+		Convey("a server leaving the broker should stop receiving", func() {
+			So(b.SetBroker(nil), ShouldBeNil)
+			a.Reload("index.html")
+			b.Reload("own.html")
+			sr, err := readReload(connB)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "own.html")
+		})
+	})
+}
@@ -0,0 +1,83 @@
+package lrserver
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// openURL launches the default browser at a URL. Tests swap it out.
+var openURL = func(u string) error {
+	cmd := browserCommand(runtime.GOOS, u)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// browserCommand gets the command opening u in the default browser on
+// the platform
+func browserCommand(goos, u string) *exec.Cmd {
+	switch goos {
+	case "darwin":
+		return exec.Command("open", u)
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+	}
+	return exec.Command("xdg-open", u)
+}
+
+// OpenBrowser gets the URL opened in the browser once the server starts
+// serving
+func (s *Server) OpenBrowser() string {
+	return s.settings().openBrowser
+}
+
+// SetOpenBrowser opens the http(s) URL of the app being developed in the
+// default browser once ListenAndServe or Serve has bound its listener,
+// using open on macOS, rundll32 on Windows and xdg-open elsewhere. It's
+// skipped in CI, when the CI environment variable is set to anything but
+// false, or when LRSERVER_NO_BROWSER is set. An empty URL opens nothing,
+// as by default.
+func (s *Server) SetOpenBrowser(rawURL string) error {
+	if rawURL != "" {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return err
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("lrserver: invalid browser URL %q", rawURL)
+		}
+	}
+	s.update(func(cfg *settings) { cfg.openBrowser = rawURL })
+	return nil
+}
+
+// openBrowser opens the browser, if that's configured and allowed
+func (s *Server) openBrowser() {
+	u := s.settings().openBrowser
+	if u == "" {
+		return
+	}
+	if browserDisabled() {
+		s.logStatus("not opening " + u + " in CI")
+		return
+	}
+	s.logStatus("opening " + u)
+	if err := openURL(u); err != nil {
+		s.logError("opening browser:", err)
+	}
+}
+
+// browserDisabled reports whether the environment asks for no browser
+func browserDisabled() bool {
+	if _, ok := os.LookupEnv("LRSERVER_NO_BROWSER"); ok {
+		return true
+	}
+	ci := strings.ToLower(os.Getenv("CI"))
+	return ci != "" && ci != "false" && ci != "0"
+}
@@ -0,0 +1,63 @@
+package lrserver_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOpenBrowser(t *testing.T) {
+	opened := make(chan string, 1)
+	defer lrserver.SetOpenURL(func(u string) error {
+		opened <- u
+		return nil
+	})()
+
+	Convey("Given a server set to open the browser", t, func() {
+		t.Setenv("CI", "")
+		srv, err := lrserver.NewServer(
+			lrserver.WithHost("127.0.0.1"),
+			lrserver.WithPort(0),
+			lrserver.WithLogger(nil),
+			lrserver.WithOpenBrowser("http://localhost:3000/"),
+		)
+		So(err, ShouldBeNil)
+		So(srv.OpenBrowser(), ShouldEqual, "http://localhost:3000/")
+
+		Convey("serving should open it", func() {
+			go srv.ListenAndServe()
+			defer srv.Close()
+			select {
+			case u := <-opened:
+				So(u, ShouldEqual, "http://localhost:3000/")
+			case <-time.After(time.Second):
+				t.Fatal("browser not opened")
+			}
+		})
+
+		Convey("serving in CI should not", func() {
+			t.Setenv("CI", "true")
+			go srv.ListenAndServe()
+			time.Sleep(10 * time.Millisecond)
+			defer srv.Close()
+			So(srv.Status().Listening, ShouldBeTrue)
+			So(opened, ShouldBeEmpty)
+		})
+	})
+
+	Convey("Only http(s) URLs should be opened", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		So(srv.SetOpenBrowser("file:///etc/passwd"), ShouldNotBeNil)
+		So(srv.SetOpenBrowser("localhost:3000"), ShouldNotBeNil)
+		So(srv.SetOpenBrowser(""), ShouldBeNil)
+	})
+
+	Convey("The browser should be opened with the platform's command", t, func() {
+		So(lrserver.BrowserCommand("darwin", "http://a/"), ShouldResemble, []string{"open", "http://a/"})
+		So(lrserver.BrowserCommand("windows", "http://a/"), ShouldResemble, []string{"rundll32", "url.dll,FileProtocolHandler", "http://a/"})
+		So(lrserver.BrowserCommand("linux", "http://a/"), ShouldResemble, []string{"xdg-open", "http://a/"})
+	})
+}
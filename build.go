@@ -0,0 +1,32 @@
+package lrserver
+
+// BuildHook rebuilds a site from a set of changed files before they're
+// reloaded, e.g. by running go generate or a bundler
+type BuildHook func(changed []string) error
+
+// SetBuildHook sets a hook run with each set of changed files before
+// they're reloaded: the files of a debounced burst, a ReloadAll or a
+// Resume together, and any other reload's file alone. If it fails, the
+// error is sent as an alert instead of reloading. It runs before any
+// matching commands, and can be set to nil.
+func (s *Server) SetBuildHook(h BuildHook) {
+	s.update(func(cfg *settings) { cfg.buildHook = h })
+}
+
+// runBuildHook runs the build hook with files, returning false if it
+// failed, after alerting the failure
+func (s *Server) runBuildHook(files []string) bool {
+	h := s.settings().buildHook
+	if h == nil || len(files) == 0 {
+		return true
+	}
+	start := s.now()
+	err := h(files)
+	s.timing("build", start)
+	if err != nil {
+		s.logError("build failed:", err)
+		s.Alert(err.Error())
+		return false
+	}
+	return true
+}
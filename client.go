@@ -0,0 +1,83 @@
+package lrserver
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// ClientInfo describes the client behind a connection, as reported in
+// its hello and info messages
+type ClientInfo struct {
+	// Protocols lists the protocols the client said hello with
+	Protocols []string
+
+	// Version is the version of the client's livereload.js
+	Version string
+
+	// Extension and ExtensionVersion identify the browser extension
+	// the client runs in, if any
+	Extension        string
+	ExtensionVersion string
+
+	// SnippetVersion is the version of the snippet that loaded the
+	// client, if it reported one
+	SnippetVersion string
+
+	// Plugins maps the identifiers of the client's plugins to their
+	// versions, once the client has reported them
+	Plugins map[string]string
+}
+
+// HasPlugin reports whether the client has the plugin with id
+func (ci ClientInfo) HasPlugin(id string) bool {
+	_, ok := ci.Plugins[id]
+	return ok
+}
+
+// clientInfoFromHello describes the client saying hello
+func clientInfoFromHello(hello *clientMessage) ClientInfo {
+	return ClientInfo{
+		Protocols:        append([]string(nil), hello.Protocols...),
+		Version:          hello.Ver,
+		Extension:        hello.Ext,
+		ExtensionVersion: versionString(hello.ExtVer),
+		SnippetVersion:   versionString(hello.SnipVer),
+	}
+}
+
+// clientInfo gets what the client has reported about itself
+func (c *conn) clientInfo() ClientInfo {
+	ci, _ := c.client.Load().(ClientInfo)
+	return ci
+}
+
+// updatePlugins records the plugins reported in an info message.
+// Plugins that aren't described by an object are skipped.
+func (c *conn) updatePlugins(raw json.RawMessage) {
+	var plugins map[string]json.RawMessage
+	if json.Unmarshal(raw, &plugins) != nil {
+		return
+	}
+	ci := c.clientInfo()
+	ci.Plugins = make(map[string]string, len(plugins))
+	for id, data := range plugins {
+		var plugin struct {
+			Version interface{} `json:"version"`
+		}
+		if json.Unmarshal(data, &plugin) == nil {
+			ci.Plugins[id] = versionString(plugin.Version)
+		}
+	}
+	c.client.Store(ci)
+}
+
+// versionString formats a version sent as either a string or a number
+func versionString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
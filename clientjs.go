@@ -0,0 +1,124 @@
+package lrserver
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// clientBuild is an embedded livereload.js release, as a classic
+// script and an ES module, both templates for renderScript
+type clientBuild struct {
+	script, module string
+}
+
+// clientBuilds are the embedded livereload.js releases by version
+var clientBuilds = map[string]clientBuild{
+	clientVersion: {js, jsModule},
+}
+
+// ClientVersions lists the versions of the embedded livereload.js
+// builds, any of which can be served with SetClientVersion
+func ClientVersions() []string {
+	versions := make([]string, 0, len(clientBuilds))
+	for v := range clientBuilds {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// ClientVersion gets the version of the embedded livereload.js served
+// when there's no custom client script
+func (s *Server) ClientVersion() string {
+	return s.settings().clientVersion
+}
+
+// SetClientVersion serves the embedded livereload.js build of version,
+// which must be one of ClientVersions. Pages reporting another version
+// are warned their copy is stale, as with the default build.
+func (s *Server) SetClientVersion(version string) error {
+	if _, ok := clientBuilds[version]; !ok {
+		return errors.New("no embedded livereload.js " + version + ", have " + strings.Join(ClientVersions(), ", "))
+	}
+	s.update(func(cfg *settings) { cfg.clientVersion = version })
+	return nil
+}
+
+// ClientJS gets the custom client script set with SetClientJS,
+// or nil if the bundled livereload.js is served
+func (s *Server) ClientJS() []byte {
+	script := s.settings().clientJS
+	if script == "" {
+		return nil
+	}
+	return []byte(script)
+}
+
+// SetClientJS serves script at /livereload.js and /livereload.mjs in
+// place of the bundled client, for instance a newer or customized
+// livereload-js build. It's preceded by the window.LiveReloadOptions
+// that upstream builds read, pointing it at the server's host, port,
+// prefix and token as the bundled client is. Pages running it aren't
+// warned about a version mismatch. Empty or nil restores the bundled
+// client.
+func (s *Server) SetClientJS(script []byte) {
+	s.update(func(cfg *settings) { cfg.clientJS = string(script) })
+}
+
+// SetClientJSFile serves the script in the file at path, as SetClientJS.
+// The file is read once, so call it again after rebuilding the script.
+func (s *Server) SetClientJSFile(path string) error {
+	script, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	s.SetClientJS(script)
+	return nil
+}
+
+// clientScript renders the client script served to req: the custom
+// script if one is set, otherwise the selected embedded build
+func (s *Server) clientScript(req *http.Request, module bool) string {
+	cfg := s.settings()
+	if cfg.clientJS != "" {
+		return s.renderCustomScript(cfg.clientJS, req)
+	}
+	build := clientBuilds[cfg.clientVersion]
+	if module {
+		return s.renderScript(build.module, req)
+	}
+	return s.renderScript(build.script, req)
+}
+
+// customScriptOptions are the livereload-js options set for custom
+// client scripts
+type customScriptOptions struct {
+	HTTPS bool   `json:"https"`
+	Host  string `json:"host"`
+	Port  uint16 `json:"port"`
+	Path  string `json:"path"`
+}
+
+// renderCustomScript precedes a custom client script with the options
+// upstream livereload-js builds read from window.LiveReloadOptions. Their
+// path is the websocket's, without the leading slash, so it carries the
+// prefix and the token.
+func (s *Server) renderCustomScript(script string, req *http.Request) string {
+	secure, host, port := s.scriptTarget(req)
+	path := strings.TrimPrefix(s.prefix+"/livereload", "/")
+	if token := s.scriptToken(req); token != "" {
+		path += "?token=" + token
+	}
+
+	// JSON escapes < and >, so the options can't end a script element
+	opts, err := json.Marshal(customScriptOptions{secure, host, port, path})
+	if err != nil {
+		s.logError(err)
+		return script
+	}
+	return "window.LiveReloadOptions = " + string(opts) + ";\n" + script
+}
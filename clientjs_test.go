@@ -0,0 +1,92 @@
+package lrserver_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClientJS(t *testing.T) {
+	Convey("Given a running server", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		scriptURL := fmt.Sprintf("http%s:%d/livereload.js", localhost, srv.Port())
+		moduleURL := fmt.Sprintf("http%s:%d/livereload.mjs", localhost, srv.Port())
+
+		Convey("the bundled client should be served by default", func() {
+			So(srv.ClientJS(), ShouldBeNil)
+			So(getBody(t, scriptURL), ShouldContainSubstring, "LiveReload")
+		})
+
+		Convey("a custom client should be served with the server's options", func() {
+			srv.SetClientJS([]byte("console.log('100%')"))
+			want := fmt.Sprintf(`window.LiveReloadOptions = {"https":false,"host":"127.0.0.1","port":%d,"path":"livereload"};`+"\nconsole.log('100%%')", srv.Port())
+			So(getBody(t, scriptURL), ShouldEqual, want)
+
+			Convey("at the module path too", func() {
+				So(getBody(t, moduleURL), ShouldEqual, want)
+			})
+
+			Convey("including the token if the page presents it", func() {
+				So(srv.SetAuthToken("s3cret"), ShouldBeNil)
+				So(getBody(t, scriptURL+"?token=s3cret"), ShouldContainSubstring, `"path":"livereload?token=s3cret"`)
+				So(getBody(t, scriptURL), ShouldNotContainSubstring, "s3cret")
+			})
+
+			Convey("and the bundled one restored when cleared", func() {
+				srv.SetClientJS(nil)
+				So(getBody(t, scriptURL), ShouldContainSubstring, "LiveReload")
+				So(getBody(t, moduleURL), ShouldContainSubstring, "export function init")
+			})
+		})
+
+		Convey("a custom client should be read from a file", func() {
+			dir, err := ioutil.TempDir("", "lrserver")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "livereload.js")
+			So(ioutil.WriteFile(path, []byte("window.custom = true"), 0644), ShouldBeNil)
+
+			So(srv.SetClientJSFile(path), ShouldBeNil)
+			So(getBody(t, scriptURL), ShouldEndWith, "\nwindow.custom = true")
+			So(srv.SetClientJSFile(filepath.Join(dir, "missing.js")), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given a running server", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+
+		Convey("the embedded client versions should be selectable", func() {
+			So(lrserver.ClientVersions(), ShouldContain, srv.ClientVersion())
+			So(srv.SetClientVersion(srv.ClientVersion()), ShouldBeNil)
+		})
+
+		Convey("an unknown client version should be refused", func() {
+			before := srv.ClientVersion()
+			So(srv.SetClientVersion("0.0.1"), ShouldNotBeNil)
+			So(srv.ClientVersion(), ShouldEqual, before)
+		})
+	})
+
+	Convey("Given a server created with an unknown client version", t, func() {
+		_, err := lrserver.NewServer(lrserver.WithClientVersion("0.0.1"))
+
+		Convey("creating it should fail", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given a server created with a missing client file", t, func() {
+		_, err := lrserver.NewServer(lrserver.WithClientJSFile("/nonexistent/livereload.js"))
+
+		Convey("creating it should fail", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
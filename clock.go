@@ -0,0 +1,148 @@
+package lrserver
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and schedules the server's timeouts, delays and
+// intervals. Tests can substitute a ManualClock to fast-forward time.
+// Socket deadlines are left to the operating system's clock.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a call scheduled by a Clock
+type Timer interface {
+	// Stop cancels the call, reporting whether it was still pending
+	Stop() bool
+}
+
+// SystemClock is the real clock, used by default
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// ManualClock is a Clock that only moves when advanced, so tests can
+// step through timeouts deterministically
+type ManualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*manualTimer
+}
+
+type manualTimer struct {
+	clock *ManualClock
+	when  time.Time
+	fire  func(now time.Time)
+}
+
+// NewManualClock creates a clock stopped at now
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now gets the clock's current time
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After sends the time on the returned channel once the clock has
+// been advanced by d
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.schedule(d, func(now time.Time) { ch <- now })
+	return ch
+}
+
+// AfterFunc calls f in its own goroutine once the clock has been
+// advanced by d
+func (c *ManualClock) AfterFunc(d time.Duration, f func()) Timer {
+	return c.schedule(d, func(time.Time) { go f() })
+}
+
+// Advance moves the clock forward by d, firing every timer due by then
+// in order
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	var due []*manualTimer
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.when.After(now) {
+			pending = append(pending, t)
+		} else {
+			due = append(due, t)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].when.Before(due[j].when) })
+	for _, t := range due {
+		t.fire(now)
+	}
+}
+
+// Pending gets the number of timers waiting for the clock to advance,
+// which lets tests wait until the code under test is blocked on one
+func (c *ManualClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+func (c *ManualClock) schedule(d time.Duration, fire func(time.Time)) *manualTimer {
+	c.mu.Lock()
+	t := &manualTimer{clock: c, when: c.now.Add(d), fire: fire}
+	if d <= 0 {
+		now := c.now
+		c.mu.Unlock()
+		fire(now)
+		return t
+	}
+	c.timers = append(c.timers, t)
+	c.mu.Unlock()
+	return t
+}
+
+func (t *manualTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Clock gets the clock the server schedules with
+func (s *Server) Clock() Clock {
+	return s.settings().clock
+}
+
+// SetClock sets the clock the server schedules with, SystemClock by
+// default. It's meant for tests, and should be set before serving.
+func (s *Server) SetClock(c Clock) {
+	s.update(func(cfg *settings) { cfg.clock = c })
+}
+
+// now gets the current time on the server's clock
+func (s *Server) now() time.Time {
+	return s.Clock().Now()
+}
@@ -0,0 +1,70 @@
+package lrserver_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestManualClock(t *testing.T) {
+	Convey("Given a manual clock", t, func() {
+		start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		clock := lrserver.NewManualClock(start)
+
+		Convey("timers should only fire once it's advanced far enough", func() {
+			fired := make(chan struct{}, 1)
+			clock.AfterFunc(time.Minute, func() { fired <- struct{}{} })
+			after := clock.After(2 * time.Minute)
+			So(clock.Pending(), ShouldEqual, 2)
+
+			clock.Advance(time.Minute)
+			select {
+			case <-fired:
+			case <-time.After(time.Second):
+				t.Fatal("timer did not fire")
+			}
+			So(clock.Pending(), ShouldEqual, 1)
+			So(clock.Now(), ShouldEqual, start.Add(time.Minute))
+
+			clock.Advance(time.Minute)
+			So(<-after, ShouldEqual, start.Add(2*time.Minute))
+		})
+
+		Convey("stopped timers should never fire", func() {
+			timer := clock.AfterFunc(time.Second, func() { t.Error("stopped timer fired") })
+			So(timer.Stop(), ShouldBeTrue)
+			So(timer.Stop(), ShouldBeFalse)
+			clock.Advance(time.Minute)
+			time.Sleep(10 * time.Millisecond)
+		})
+
+		Convey("command timeouts should follow the server's clock", func() {
+			srv := startServer(t)
+			srv.SetClock(clock)
+			err := srv.AddCommand(lrserver.Command{
+				Pattern: "*.scss",
+				Run:     "exec sleep 5",
+				Timeout: time.Hour,
+			})
+			So(err, ShouldBeNil)
+
+			done := make(chan struct{})
+			go func() {
+				srv.Reload("main.scss")
+				close(done)
+			}()
+			for clock.Pending() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+
+			clock.Advance(time.Hour)
+			select {
+			case <-done:
+			case <-time.After(2 * time.Second):
+				t.Fatal("command was not timed out")
+			}
+		})
+	})
+}
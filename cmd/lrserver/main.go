@@ -0,0 +1,172 @@
+// Command lrserver serves LiveReload, reloading browsers whenever files
+// in the watched directories change:
+//
+//	lrserver --port 35729 --watch ./public --ignore 'node_modules/**' --exec 'make build'
+//
+// Directories are watched recursively, the current one if --watch isn't
+// given, skipping what git ignores with --gitignore. The --exec command
+// runs once for each burst of changes before reloading, with the changed
+// paths in LRSERVER_CHANGED, one per line; if it fails, its output is
+// sent as an alert instead. With --idle-shutdown, lrserver exits once it
+// has had no clients for that long.
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jaschaephraim/lrserver"
+)
+
+// config is the command line configuration
+type config struct {
+	name     string
+	host     string
+	port     uint16
+	watch    []string
+	ignore   []string
+	git      bool
+	poll     time.Duration
+	announce bool
+	open     string
+	idle     time.Duration
+	exec     string
+	debounce time.Duration
+}
+
+// stringList is a flag that may be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// parseFlags parses the command line arguments in args
+func parseFlags(args []string) (*config, error) {
+	cfg := new(config)
+	var watch, ignore stringList
+	var port uint
+
+	fs := flag.NewFlagSet("lrserver", flag.ContinueOnError)
+	fs.StringVar(&cfg.name, "name", lrserver.DefaultName, "server name, shown in logs")
+	fs.StringVar(&cfg.host, "host", lrserver.DefaultHost, "host to listen on")
+	fs.UintVar(&port, "port", uint(lrserver.DefaultPort), "port to listen on")
+	fs.Var(&watch, "watch", "directory to watch, recursively (repeatable; default .)")
+	fs.Var(&ignore, "ignore", "glob of paths to ignore, without watching ignored directories, e.g. 'node_modules/**' (repeatable)")
+	fs.BoolVar(&cfg.git, "gitignore", false, "skip paths ignored by git, without watching ignored directories")
+	fs.DurationVar(&cfg.poll, "poll", 0, "poll for changes at this interval instead of using filesystem notifications, e.g. on Docker volumes")
+	fs.BoolVar(&cfg.announce, "announce", false, "advertise the server over mDNS, for devices on the LAN")
+	fs.StringVar(&cfg.open, "open", "", "URL of the app to open in the browser once listening, except in CI")
+	fs.DurationVar(&cfg.idle, "idle-shutdown", 0, "exit once there have been no clients for this long")
+	fs.StringVar(&cfg.exec, "exec", "", "shell command to run before reloading")
+	fs.DurationVar(&cfg.debounce, "debounce", 100*time.Millisecond, "time to wait for a burst of changes to settle")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if port > 65535 {
+		return nil, fmt.Errorf("invalid port %d", port)
+	}
+
+	cfg.port, cfg.watch, cfg.ignore = uint16(port), watch, ignore
+	if len(cfg.watch) == 0 {
+		cfg.watch = []string{"."}
+	}
+	return cfg, nil
+}
+
+// newServer creates a server configured by cfg, watching its directories
+func newServer(cfg *config) (*lrserver.Server, error) {
+	srv, err := lrserver.NewServer(
+		lrserver.WithName(cfg.name),
+		lrserver.WithHost(cfg.host),
+		lrserver.WithPort(cfg.port),
+		lrserver.WithOpenBrowser(cfg.open),
+		lrserver.WithIdleShutdown(cfg.idle),
+	)
+	if err != nil {
+		return nil, err
+	}
+	srv.SetDebounce(cfg.debounce)
+	if cfg.poll > 0 {
+		srv.SetPollInterval(cfg.poll)
+		srv.SetPolling(true)
+	}
+	if cfg.exec != "" {
+		srv.SetBuildHook(execHook(cfg.exec))
+	}
+	if cfg.announce {
+		if err = srv.Announce(); err != nil {
+			return nil, err
+		}
+	}
+	opts := []lrserver.WatchOption{lrserver.Recursive, lrserver.Ignore(cfg.ignore...)}
+	if cfg.git {
+		opts = append(opts, lrserver.GitIgnore)
+	}
+	for _, dir := range cfg.watch {
+		err = srv.Watch(dir, opts...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return srv, nil
+}
+
+// execHook gets a build hook running script in the shell
+func execHook(script string) lrserver.BuildHook {
+	return func(changed []string) error {
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/C", script)
+		} else {
+			cmd = exec.Command("sh", "-c", script)
+		}
+		cmd.Env = append(os.Environ(), "LRSERVER_CHANGED="+strings.Join(changed, "\n"))
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s: %v\n%s", script, err, bytes.TrimSpace(out))
+		}
+		return nil
+	}
+}
+
+func main() {
+	cfg, err := parseFlags(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "lrserver:", err)
+		os.Exit(2)
+	}
+
+	srv, err := newServer(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "lrserver:", err)
+		os.Exit(1)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := srv.ListenAndServeContext(ctx); err != nil && err != http.ErrServerClosed {
+		fmt.Fprintln(os.Stderr, "lrserver:", err)
+		os.Exit(1)
+	}
+}
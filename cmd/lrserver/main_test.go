@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseFlags(t *testing.T) {
+	Convey("Given no arguments", t, func() {
+		cfg, err := parseFlags(nil)
+		So(err, ShouldBeNil)
+
+		Convey("the defaults should be used", func() {
+			So(cfg.name, ShouldEqual, lrserver.DefaultName)
+			So(cfg.port, ShouldEqual, lrserver.DefaultPort)
+			So(cfg.watch, ShouldResemble, []string{"."})
+			So(cfg.debounce, ShouldEqual, 100*time.Millisecond)
+		})
+	})
+
+	Convey("Given a full command line", t, func() {
+		cfg, err := parseFlags([]string{
+			"--port", "8081",
+			"--watch", "./public", "--watch", "./assets",
+			"--ignore", "node_modules/**", "--ignore", "*.map",
+			"--gitignore",
+			"--poll", "2s",
+			"--announce",
+			"--open", "http://localhost:3000/",
+			"--idle-shutdown", "10m",
+			"--exec", "make build",
+			"--debounce", "250ms",
+		})
+		So(err, ShouldBeNil)
+
+		Convey("every flag should be applied", func() {
+			So(cfg.port, ShouldEqual, 8081)
+			So(cfg.watch, ShouldResemble, []string{"./public", "./assets"})
+			So(cfg.ignore, ShouldResemble, []string{"node_modules/**", "*.map"})
+			So(cfg.git, ShouldBeTrue)
+			So(cfg.poll, ShouldEqual, 2*time.Second)
+			So(cfg.announce, ShouldBeTrue)
+			So(cfg.open, ShouldEqual, "http://localhost:3000/")
+			So(cfg.idle, ShouldEqual, 10*time.Minute)
+			So(cfg.exec, ShouldEqual, "make build")
+			So(cfg.debounce, ShouldEqual, 250*time.Millisecond)
+		})
+	})
+
+	Convey("Given bad arguments", t, func() {
+		Convey("they should be refused", func() {
+			_, err := parseFlags([]string{"--port", "70000"})
+			So(err, ShouldNotBeNil)
+			_, err = parseFlags([]string{"public"})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestNewServer(t *testing.T) {
+	Convey("Given a directory to watch", t, func() {
+		dir, err := ioutil.TempDir("", "lrserver")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		Convey("a configured server should be created", func() {
+			srv, err := newServer(&config{
+				name:     "test",
+				host:     "127.0.0.1",
+				watch:    []string{dir},
+				git:      true,
+				poll:     time.Second,
+				exec:     "true",
+				debounce: time.Second,
+			})
+			So(err, ShouldBeNil)
+			defer srv.Close()
+			So(srv.Name(), ShouldEqual, "test")
+			So(srv.Debounce(), ShouldEqual, time.Second)
+			So(srv.Polling(), ShouldBeTrue)
+			So(srv.Unwatch(dir), ShouldBeNil)
+		})
+	})
+}
+
+func TestExecHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+
+	Convey("Given an --exec command", t, func() {
+		Convey("it should get the changed paths", func() {
+			dir, err := ioutil.TempDir("", "lrserver")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+			out := filepath.Join(dir, "changed")
+
+			hook := execHook(`printf '%s' "$LRSERVER_CHANGED" > ` + out)
+			So(hook([]string{"index.html", "css/main.css"}), ShouldBeNil)
+			changed, err := ioutil.ReadFile(out)
+			So(err, ShouldBeNil)
+			So(string(changed), ShouldEqual, "index.html\ncss/main.css")
+		})
+
+		Convey("its failures should carry its output", func() {
+			err := execHook("echo broken; exit 1")([]string{"index.html"})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "broken")
+		})
+	})
+}
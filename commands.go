@@ -0,0 +1,153 @@
+package lrserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Command is a shell command run before reloading changed files
+// matching Pattern, such as regenerating a stylesheet
+type Command struct {
+	// Pattern is a glob matched against changed paths,
+	// where ** matches any number of directories
+	Pattern string
+
+	// Run is the shell command, as a text/template. The template data
+	// has the changed path's File, Dir, Base, Ext and Name (the base
+	// without its extension), which are shell-quoted when printed, since
+	// paths can come from trigger requests and stdin commands:
+	// `tailwindcss -o dist/tailwind.css --content {{.File}}`. The raw
+	// function prints one unquoted, and quote shell-quotes other values.
+	Run string
+
+	// Dir is the working directory, the current one if empty
+	Dir string
+
+	// Timeout bounds the command's run time if positive
+	Timeout time.Duration
+}
+
+// commandData is the template data of a Command
+type commandData struct {
+	File, Dir, Base, Ext, Name shellValue
+}
+
+// shellValue is a template value that is shell-quoted when printed
+type shellValue string
+
+// String gets the value shell-quoted
+func (v shellValue) String() string {
+	return shellQuote(string(v))
+}
+
+// commandFuncs are the functions of Command templates
+var commandFuncs = template.FuncMap{
+	"raw": func(v shellValue) string {
+		return string(v)
+	},
+	"quote": func(v interface{}) string {
+		// Template data is already quoted
+		if v, ok := v.(shellValue); ok {
+			return v.String()
+		}
+		return shellQuote(fmt.Sprint(v))
+	},
+}
+
+// compiledCommand is a Command with its pattern and template parsed
+type compiledCommand struct {
+	Command
+	glob *globMatcher
+	tmpl *template.Template
+}
+
+// AddCommand attaches a shell command to run before reloading files
+// matching its pattern. Matching commands run in the order they were
+// added. If one fails, its output is sent as an alert instead of
+// reloading the file.
+func (s *Server) AddCommand(c Command) error {
+	glob, err := newGlobMatcher(c.Pattern)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New(c.Pattern).Funcs(commandFuncs).Parse(c.Run)
+	if err != nil {
+		return err
+	}
+	s.update(func(cfg *settings) {
+		cfg.commands = append(cfg.commands[:len(cfg.commands):len(cfg.commands)], &compiledCommand{c, glob, tmpl})
+	})
+	return nil
+}
+
+// runCommands runs the commands matching file, returning
+// false if any failed, after alerting the failure
+func (s *Server) runCommands(file string) bool {
+	for _, c := range s.settings().commands {
+		if !c.glob.match(file) {
+			continue
+		}
+
+		start := s.now()
+		err := c.run(file, s.Clock())
+		s.timing("command", start)
+		if err != nil {
+			s.logError(err)
+			s.Alert(err.Error())
+			return false
+		}
+	}
+	return true
+}
+
+func (c *compiledCommand) run(file string, clock Clock) error {
+	base := filepath.Base(file)
+	ext := filepath.Ext(file)
+	data := commandData{
+		File: shellValue(file),
+		Dir:  shellValue(filepath.Dir(file)),
+		Base: shellValue(base),
+		Ext:  shellValue(ext),
+		Name: shellValue(strings.TrimSuffix(base, ext)),
+	}
+	var script bytes.Buffer
+	err := c.tmpl.Execute(&script, data)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if c.Timeout > 0 {
+		defer clock.AfterFunc(c.Timeout, cancel).Stop()
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", script.String())
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", script.String())
+	}
+	cmd.Dir = c.Dir
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %v\n%s", script.String(), err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// shellQuote quotes v for a POSIX shell
+func shellQuote(v string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.Replace(v, `"`, `""`, -1) + `"`
+	}
+	return "'" + strings.Replace(v, "'", `'\''`, -1) + "'"
+}
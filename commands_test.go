@@ -0,0 +1,137 @@
+package lrserver_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCommands(t *testing.T) {
+	Convey("Given a running server with a connected websocket", t, func() {
+		dir, err := ioutil.TempDir("", "lrserver")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		srv := startServer(t)
+		conn := connect(t, srv)
+		defer conn.Close()
+
+		Convey("matching commands should run before the reload is sent", func() {
+			err := srv.AddCommand(lrserver.Command{
+				Pattern: "src/**/*.css",
+				Run:     `echo {{.Name}} >> log`,
+				Dir:     dir,
+			})
+			So(err, ShouldBeNil)
+
+			srv.Reload("src/styles/it's.css")
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "src/styles/it's.css")
+
+			srv.Reload("other/main.css")
+			_, err = readReload(conn)
+			So(err, ShouldBeNil)
+
+			out, err := ioutil.ReadFile(filepath.Join(dir, "log"))
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, "it's\n")
+		})
+
+		Convey("changed paths should be shell-quoted unless raw", func() {
+			err := srv.AddCommand(lrserver.Command{
+				Pattern: "**",
+				Run:     `echo {{.File}} {{.Dir}}/{{.Name}}{{.Ext}} >> log; echo {{raw .Ext}} >> log`,
+				Dir:     dir,
+			})
+			So(err, ShouldBeNil)
+
+			for _, path := range []string{"a;touch semicolon.css", "$(touch subshell).css", "`touch backtick`.css"} {
+				srv.Reload(path)
+				sr, err := readReload(conn)
+				So(err, ShouldBeNil)
+				So(sr.Path, ShouldEqual, path)
+			}
+
+			for _, name := range []string{"semicolon.css", "subshell", "backtick"} {
+				_, err := os.Stat(filepath.Join(dir, name))
+				So(os.IsNotExist(err), ShouldBeTrue)
+			}
+			out, err := ioutil.ReadFile(filepath.Join(dir, "log"))
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, "a;touch semicolon.css ./a;touch semicolon.css\n.css\n"+
+				"$(touch subshell).css ./$(touch subshell).css\n.css\n"+
+				"`touch backtick`.css ./`touch backtick`.css\n.css\n")
+		})
+
+		Convey("failing commands should alert instead of reloading", func() {
+			err := srv.AddCommand(lrserver.Command{
+				Pattern: "*.css",
+				Run:     `echo broken stylesheet; exit 3`,
+				Timeout: time.Second,
+			})
+			So(err, ShouldBeNil)
+
+			srv.Reload("main.css")
+
+			sa := new(serverAlert)
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			err = conn.ReadJSON(sa)
+			So(err, ShouldBeNil)
+			So(sa.Command, ShouldEqual, "alert")
+			So(sa.Message, ShouldContainSubstring, "exit status 3")
+			So(strings.Contains(sa.Message, "broken stylesheet"), ShouldBeTrue)
+		})
+
+		Convey("invalid commands should be rejected", func() {
+			So(srv.AddCommand(lrserver.Command{Pattern: "[", Run: "true"}), ShouldNotBeNil)
+			So(srv.AddCommand(lrserver.Command{Pattern: "*", Run: "{{"}), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestBuildHook(t *testing.T) {
+	Convey("Given a running server with a build hook and a connected websocket", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		conn := connect(t, srv)
+		defer conn.Close()
+
+		var builds [][]string
+		var fail error
+		srv.SetBuildHook(func(changed []string) error {
+			builds = append(builds, changed)
+			return fail
+		})
+
+		Convey("a change set should be built once before reloading", func() {
+			srv.ReloadAll("css/a.css", "css/b.css")
+			for _, path := range []string{"css/a.css", "css/b.css"} {
+				sr, err := readReload(conn)
+				So(err, ShouldBeNil)
+				So(sr.Path, ShouldEqual, path)
+			}
+			So(builds, ShouldResemble, [][]string{{"css/a.css", "css/b.css"}})
+		})
+
+		Convey("a failed build should be alerted instead of reloading", func() {
+			fail = errors.New("syntax error in main.scss")
+			srv.Reload("css/main.css")
+			sa := new(serverAlert)
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			So(conn.ReadJSON(sa), ShouldBeNil)
+			So(sa.Command, ShouldEqual, "alert")
+			So(sa.Message, ShouldEqual, "syntax error in main.scss")
+			So(builds, ShouldResemble, [][]string{{"css/main.css"}})
+		})
+	})
+}
@@ -1,36 +1,59 @@
 package lrserver
 
 import (
+	"context"
 	"encoding/json"
+	"io"
+	"io/ioutil"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
 type conn struct {
-	conn *websocket.Conn
+	id         uint64
+	conn       *websocket.Conn
+	stream     *sseStream
+	remoteAddr string
+	userAgent  string
 
-	server    *Server
-	handshake bool
+	server      *Server
+	handshake   int32
+	protocol    string
+	connectedAt time.Time
 
-	reloadChan chan string
-	alertChan  chan string
-	closeChan  chan closeSignal
+	queue   *sendQueue
+	limiter rateLimiter
+	ctx     context.Context
+	cancel  context.CancelFunc
+	closed  int32
+	pongs   uint32
+	url     atomic.Value
+	client  atomic.Value
 }
 
 func (c *conn) start() {
-	go c.receive()
-	go c.transmit()
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetPongHandler(func(string) error {
+		atomic.AddUint32(&c.pongs, 1)
+		return nil
+	})
+	c.server.spawn(c.receive)
+	c.server.spawn(c.transmit)
+	c.server.spawn(c.keepalive)
 
 	// Say hello
-	err := c.conn.WriteJSON(makeServerHello(c.server.Name()))
+	c.conn.SetWriteDeadline(c.server.writeDeadline())
+	err := c.conn.WriteJSON(makeServerHello(c.server.Name(), c.server.protocols(), c.server.Epoch()))
 	if err != nil {
 		c.close(websocket.CloseInternalServerErr, err)
 	}
 
-	// Block until close signal is sent
-	<-c.closeChan
+	// Block until closed, or the server stops
+	<-c.ctx.Done()
+	c.close(websocket.CloseGoingAway, nil)
 }
 
 func (c *conn) receive() {
@@ -42,73 +65,207 @@ func (c *conn) receive() {
 			return
 		}
 
-		// Close if binary instead of text
-		if msgType == websocket.BinaryMessage {
-			c.close(websocket.CloseUnsupportedData, nil)
-			return
+		// Discard everything after the handshake in read-only mode
+		if c.shookHands() && c.server.ReadOnly() {
+			io.Copy(ioutil.Discard, reader)
+			continue
 		}
 
-		// Close if it's not JSON
-		hello := new(clientHello)
-		err = json.NewDecoder(reader).Decode(hello)
+		// Close if it's not a valid message
+		msg, closeCode, err := decodeClientMessage(msgType, reader)
 		if err != nil {
-			c.close(websocket.ClosePolicyViolation, err)
+			c.close(closeCode, err)
 			return
 		}
 
-		// Close if missing a command field
-		if hello.Command == "" {
-			c.close(websocket.ClosePolicyViolation, nil)
-		}
-
 		// Validate handshake
-		if !c.handshake {
-			if !validateHello(hello) {
+		if !c.shookHands() {
+			if !validateHello(msg) {
 				c.badHandshake()
 				return
 			}
-			c.handshake = true
-			c.server.logStatus("connected")
+			// Set before connected publishes them, as other goroutines
+			// read them only once the handshake is marked complete
+			c.protocol, c.connectedAt = negotiateProtocol(msg), c.server.now()
+			c.client.Store(clientInfoFromHello(msg))
+			c.server.logDebug("handshake", "remote", c.remoteAddr, "protocol", c.protocol, "ver", msg.Ver, "ext", msg.Ext)
+			c.connected()
+			c.checkVersion(msg)
+			c.checkEpoch(msg.Epoch)
+			continue
+		}
+
+		// Track the page's URL and plugins
+		if (msg.Command == "info" || msg.Command == "url") && msg.URL != "" {
+			c.url.Store(msg.URL)
 		}
+		if msg.Command == "info" && len(msg.Plugins) > 0 {
+			c.updatePlugins(msg.Plugins)
+		}
+	}
+}
+
+// connected marks the handshake complete and announces the client
+func (c *conn) connected() {
+	atomic.StoreInt32(&c.handshake, 1)
+	c.server.logStatus("connected: " + c.remoteAddr)
+	c.server.emit(Event{Type: EventConnected, Remote: c.remoteAddr})
+	if h := c.server.settings().connectHandler; h != nil {
+		h(c.info())
+	}
+	c.sendPending()
+}
+
+// checkEpoch fully reloads pages left open across a server restart
+func (c *conn) checkEpoch(epoch string) {
+	if epoch != "" && epoch != c.server.Epoch() {
+		c.server.logStatus("stale client, reloading page: " + c.remoteAddr)
+		c.reloadPage()
 	}
 }
 
 func (c *conn) transmit() {
 	for {
-		var resp interface{}
 		select {
-
-		// Reload
-		case file := <-c.reloadChan:
-			if !c.handshake {
-				c.badHandshake()
+		case <-c.ctx.Done():
+			return
+		case <-c.queue.ready:
+		}
+		for _, out := range c.queue.drain() {
+			if out.closeCode != 0 {
+				c.close(out.closeCode, nil)
 				return
 			}
-			resp = makeServerReload(file, c.server.LiveCSS())
-
-		// Alert
-		case msg := <-c.alertChan:
-			if !c.handshake {
+			if !c.shookHands() {
 				c.badHandshake()
 				return
 			}
-			resp = makeServerAlert(msg)
+			err := c.write(out.data)
+			if err != nil {
+				c.close(websocket.CloseInternalServerErr, err)
+				return
+			}
+			c.server.emit(out.delivered)
 		}
+	}
+}
 
-		err := c.conn.WriteJSON(resp)
-		if err != nil {
-			c.close(websocket.CloseInternalServerErr, err)
+// write sends an encoded message over the websocket, or the event
+// stream of an SSE client, within the write timeout
+func (c *conn) write(data []byte) error {
+	deadline := c.server.writeDeadline()
+	if c.stream != nil {
+		return c.stream.write(data, deadline)
+	}
+	c.conn.SetWriteDeadline(deadline)
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// send queues an encoded message, once the rate limit allows it
+func (c *conn) send(data []byte, delivered Event) {
+	delivered.Type, delivered.Remote = EventDelivered, c.remoteAddr
+	if c.legacy() {
+		if data = legacyMessage(data); data == nil {
+			return
+		}
+	}
+	out := outbound{data: data, delivered: delivered}
+	if !c.rateLimited(out) {
+		c.enqueue(out)
+	}
+}
+
+// enqueue queues out, applying the slow client policy if the
+// connection's queue is already full
+func (c *conn) enqueue(out outbound) {
+	cfg := c.server.settings()
+	if c.queue.push(out, cfg.maxQueuedMessages, cfg.maxQueuedBytes) {
+		return
+	}
+
+	switch cfg.slowClientPolicy {
+	case DropMessages:
+		c.server.dropped(c.remoteAddr, 1)
+	case CoalesceMessages:
+		reload, ok := c.pageReload()
+		if !ok {
 			return
 		}
+		n := c.queue.replace(reload)
+		c.server.dropped(c.remoteAddr, n+1)
+	default:
+		atomic.AddInt64(&c.server.stats.evictions, 1)
+		c.server.increment("evictions")
+		c.server.spawn(func() {
+			c.close(websocket.CloseTryAgainLater, errQueueFull)
+		})
 	}
 }
 
+// closeWhenSent closes the connection with closeCode once everything
+// already queued for it has been sent
+func (c *conn) closeWhenSent(closeCode int) {
+	c.queue.push(outbound{closeCode: closeCode}, 0, 0)
+}
+
+// checkVersion warns about pages running a different livereload.js than
+// the one served, typically a stale cached copy. Browser extensions
+// bundle their own and are left alone.
+func (c *conn) checkVersion(hello *clientMessage) {
+	cfg := c.server.settings()
+	if hello.Ver == "" || hello.Ext != "" || hello.Ver == cfg.clientVersion || cfg.clientJS != "" {
+		return
+	}
+	msg := "stale livereload.js " + hello.Ver + " (served " + cfg.clientVersion + "), clear the browser cache"
+	c.server.logError(msg + ": " + c.remoteAddr)
+	if !c.server.AlertStaleScripts() || c.server.AlertsDisabled() {
+		return
+	}
+	data, err := json.Marshal(makeServerAlert(msg))
+	if err != nil {
+		c.server.logError(err)
+		return
+	}
+	c.send(data, Event{Command: "alert", Message: msg})
+}
+
+// reloadPage tells the client to reload the whole page
+func (c *conn) reloadPage() {
+	data, err := json.Marshal(makeServerReload("", false))
+	if err != nil {
+		c.server.logError(err)
+		return
+	}
+	c.send(data, Event{Command: "reload"})
+}
+
+// pageURL gets the URL of the client's page, if it has reported one
+func (c *conn) pageURL() string {
+	u, _ := c.url.Load().(string)
+	return u
+}
+
+// legacy reports whether the client speaks protocol 6. The protocol is
+// set before the handshake is marked complete, so it's only read after.
+func (c *conn) legacy() bool {
+	return c.shookHands() && c.protocol == legacyProtocol
+}
+
+// shookHands reports whether the client has sent a valid hello
+func (c *conn) shookHands() bool {
+	return atomic.LoadInt32(&c.handshake) == 1
+}
+
 func (c *conn) badHandshake() {
+	c.server.emit(Event{Type: EventHandshakeFailed, Remote: c.remoteAddr, Error: "invalid hello"})
 	c.close(websocket.ClosePolicyViolation, websocket.ErrBadHandshake)
 }
 
 func (c *conn) close(closeCode int, closeErr error) error {
-	var err error
+	// Only the first close counts
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return nil
+	}
 	var errMsg string
 
 	if closeErr != nil {
@@ -116,12 +273,8 @@ func (c *conn) close(closeCode int, closeErr error) error {
 		c.server.logError(closeErr)
 
 		// Attempt to set close code from error message
-		errMsgLen := len(errMsg)
-		if errMsgLen >= 21 && errMsg[:17] == "websocket: close " {
-			closeCode, err = strconv.Atoi(errMsg[17:21])
-			if errMsgLen > 21 {
-				errMsg = errMsg[22:]
-			}
+		if code, text, ok := parseCloseError(errMsg); ok {
+			closeCode, errMsg = code, text
 		}
 	}
 
@@ -129,26 +282,48 @@ func (c *conn) close(closeCode int, closeErr error) error {
 	if closeCode == 0 {
 		closeCode = websocket.CloseNoStatusReceived
 	}
+	return c.closeWith(closeCode, errMsg)
+}
 
-	// Send close message
-	closeMessage := websocket.FormatCloseMessage(closeCode, errMsg)
-	deadline := time.Now().Add(time.Second)
-	err = c.conn.WriteControl(websocket.CloseMessage, closeMessage, deadline)
+// closeWith sends a close frame with closeCode and text, and removes the
+// connection. The caller must already have claimed the close.
+func (c *conn) closeWith(closeCode int, text string) error {
+	// Send close message. SSE clients just see their stream end.
+	var err error
+	if c.stream == nil {
+		closeMessage := websocket.FormatCloseMessage(closeCode, text)
+		deadline := time.Now().Add(time.Second)
+		err = c.conn.WriteControl(websocket.CloseMessage, closeMessage, deadline)
+		c.conn.Close()
+	}
 
-	// Kill and remove connection
-	c.closeChan <- closeSignal{}
-	c.server.conns.remove(c)
+	// Kill and remove connection, which ends its goroutines
+	c.cancel()
+	if c.server.conns.remove(c) {
+		c.server.checkIdle()
+		c.server.emit(Event{Type: EventDisconnected, Remote: c.remoteAddr})
+		if h := c.server.settings().disconnectHandler; h != nil && c.shookHands() {
+			h(c.info())
+		}
+	}
 	return err
 }
 
-type connSet map[*conn]struct{}
-
-func (cs connSet) add(c *conn) {
-	cs[c] = struct{}{}
-}
+// parseCloseError extracts the close code and text from the message of
+// an error like "websocket: close 1001 (going away): reason"
+func parseCloseError(errMsg string) (int, string, bool) {
+	const prefix = "websocket: close "
+	if len(errMsg) < len(prefix)+4 || errMsg[:len(prefix)] != prefix {
+		return 0, "", false
+	}
+	code, err := strconv.Atoi(errMsg[len(prefix) : len(prefix)+4])
+	if err != nil || code < 1000 || code > 4999 {
+		return 0, "", false
+	}
 
-func (cs connSet) remove(c *conn) {
-	delete(cs, c)
+	text := errMsg[len(prefix)+4:]
+	if len(text) > 0 {
+		text = text[1:]
+	}
+	return code, text, true
 }
-
-type closeSignal struct{}
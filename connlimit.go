@@ -0,0 +1,58 @@
+package lrserver
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// MaxConnections gets the number of clients that may be connected at once
+func (s *Server) MaxConnections() int {
+	return s.settings().maxConnections
+}
+
+// SetMaxConnections sets the number of clients that may be connected at
+// once, so a runaway test harness or a pile of forgotten tabs can't use
+// up the process's file descriptors. There's no limit by default, and
+// zero or less removes it. Websockets beyond the limit are closed with
+// code 1013 (try again later) straight after the upgrade, which
+// livereload.js retries, and event streams get 503 Service Unavailable.
+// Either way, a warning is logged.
+func (s *Server) SetMaxConnections(n int) {
+	s.update(func(cfg *settings) { cfg.maxConnections = n })
+}
+
+// admit registers c unless the connection limit has been reached, in
+// which case it counts and logs the rejection and reports false. A
+// connection registered after Shutdown has already been through the
+// registry is told to go away like the rest.
+func (s *Server) admit(c *conn) bool {
+	max := s.MaxConnections()
+	if s.conns.addLimited(c, max) {
+		if atomic.LoadInt32(&s.shuttingDown) == 1 {
+			c.closeWhenSent(websocket.CloseGoingAway)
+		}
+		s.checkIdle()
+		return true
+	}
+	atomic.AddInt64(&s.stats.rejected, 1)
+	s.increment("rejected")
+	s.logError("connection limit of " + strconv.Itoa(max) + " reached, refusing " + c.remoteAddr)
+	return false
+}
+
+// rejectWebSocket closes a websocket refused by the connection limit
+func rejectWebSocket(wsConn *websocket.Conn) {
+	closeMessage := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too many connections")
+	wsConn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second))
+	wsConn.Close()
+}
+
+// rejectStream answers an event stream refused by the connection limit
+func rejectStream(rw http.ResponseWriter) {
+	rw.Header().Set("Retry-After", "1")
+	http.Error(rw, "too many connections", http.StatusServiceUnavailable)
+}
@@ -0,0 +1,47 @@
+package lrserver_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMaxConnections(t *testing.T) {
+	Convey("Given a running server limited to one connection", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		srv.SetMaxConnections(1)
+		So(srv.MaxConnections(), ShouldEqual, 1)
+		conn := connect(t, srv)
+		defer conn.Close()
+
+		Convey("further websockets should be told to try again later", func() {
+			dialer := new(websocket.Dialer)
+			extra, _, err := dialer.Dial(fmt.Sprintf("ws%s:%d/livereload", localhost, srv.Port()), nil)
+			So(err, ShouldBeNil)
+			defer extra.Close()
+
+			_, _, err = extra.ReadMessage()
+			So(websocket.IsCloseError(err, websocket.CloseTryAgainLater), ShouldBeTrue)
+			So(srv.Status().Rejected, ShouldEqual, 1)
+			So(srv.Status().Config.MaxConnections, ShouldEqual, 1)
+
+			Convey("until the connected one leaves", func() {
+				conn.Close()
+				So(waitFor(func() bool { return srv.Status().Clients == 0 }), ShouldBeTrue)
+				connect(t, srv).Close()
+			})
+		})
+
+		Convey("further event streams should be refused", func() {
+			resp, err := http.Get(fmt.Sprintf("http%s:%d/livereload/sse", localhost, srv.Port()))
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusServiceUnavailable)
+			So(srv.Status().Clients, ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,308 @@
+package lrserver
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DashboardPath is where EnableDashboard serves the dashboard
+const DashboardPath = "/livereload/ui"
+
+// dashboardHistory is how many recent events a newly opened dashboard
+// is shown
+const dashboardHistory = 100
+
+// dashboard passes events on to open dashboard pages
+type dashboard struct {
+	enabled int32
+	mu      sync.Mutex
+	subs    map[chan Event]struct{}
+	recent  []Event
+}
+
+// publish passes e on to every open dashboard, dropping it for those
+// too far behind
+func (d *dashboard) publish(e Event) {
+	if atomic.LoadInt32(&d.enabled) == 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.recent) == dashboardHistory {
+		n := copy(d.recent, d.recent[1:])
+		d.recent = d.recent[:n]
+	}
+	d.recent = append(d.recent, e)
+	for ch := range d.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// subscribe gets the recent events, and a channel receiving new ones
+// until unsubscribed
+func (d *dashboard) subscribe() (chan Event, []Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.subs == nil {
+		d.subs = make(map[chan Event]struct{})
+	}
+	ch := make(chan Event, dashboardHistory)
+	d.subs[ch] = struct{}{}
+	return ch, append([]Event(nil), d.recent...)
+}
+
+func (d *dashboard) unsubscribe(ch chan Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.subs, ch)
+}
+
+// dashboardUpdate is sent to dashboard pages whenever something happens
+type dashboardUpdate struct {
+	Events  []Event    `json:"events"`
+	Clients []ConnInfo `json:"clients"`
+}
+
+// EnableDashboard serves a page at DashboardPath, under any path prefix,
+// listing the connected clients and their pages along with a live log of
+// recent events, with forms to reload a path or send an alert by hand.
+// It's handy for finding out why a tab isn't reloading. Like an open
+// trigger, it can be used by anyone who can reach the server, unless an
+// auth token is set: the page must then be opened with ?token=, and
+// passes it on to its socket.
+func (s *Server) EnableDashboard() error {
+	err := s.alias(s.prefix+DashboardPath, dashboardHandler(s))
+	if err != nil {
+		return err
+	}
+	err = s.alias(s.prefix+DashboardPath+"/ws", dashboardSocketHandler(s))
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt32(&s.dash.enabled, 1)
+	return nil
+}
+
+func dashboardHandler(s *Server) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if !s.checkHost(rw, req) {
+			return
+		}
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			rw.Header().Set("Allow", "GET, HEAD")
+			http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if !s.authorized(req) {
+			http.Error(rw, "missing or wrong auth token", http.StatusUnauthorized)
+			return
+		}
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		rw.Header().Set("Cache-Control", "no-store")
+		socket := s.prefix + DashboardPath + "/ws" + s.tokenQuery("?")
+		err := dashboardPage.Execute(rw, struct{ Name, Socket string }{s.name, socket})
+		if err != nil {
+			s.logError(err)
+		}
+	}
+}
+
+// dashboardSocketHandler streams updates to a dashboard page, and takes
+// reload and alert commands from it in the same form as ServeStdin
+func dashboardSocketHandler(s *Server) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if !s.checkHost(rw, req) {
+			return
+		}
+		if f := diagnoseUpgrade(req); f != nil {
+			writeUpgradeFailure(s, rw, req, f)
+			return
+		}
+		// Commands can only come from the dashboard page itself
+		if !sameOriginRequest(req) {
+			writeUpgradeFailure(s, rw, req, &upgradeFailure{
+				http.StatusForbidden,
+				"pages from " + req.Header.Get("Origin") + " may not control the server",
+				"open the dashboard at " + s.prefix + DashboardPath,
+			})
+			return
+		}
+		if !s.authorized(req) {
+			writeUpgradeFailure(s, rw, req, &upgradeFailure{
+				http.StatusUnauthorized,
+				"missing or wrong auth token",
+				"open the dashboard with ?token=",
+			})
+			return
+		}
+		ws, err := s.upgrader().Upgrade(rw, req, nil)
+		if err != nil {
+			writeUpgradeFailure(s, rw, req, &upgradeFailure{
+				http.StatusBadRequest,
+				err.Error(),
+				"check the client and any proxies in between",
+			})
+			return
+		}
+		s.goroutines.add()
+		defer s.goroutines.done()
+		defer ws.Close()
+
+		events, batch := s.dash.subscribe()
+		defer s.dash.unsubscribe(events)
+		ctx, cancel := context.WithCancel(s.ctx)
+		defer cancel()
+
+		ws.SetReadLimit(maxMessageSize)
+		s.spawn(func() {
+			defer cancel()
+			for {
+				cmd := new(StdinCommand)
+				if err := ws.ReadJSON(cmd); err != nil {
+					return
+				}
+				if err := s.runStdinCommand(cmd); err != nil {
+					s.logError("dashboard:", err)
+				}
+			}
+		})
+
+		for {
+			ws.SetWriteDeadline(s.writeDeadline())
+			err := ws.WriteJSON(dashboardUpdate{Events: batch, Clients: s.Connections()})
+			if err != nil {
+				return
+			}
+			select {
+			case <-ctx.Done():
+			case <-s.halted:
+			case e := <-events:
+				batch = []Event{e}
+				for len(events) > 0 {
+					batch = append(batch, <-events)
+				}
+				continue
+			}
+			closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, "")
+			ws.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second))
+			return
+		}
+	}
+}
+
+// sameOriginRequest reports whether req comes from a page served by the
+// host it was sent to, or from outside a browser
+func sameOriginRequest(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, req.Host)
+}
+
+var dashboardPage = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}} dashboard</title>
+<style>
+body { font: 14px/1.4 system-ui, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.4em; }
+h2 { font-size: 1.1em; margin-top: 1.5em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 4px 8px; border-bottom: 1px solid #ddd; }
+form { display: inline-block; margin-right: 2em; }
+#status { color: #888; }
+#log { font: 12px/1.4 ui-monospace, monospace; max-height: 24em; overflow-y: auto; background: #f6f6f6; padding: 8px; }
+</style>
+</head>
+<body>
+<h1>{{.Name}} <span id="status">connecting&hellip;</span></h1>
+<form id="reload"><input name="path" value="index.html" size="30"> <button>Reload</button></form>
+<form id="alert"><input name="message" placeholder="Message" size="30"> <button>Alert</button></form>
+<h2>Clients</h2>
+<table>
+<thead><tr><th>ID</th><th>Page</th><th>Address</th><th>Protocol</th><th>Connected</th></tr></thead>
+<tbody id="clients"></tbody>
+</table>
+<h2>Events</h2>
+<div id="log"></div>
+<script>
+(function() {
+  var socketPath = {{.Socket}};
+  var socket;
+
+  function cell(row, text) {
+    var td = document.createElement('td');
+    td.textContent = text || '';
+    row.appendChild(td);
+  }
+
+  function update(msg) {
+    var clients = document.getElementById('clients');
+    clients.innerHTML = '';
+    (msg.clients || []).forEach(function(c) {
+      var row = document.createElement('tr');
+      cell(row, c.ID);
+      cell(row, c.URL);
+      cell(row, c.RemoteAddr);
+      cell(row, c.Protocol.replace(/^.*\//, ''));
+      cell(row, new Date(c.ConnectedAt).toLocaleTimeString());
+      clients.appendChild(row);
+    });
+
+    var log = document.getElementById('log');
+    (msg.events || []).forEach(function(e) {
+      var line = document.createElement('div');
+      line.textContent = new Date(e.time).toLocaleTimeString() + ' ' + e.event +
+        [e.remote, e.command, e.path, e.message, e.error].filter(Boolean).map(function(s) { return ' ' + s; }).join('');
+      log.appendChild(line);
+    });
+    log.scrollTop = log.scrollHeight;
+  }
+
+  function connect() {
+    var status = document.getElementById('status');
+    socket = new WebSocket((location.protocol === 'https:' ? 'wss://' : 'ws://') + location.host + socketPath);
+    socket.onopen = function() { status.textContent = 'connected'; };
+    socket.onmessage = function(e) { update(JSON.parse(e.data)); };
+    socket.onclose = function() {
+      status.textContent = 'disconnected, retrying';
+      setTimeout(connect, 2000);
+    };
+  }
+
+  function send(cmd) {
+    if (socket && socket.readyState === WebSocket.OPEN) {
+      socket.send(JSON.stringify(cmd));
+    }
+  }
+
+  document.getElementById('reload').onsubmit = function(e) {
+    e.preventDefault();
+    send({cmd: 'reload', path: this.path.value});
+  };
+  document.getElementById('alert').onsubmit = function(e) {
+    e.preventDefault();
+    send({cmd: 'alert', message: this.message.value});
+  };
+
+  document.getElementById('log').innerHTML = '';
+  connect();
+})();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,119 @@
+package lrserver_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// dashboardUpdate mirrors what the dashboard socket sends
+type dashboardUpdate struct {
+	Events []struct {
+		Type    string `json:"event"`
+		Path    string `json:"path"`
+		Message string `json:"message"`
+	} `json:"events"`
+	Clients []struct {
+		ID  uint64
+		URL string
+	} `json:"clients"`
+}
+
+func TestDashboard(t *testing.T) {
+	Convey("Given a running server with the dashboard enabled", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		So(srv.EnableDashboard(), ShouldBeNil)
+		So(srv.EnableDashboard(), ShouldNotBeNil)
+		base := fmt.Sprintf("%s:%d/livereload/ui", localhost, srv.Port())
+
+		Convey("the page should be served", func() {
+			body := getBody(t, "http"+base)
+			So(body, ShouldContainSubstring, "<title>"+srv.Name()+" dashboard</title>")
+			So(body, ShouldContainSubstring, `socketPath = "/livereload/ui/ws";`)
+		})
+
+		Convey("its socket should stream clients and events", func() {
+			dash, _, err := websocket.DefaultDialer.Dial("ws"+base+"/ws", nil)
+			So(err, ShouldBeNil)
+			defer dash.Close()
+			read := func() *dashboardUpdate {
+				u := new(dashboardUpdate)
+				dash.SetReadDeadline(time.Now().Add(time.Second))
+				So(dash.ReadJSON(u), ShouldBeNil)
+				return u
+			}
+			So(read().Clients, ShouldBeEmpty)
+
+			conn := connect(t, srv)
+			defer conn.Close()
+			u := read()
+			for len(u.Clients) == 0 {
+				u = read()
+			}
+			So(u.Clients, ShouldHaveLength, 1)
+
+			Convey("and reload clients on command", func() {
+				So(dash.WriteJSON(map[string]string{"cmd": "reload", "path": "css/main.css"}), ShouldBeNil)
+				sr, err := readReload(conn)
+				So(err, ShouldBeNil)
+				So(sr.Path, ShouldEqual, "css/main.css")
+
+				var reloaded bool
+				for !reloaded {
+					for _, e := range read().Events {
+						reloaded = reloaded || e.Type == "reload" && e.Path == "css/main.css"
+					}
+				}
+				So(reloaded, ShouldBeTrue)
+			})
+		})
+
+		Convey("pages from other origins should not be able to connect", func() {
+			_, resp, err := websocket.DefaultDialer.Dial("ws"+base+"/ws", http.Header{"Origin": {"http://evil.example"}})
+			So(err, ShouldNotBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("with an auth token set", func() {
+			So(srv.SetAuthToken("s3cret"), ShouldBeNil)
+
+			Convey("the page should require it, and pass it on to its socket", func() {
+				resp, err := http.Get("http" + base)
+				So(err, ShouldBeNil)
+				resp.Body.Close()
+				So(resp.StatusCode, ShouldEqual, http.StatusUnauthorized)
+				So(getBody(t, "http"+base+"?token=s3cret"), ShouldContainSubstring, `socketPath = "/livereload/ui/ws?token=s3cret";`)
+			})
+
+			Convey("tokenless sockets should be refused", func() {
+				_, resp, err := websocket.DefaultDialer.Dial("ws"+base+"/ws", nil)
+				So(err, ShouldNotBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusUnauthorized)
+
+				dash, _, err := websocket.DefaultDialer.Dial("ws"+base+"/ws?token=s3cret", nil)
+				So(err, ShouldBeNil)
+				dash.Close()
+			})
+		})
+
+		Convey("shutting down should close open dashboards rather than wait on them", func() {
+			dash, _, err := websocket.DefaultDialer.Dial("ws"+base+"/ws", nil)
+			So(err, ShouldBeNil)
+			defer dash.Close()
+			dash.SetReadDeadline(time.Now().Add(time.Second))
+			So(dash.ReadJSON(new(dashboardUpdate)), ShouldBeNil)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			So(srv.Shutdown(ctx), ShouldBeNil)
+			_, _, err = dash.ReadMessage()
+			So(websocket.IsCloseError(err, websocket.CloseGoingAway), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,176 @@
+package lrserver
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDebounceMaxWait is the longest a burst of changes is held by
+// default
+const DefaultDebounceMaxWait = 2 * time.Second
+
+// debouncer collects the paths reloaded during a burst of changes
+type debouncer struct {
+	mu      sync.Mutex
+	paths   []string
+	timer   Timer
+	started time.Time
+}
+
+// SetDebounce coalesces reloads requested within d of each other, so a
+// burst of file events from an editor or build tool sends each client a
+// single message once it settles. If only stylesheets changed, each is
+// reloaded in place; otherwise one page reload covers everything. Zero,
+// the default, sends every reload straight away.
+func (s *Server) SetDebounce(d time.Duration) {
+	s.update(func(cfg *settings) { cfg.debounce = d })
+}
+
+// Debounce gets the window reloads are coalesced within
+func (s *Server) Debounce() time.Duration {
+	return s.settings().debounce
+}
+
+// SetDebounceMaxWait caps how long a burst of changes is held while the
+// debounce window keeps restarting, so a steady stream of them, such as
+// from a watched log or a bundler's output, can't put the reload off
+// forever. Once d has passed since the burst's first change it's sent
+// regardless. It's DefaultDebounceMaxWait by default; zero or less lifts
+// the cap.
+func (s *Server) SetDebounceMaxWait(d time.Duration) {
+	s.update(func(cfg *settings) { cfg.debounceMaxWait = d })
+}
+
+// DebounceMaxWait gets the longest a burst of changes is held
+func (s *Server) DebounceMaxWait() time.Duration {
+	return s.settings().debounceMaxWait
+}
+
+// SetReloadDelay delays every reload by d, for site generators that
+// finish writing files slightly after the change is seen. Build commands
+// run once the delay is over, and any debounce window comes first.
+// ReloadOptions.Delay overrides it for a single reload. Zero, the
+// default, sends reloads straight away.
+func (s *Server) SetReloadDelay(d time.Duration) {
+	s.update(func(cfg *settings) { cfg.reloadDelay = d })
+}
+
+// ReloadDelay gets how long reloads are delayed
+func (s *Server) ReloadDelay() time.Duration {
+	return s.settings().reloadDelay
+}
+
+// ReloadAll reloads a set of changed files, such as a build's change
+// set, with as few messages as possible: a single page reload if any of
+// them isn't a stylesheet, since that covers the rest, and otherwise a
+// live reload of each stylesheet. If a debounce window is set, the files
+// join the current burst instead.
+func (s *Server) ReloadAll(files ...string) {
+	if len(files) == 0 {
+		return
+	}
+	if d := s.Debounce(); d > 0 {
+		s.debounceReload(d, files...)
+		return
+	}
+	s.reloadBatch(files)
+}
+
+// debounceReload adds files to the current burst, restarting its window
+// unless that would hold it past the maximum wait
+func (s *Server) debounceReload(d time.Duration, files ...string) {
+	now := s.now()
+	s.debounced.mu.Lock()
+	defer s.debounced.mu.Unlock()
+	if len(s.debounced.paths) == 0 {
+		s.debounced.started = now
+	}
+	if max := s.DebounceMaxWait(); max > 0 {
+		if left := s.debounced.started.Add(max).Sub(now); left < d {
+			d = left
+		}
+		if d < 0 {
+			d = 0
+		}
+	}
+	for _, file := range files {
+		if !containsString(s.debounced.paths, file) {
+			s.debounced.paths = append(s.debounced.paths, file)
+		}
+	}
+	if s.debounced.timer != nil {
+		s.debounced.timer.Stop()
+	}
+	s.debounced.timer = s.Clock().AfterFunc(d, s.flushDebounced)
+}
+
+// flushDebounced reloads the paths collected during the burst
+func (s *Server) flushDebounced() {
+	s.debounced.mu.Lock()
+	paths := s.debounced.paths
+	s.debounced.paths, s.debounced.timer = nil, nil
+	s.debounced.mu.Unlock()
+
+	s.reloadBatch(paths)
+}
+
+// reloadBatch reloads a set of changed files with as few messages as
+// possible: one page reload if any of them can't be refreshed in place,
+// which subsumes the rest, and otherwise one live reload per file
+func (s *Server) reloadBatch(files []string) {
+	if files = s.ignoredByPolicy(files); len(files) == 0 {
+		return
+	}
+	if s.holdPaused(files...) {
+		return
+	}
+	if d := s.ReloadDelay(); d > 0 {
+		s.Clock().AfterFunc(d, func() { s.sendBatch(files) })
+		return
+	}
+	s.sendBatch(files)
+}
+
+// sendBatch reloads a set of files for reloadBatch, straight away
+func (s *Server) sendBatch(files []string) {
+	if !s.runBuildHook(files) {
+		return
+	}
+	var targets []string
+	for _, file := range files {
+		if !s.runCommands(file) {
+			continue
+		}
+		for _, target := range s.reloadTargets(file) {
+			if !containsString(targets, target) {
+				targets = append(targets, target)
+			}
+		}
+	}
+
+	for _, target := range targets {
+		if !s.refreshedInPlace(target) {
+			s.reload(nil, target, ReloadOptions{})
+			return
+		}
+	}
+	for _, target := range targets {
+		s.reload(nil, target, ReloadOptions{})
+	}
+}
+
+// isStylesheet reports whether file is reloaded in place by live CSS
+func isStylesheet(file string) bool {
+	return strings.EqualFold(path.Ext(file), ".css")
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package lrserver_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDebounce(t *testing.T) {
+	Convey("Given a running server debouncing reloads and a connected websocket", t, func() {
+		srv := startServer(t)
+		clock := lrserver.NewManualClock(time.Now())
+		srv.SetClock(clock)
+		srv.SetKeepalive(0, 0)
+		srv.SetDebounce(100 * time.Millisecond)
+		conn := connect(t, srv)
+		defer conn.Close()
+
+		Convey("a burst of stylesheet changes should reload each stylesheet once", func() {
+			srv.Reload("css/a.css")
+			clock.Advance(50 * time.Millisecond)
+			srv.Reload("css/b.css")
+			srv.Reload("css/a.css")
+			clock.Advance(50 * time.Millisecond)
+			So(clock.Pending(), ShouldEqual, 1)
+			clock.Advance(50 * time.Millisecond)
+
+			for _, path := range []string{"css/a.css", "css/b.css"} {
+				sr, err := readReload(conn)
+				So(err, ShouldBeNil)
+				So(sr.Path, ShouldEqual, path)
+			}
+		})
+
+		Convey("a burst including other files should send a single page reload", func() {
+			srv.Reload("css/a.css")
+			srv.Reload("index.html")
+			srv.Reload("js/app.js")
+			clock.Advance(100 * time.Millisecond)
+
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "index.html")
+
+			srv.SetDebounce(0)
+			srv.Reload("after.html")
+			sr, err = readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "after.html")
+		})
+
+		Convey("a steady stream of changes should be sent once the maximum wait is up", func() {
+			srv.SetDebounceMaxWait(250 * time.Millisecond)
+			So(srv.DebounceMaxWait(), ShouldEqual, 250*time.Millisecond)
+			for i := 0; i < 4; i++ {
+				srv.Reload("logs/app.log")
+				clock.Advance(50 * time.Millisecond)
+			}
+			srv.Reload("logs/app.log")
+			So(clock.Pending(), ShouldEqual, 1)
+			clock.Advance(50 * time.Millisecond)
+
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "logs/app.log")
+		})
+	})
+}
+
+func TestReloadAll(t *testing.T) {
+	Convey("Given a running server and a connected websocket", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		conn := connect(t, srv)
+		defer conn.Close()
+
+		Convey("a batch of stylesheets should reload each in place", func() {
+			srv.ReloadAll("css/a.css", "css/b.css", "css/a.css")
+			for _, path := range []string{"css/a.css", "css/b.css"} {
+				sr, err := readReload(conn)
+				So(err, ShouldBeNil)
+				So(sr.Path, ShouldEqual, path)
+				So(sr.LiveCSS, ShouldBeTrue)
+			}
+		})
+
+		Convey("a batch including other files should send a single page reload", func() {
+			srv.ReloadAll("css/a.css", "index.html", "js/app.js")
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "index.html")
+
+			srv.Reload("after.html")
+			sr, err = readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "after.html")
+		})
+	})
+}
+
+func TestReloadDelay(t *testing.T) {
+	Convey("Given a running server delaying reloads and a connected websocket", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		clock := lrserver.NewManualClock(time.Now())
+		srv.SetClock(clock)
+		srv.SetKeepalive(0, 0)
+		srv.SetReloadDelay(100 * time.Millisecond)
+		So(srv.ReloadDelay(), ShouldEqual, 100*time.Millisecond)
+		conn := connect(t, srv)
+		defer conn.Close()
+
+		Convey("reloads should wait out the delay", func() {
+			srv.Reload("index.html")
+			So(clock.Pending(), ShouldEqual, 1)
+			clock.Advance(100 * time.Millisecond)
+
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "index.html")
+		})
+
+		Convey("batches should wait out the delay", func() {
+			srv.ReloadAll("css/a.css", "css/b.css")
+			So(clock.Pending(), ShouldEqual, 1)
+			clock.Advance(100 * time.Millisecond)
+
+			for _, path := range []string{"css/a.css", "css/b.css"} {
+				sr, err := readReload(conn)
+				So(err, ShouldBeNil)
+				So(sr.Path, ShouldEqual, path)
+			}
+		})
+
+		Convey("a single reload's delay should override it", func() {
+			srv.ReloadWithOptions("late.html", lrserver.ReloadOptions{Delay: time.Second})
+			srv.ReloadWithOptions("now.html", lrserver.ReloadOptions{Delay: -1})
+
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "now.html")
+
+			clock.Advance(time.Second)
+			sr, err = readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "late.html")
+		})
+	})
+}
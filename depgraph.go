@@ -0,0 +1,389 @@
+package lrserver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ImportParser extracts the files a source file imports
+type ImportParser interface {
+	// Imports gets the filesystem paths of the existing files imported
+	// by the file at path, whose content is src
+	Imports(path string, src []byte) ([]string, error)
+}
+
+// ImportParserFunc adapts a function to the ImportParser interface
+type ImportParserFunc func(path string, src []byte) ([]string, error)
+
+// Imports calls f(path, src)
+func (f ImportParserFunc) Imports(path string, src []byte) ([]string, error) {
+	return f(path, src)
+}
+
+// DepGraph tracks which files import which, so that a change to a
+// partial or imported module can be traced back to the entry points
+// the browser actually loads
+type DepGraph struct {
+	mu         sync.RWMutex
+	parsers    map[string]ImportParser
+	imports    map[string]map[string]bool
+	importedBy map[string]map[string]bool
+}
+
+// NewDepGraph creates a dependency graph with parsers for Sass
+// (.scss, .sass), JavaScript modules (.js, .mjs, .jsx, .ts, .tsx) and
+// Go templates (.tmpl, .gohtml)
+func NewDepGraph() *DepGraph {
+	g := &DepGraph{
+		parsers:    make(map[string]ImportParser),
+		imports:    make(map[string]map[string]bool),
+		importedBy: make(map[string]map[string]bool),
+	}
+	for _, ext := range []string{".scss", ".sass"} {
+		g.parsers[ext] = SassParser
+	}
+	for _, ext := range []string{".js", ".mjs", ".jsx", ".ts", ".tsx"} {
+		g.parsers[ext] = JSParser
+	}
+	for _, ext := range []string{".tmpl", ".gohtml"} {
+		g.parsers[ext] = GoTemplateParser
+	}
+	return g
+}
+
+// SetParser sets the parser for files with extension ext,
+// or removes it if p is nil
+func (g *DepGraph) SetParser(ext string, p ImportParser) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if p == nil {
+		delete(g.parsers, ext)
+		return
+	}
+	g.parsers[ext] = p
+}
+
+// Scan parses every file under root that has a parser
+func (g *DepGraph) Scan(root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() && g.parser(path) != nil {
+			return g.Update(path)
+		}
+		return nil
+	})
+}
+
+// Update re-parses the imports of the file at path. Missing
+// files and files without a parser are removed from the graph.
+func (g *DepGraph) Update(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	p := g.parser(path)
+	if p == nil {
+		g.setImports(path, nil)
+		return nil
+	}
+	src, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		g.setImports(path, nil)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	imports, err := p.Imports(path, src)
+	if err != nil {
+		return err
+	}
+	g.setImports(path, imports)
+	return nil
+}
+
+// Remove forgets the imports of path. Files importing it keep
+// their edges, so a removed partial still maps to its parents.
+func (g *DepGraph) Remove(path string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		g.setImports(abs, nil)
+	}
+}
+
+// Dependents gets every file that imports path, directly or indirectly
+func (g *DepGraph) Dependents(path string) []string {
+	path, _ = filepath.Abs(path)
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	seen := map[string]bool{path: true}
+	var deps []string
+	queue := []string{path}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for importer := range g.importedBy[p] {
+			if !seen[importer] {
+				seen[importer] = true
+				deps = append(deps, importer)
+				queue = append(queue, importer)
+			}
+		}
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+// Entrypoints gets the files depending on path that aren't imported by
+// anything else, which are the ones the browser loads. A file that
+// nothing imports is its own entry point.
+func (g *DepGraph) Entrypoints(path string) []string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return []string{path}
+	}
+
+	deps := g.Dependents(abs)
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var entrypoints []string
+	for _, d := range deps {
+		if len(g.importedBy[d]) == 0 {
+			entrypoints = append(entrypoints, d)
+		}
+	}
+
+	// Import cycles have no entry point, so fall back to the file itself
+	if len(entrypoints) == 0 {
+		return []string{abs}
+	}
+	return entrypoints
+}
+
+func (g *DepGraph) parser(path string) ImportParser {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.parsers[strings.ToLower(filepath.Ext(path))]
+}
+
+func (g *DepGraph) setImports(path string, imports []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for old := range g.imports[path] {
+		delete(g.importedBy[old], path)
+		if len(g.importedBy[old]) == 0 {
+			delete(g.importedBy, old)
+		}
+	}
+	delete(g.imports, path)
+
+	if len(imports) == 0 {
+		return
+	}
+	set := make(map[string]bool, len(imports))
+	for _, imp := range imports {
+		if imp == path {
+			continue
+		}
+		set[imp] = true
+		if g.importedBy[imp] == nil {
+			g.importedBy[imp] = make(map[string]bool)
+		}
+		g.importedBy[imp][path] = true
+	}
+	g.imports[path] = set
+}
+
+// resolve finds the first of candidates relative to dir that exists
+func resolve(dir string, candidates ...string) (string, bool) {
+	for _, c := range candidates {
+		p := c
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, filepath.FromSlash(c))
+		}
+		if info, err := os.Stat(p); err == nil && info.Mode().IsRegular() {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+var (
+	sassImport = regexp.MustCompile(`@(?:use|forward|import)\s+((?:(?:"[^"]*"|'[^']*')\s*,?\s*)+)`)
+	sassString = regexp.MustCompile(`"([^"]*)"|'([^']*)'`)
+)
+
+// SassParser parses Sass @use, @forward and @import rules, resolving
+// partials (_name.scss), index files and both syntaxes' extensions
+var SassParser ImportParser = ImportParserFunc(func(path string, src []byte) ([]string, error) {
+	dir := filepath.Dir(path)
+	var imports []string
+	for _, m := range sassImport.FindAllSubmatch(src, -1) {
+		for _, s := range sassString.FindAllSubmatch(m[1], -1) {
+			name := string(s[1]) + string(s[2])
+
+			// Skip built-in modules, remote and plain CSS imports
+			if strings.Contains(name, ":") || strings.HasPrefix(name, "//") {
+				continue
+			}
+
+			d, base := filepath.Split(filepath.FromSlash(name))
+			var candidates []string
+			for _, ext := range []string{"", ".scss", ".sass", ".css"} {
+				if ext == "" && filepath.Ext(base) == "" {
+					continue
+				}
+				candidates = append(candidates,
+					filepath.Join(d, base+ext),
+					filepath.Join(d, "_"+base+ext),
+				)
+			}
+			for _, ext := range []string{".scss", ".sass"} {
+				candidates = append(candidates,
+					filepath.Join(d, base, "_index"+ext),
+					filepath.Join(d, base, "index"+ext),
+				)
+			}
+			if p, ok := resolve(dir, candidates...); ok {
+				imports = append(imports, p)
+			}
+		}
+	}
+	return imports, nil
+})
+
+var jsImport = regexp.MustCompile(`(?:\bimport\s*(?:[\w*{}\s,$]+\s*from\s*)?|\bexport\s*[\w*{}\s,$]*\s*from\s*|\bimport\s*\(\s*|\brequire\s*\(\s*)["']([^"']+)["']`)
+
+// JSParser parses JavaScript and TypeScript import and export
+// statements, dynamic imports and require calls. Only relative
+// specifiers are resolved; packages are ignored.
+var JSParser ImportParser = ImportParserFunc(func(path string, src []byte) ([]string, error) {
+	dir := filepath.Dir(path)
+	var imports []string
+	for _, m := range jsImport.FindAllSubmatch(src, -1) {
+		spec := string(m[1])
+		if !strings.HasPrefix(spec, "./") && !strings.HasPrefix(spec, "../") {
+			continue
+		}
+
+		var candidates []string
+		for _, ext := range []string{"", ".js", ".mjs", ".jsx", ".ts", ".tsx"} {
+			candidates = append(candidates, spec+ext)
+		}
+		for _, ext := range []string{".js", ".ts"} {
+			candidates = append(candidates, spec+"/index"+ext)
+		}
+		if p, ok := resolve(dir, candidates...); ok {
+			imports = append(imports, p)
+		}
+	}
+	return imports, nil
+})
+
+var (
+	tmplInclude = regexp.MustCompile(`\{\{-?\s*(?:template|block)\s+"([^"]+)"`)
+	tmplDefine  = regexp.MustCompile(`\{\{-?\s*(?:define|block)\s+"([^"]+)"`)
+)
+
+// GoTemplateParser parses html/template and text/template
+// {{template}} and {{block}} actions. A template name resolves to the
+// file of that name in the same directory, as with ParseFiles, or
+// otherwise to the sibling .tmpl, .gohtml and .html files that
+// {{define}} it.
+var GoTemplateParser = NewGoTemplateParser(".tmpl", ".gohtml", ".html")
+
+// NewGoTemplateParser creates a parser like GoTemplateParser that
+// searches sibling files with the given extensions for {{define}}
+// actions
+func NewGoTemplateParser(exts ...string) ImportParser {
+	defineExts := make(map[string]bool, len(exts))
+	for _, ext := range exts {
+		defineExts[strings.ToLower(ext)] = true
+	}
+	return ImportParserFunc(func(path string, src []byte) ([]string, error) {
+		return goTemplateImports(path, src, defineExts)
+	})
+}
+
+// goTemplateImports gets the templates included by src, looking for
+// {{define}} actions in siblings with extensions in defineExts
+func goTemplateImports(path string, src []byte, defineExts map[string]bool) ([]string, error) {
+	dir := filepath.Dir(path)
+	var defines map[string][]string
+	var imports []string
+	for _, m := range tmplInclude.FindAllSubmatch(src, -1) {
+		name := string(m[1])
+		if p, ok := resolve(dir, name); ok {
+			imports = append(imports, p)
+			continue
+		}
+
+		// Index the definitions in sibling files on first use
+		if defines == nil {
+			defines = make(map[string][]string)
+			siblings, err := ioutil.ReadDir(dir)
+			if err != nil {
+				return nil, err
+			}
+			for _, info := range siblings {
+				sibling := filepath.Join(dir, info.Name())
+				if !info.Mode().IsRegular() || sibling == path {
+					continue
+				}
+				if !defineExts[strings.ToLower(filepath.Ext(sibling))] {
+					continue
+				}
+				content, err := ioutil.ReadFile(sibling)
+				if err != nil {
+					continue
+				}
+				for _, d := range tmplDefine.FindAllSubmatch(content, -1) {
+					defines[string(d[1])] = append(defines[string(d[1])], sibling)
+				}
+			}
+		}
+		imports = append(imports, defines[name]...)
+	}
+	return imports, nil
+}
+
+// DepGraph gets the dependency graph used to trace reloaded paths
+// back to their entry points, or nil if there isn't one
+func (s *Server) DepGraph() *DepGraph {
+	return s.settings().depGraph
+}
+
+// SetDepGraph sets a dependency graph through which reloaded
+// filesystem paths are traced back to the entry points that import
+// them, which are reloaded instead. Files are re-parsed as they're
+// reloaded, keeping the graph current.
+func (s *Server) SetDepGraph(g *DepGraph) {
+	s.update(func(cfg *settings) { cfg.depGraph = g })
+}
+
+// reloadTargets gets the paths to reload when file changes
+func (s *Server) reloadTargets(file string) []string {
+	if !filepath.IsAbs(file) {
+		return []string{file}
+	}
+	g := s.settings().depGraph
+	if g == nil {
+		return s.mapSources([]string{file})
+	}
+	if err := g.Update(file); err != nil {
+		s.logError(err)
+	}
+	return s.mapSources(g.Entrypoints(file))
+}
@@ -0,0 +1,134 @@
+package lrserver_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// writeFiles creates files under dir from a map of slash-separated
+// relative paths to contents
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(p), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(p, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDepGraph(t *testing.T) {
+	Convey("Given a scanned dependency graph", t, func() {
+		dir, err := ioutil.TempDir("", "lrserver")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		dir, err = filepath.EvalSymlinks(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		writeFiles(t, dir, map[string]string{
+			"scss/main.scss":                `@use "sass:math"; @use "components"; @import 'vars', "mixins";`,
+			"scss/_vars.scss":               `$red: #f00;`,
+			"scss/_mixins.scss":             `@forward "vars";`,
+			"scss/components/_index.scss":   `@use "buttons";`,
+			"scss/components/_buttons.scss": `.btn { color: red; }`,
+			"js/app.js":                     "import { util } from './lib/util';\nimport './side-effect.mjs';\nimport React from 'react';\nconst lazy = import(\"./lazy\");",
+			"js/lib/util.ts":                "export * from '../shared/index.js';",
+			"js/shared/index.js":            "export const x = 1;",
+			"js/side-effect.mjs":            "",
+			"js/lazy.jsx":                   "",
+			"templates/page.tmpl":           `{{template "header.tmpl" .}}{{template "footer" .}}`,
+			"templates/header.tmpl":         `<header></header>`,
+			"templates/partials.tmpl":       `{{define "footer"}}<footer></footer>{{end}}`,
+		})
+
+		g := lrserver.NewDepGraph()
+		err = g.Scan(dir)
+		So(err, ShouldBeNil)
+
+		p := func(name string) string {
+			return filepath.Join(dir, filepath.FromSlash(name))
+		}
+
+		Convey("Sass partials should map to the stylesheets importing them", func() {
+			So(g.Entrypoints(p("scss/components/_buttons.scss")), ShouldResemble, []string{p("scss/main.scss")})
+			So(g.Entrypoints(p("scss/_vars.scss")), ShouldResemble, []string{p("scss/main.scss")})
+			So(g.Dependents(p("scss/_vars.scss")), ShouldResemble, []string{p("scss/_mixins.scss"), p("scss/main.scss")})
+		})
+
+		Convey("JS modules should map to the entry points importing them", func() {
+			So(g.Entrypoints(p("js/shared/index.js")), ShouldResemble, []string{p("js/app.js")})
+			So(g.Entrypoints(p("js/lazy.jsx")), ShouldResemble, []string{p("js/app.js")})
+			So(g.Entrypoints(p("js/side-effect.mjs")), ShouldResemble, []string{p("js/app.js")})
+		})
+
+		Convey("Go templates should map to the pages including them", func() {
+			So(g.Entrypoints(p("templates/header.tmpl")), ShouldResemble, []string{p("templates/page.tmpl")})
+			So(g.Entrypoints(p("templates/partials.tmpl")), ShouldResemble, []string{p("templates/page.tmpl")})
+		})
+
+		Convey("Go template definitions should be searched for by extension", func() {
+			writeFiles(t, dir, map[string]string{"templates/partials.tpl": `{{define "nav"}}<nav></nav>{{end}}`})
+			page := p("templates/page.tmpl")
+			src := []byte(`{{template "nav" .}}`)
+
+			imports, err := lrserver.GoTemplateParser.Imports(page, src)
+			So(err, ShouldBeNil)
+			So(imports, ShouldBeEmpty)
+
+			imports, err = lrserver.NewGoTemplateParser(".TPL").Imports(page, src)
+			So(err, ShouldBeNil)
+			So(imports, ShouldResemble, []string{p("templates/partials.tpl")})
+		})
+
+		Convey("files nothing imports should be their own entry point", func() {
+			So(g.Entrypoints(p("scss/main.scss")), ShouldResemble, []string{p("scss/main.scss")})
+		})
+
+		Convey("updating a file should replace its imports", func() {
+			writeFiles(t, dir, map[string]string{"scss/main.scss": `@use "vars";`})
+			err := g.Update(p("scss/main.scss"))
+			So(err, ShouldBeNil)
+			So(g.Entrypoints(p("scss/components/_buttons.scss")), ShouldResemble, []string{p("scss/components/_index.scss")})
+		})
+
+		Convey("a server using it should reload entry points", func() {
+			srv := startServer(t)
+			srv.SetWebRoot(dir)
+			srv.SetDepGraph(g)
+			conn := connect(t, srv)
+			defer conn.Close()
+
+			srv.Reload(p("scss/_vars.scss"))
+
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "/scss/main.scss")
+		})
+
+		Convey("custom parsers should be used", func() {
+			writeFiles(t, dir, map[string]string{"page.txt": "", "include.txt": ""})
+			g.SetParser(".txt", lrserver.ImportParserFunc(func(path string, src []byte) ([]string, error) {
+				if filepath.Base(path) == "page.txt" {
+					return []string{p("include.txt")}, nil
+				}
+				return nil, nil
+			}))
+			err := g.Scan(dir)
+			So(err, ShouldBeNil)
+			So(g.Entrypoints(p("include.txt")), ShouldResemble, []string{p("page.txt")})
+		})
+	})
+}
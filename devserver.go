@@ -0,0 +1,165 @@
+package lrserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sync/atomic"
+)
+
+// DevConfig configures a DevServer
+type DevConfig struct {
+	// Name is the LiveReload server name, DefaultName if empty
+	Name string
+
+	// Addr is the address the site is served on, such as ":8080"
+	Addr string
+
+	// Dir is a directory of static files to serve. Either it or
+	// ProxyURL must be set.
+	Dir string
+
+	// ProxyURL is the URL of an application to serve through a
+	// reverse proxy, such as http://localhost:3000
+	ProxyURL string
+
+	// Watch lists the directories whose changes reload the browser.
+	// It defaults to Dir.
+	Watch []string
+
+	// Command is a process to run alongside the server, such as the
+	// application behind ProxyURL. It's stopped when the server is.
+	Command []string
+}
+
+// DevServer serves a directory or proxies an application, injecting the
+// LiveReload client into its pages, and reloads the browser when watched
+// files change. It covers the common case of a LiveReload Server in a
+// few lines; the Server it composes stays available for anything else.
+type DevServer struct {
+	cfg  DevConfig
+	lr   *Server
+	site http.Handler
+	addr atomic.Value
+}
+
+// NewDevServer creates a DevServer from cfg
+func NewDevServer(cfg DevConfig) (*DevServer, error) {
+	if (cfg.Dir == "") == (cfg.ProxyURL == "") {
+		return nil, errors.New("lrserver: exactly one of Dir and ProxyURL must be set")
+	}
+	if cfg.Name == "" {
+		cfg.Name = DefaultName
+	}
+	if len(cfg.Watch) == 0 && cfg.Dir != "" {
+		cfg.Watch = []string{cfg.Dir}
+	}
+
+	lr, err := New(cfg.Name, DefaultHost, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var site http.Handler
+	if cfg.Dir != "" {
+		site = injectScript(lr, http.FileServer(http.Dir(cfg.Dir)))
+	} else {
+		target, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, err
+		}
+		site = newInjectingProxy(lr, target)
+	}
+	return &DevServer{cfg: cfg, lr: lr, site: site}, nil
+}
+
+// LiveReload gets the LiveReload server, to configure or trigger directly
+func (d *DevServer) LiveReload() *Server {
+	return d.lr
+}
+
+// Addr gets the address the site is being served on,
+// or an empty string if it isn't yet
+func (d *DevServer) Addr() string {
+	addr, _ := d.addr.Load().(string)
+	return addr
+}
+
+// Run serves the site until ctx is done, returning nil when it shuts
+// down cleanly. It also returns if Command exits.
+func (d *DevServer) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	mux := http.NewServeMux()
+	Attach(mux, d.lr)
+	mux.Handle("/", d.site)
+	server := &http.Server{Handler: limitBodies(d.lr, mux), ErrorLog: d.lr.server.ErrorLog}
+
+	l, err := net.Listen("tcp", d.cfg.Addr)
+	if err != nil {
+		return err
+	}
+
+	// Watch
+	for _, dir := range d.cfg.Watch {
+		src, err := d.lr.newDirSource(dir, nil, true)
+		if err != nil {
+			l.Close()
+			return err
+		}
+		defer src.Close()
+		d.lr.AddEventSource(src)
+	}
+
+	// Run the command
+	errChan := make(chan error, 2)
+	if len(d.cfg.Command) > 0 {
+		cmd := exec.CommandContext(ctx, d.cfg.Command[0], d.cfg.Command[1:]...)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Start(); err != nil {
+			l.Close()
+			return err
+		}
+		go func() {
+			err := cmd.Wait()
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				err = errors.New("exited")
+			}
+			errChan <- fmt.Errorf("lrserver: %s: %v", d.cfg.Command[0], err)
+		}()
+	}
+
+	// Serve
+	d.addr.Store(l.Addr().String())
+	defer d.addr.Store("")
+	d.lr.logStatus("serving on http://" + l.Addr().String())
+	go func() {
+		errChan <- server.Serve(l)
+	}()
+
+	select {
+	case err = <-errChan:
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), DefaultGracePeriod)
+	defer cancel()
+	// Close the LiveReload clients first, as SSE clients hold open
+	// requests the site's listener would wait for
+	shutdownErr := d.lr.Shutdown(shutdownCtx)
+	if siteErr := server.Shutdown(shutdownCtx); shutdownErr == nil {
+		shutdownErr = siteErr
+	}
+	if err == nil {
+		err = shutdownErr
+	}
+	return err
+}
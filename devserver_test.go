@@ -0,0 +1,129 @@
+package lrserver_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// runDevServer starts d, returning its address and a function stopping it
+func runDevServer(t *testing.T, d *lrserver.DevServer) (string, func() error) {
+	d.LiveReload().SetStatusLog(nil)
+	d.LiveReload().SetErrorLog(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- d.Run(ctx)
+	}()
+	for i := 0; d.Addr() == ""; i++ {
+		if i == 100 {
+			t.Fatal("dev server did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return d.Addr(), func() error {
+		cancel()
+		return <-done
+	}
+}
+
+// getBody fetches url's body
+func getBody(t *testing.T, url string) string {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestDevServer(t *testing.T) {
+	Convey("Given a dev server for a directory", t, func() {
+		dir, err := ioutil.TempDir("", "lrserver")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		writeFiles(t, dir, map[string]string{
+			"index.html":   "<html><body><h1>Hi</h1></body></html>",
+			"css/main.css": "body {}",
+		})
+
+		d, err := lrserver.NewDevServer(lrserver.DevConfig{
+			Addr: "127.0.0.1:0",
+			Dir:  dir,
+		})
+		So(err, ShouldBeNil)
+		addr, stop := runDevServer(t, d)
+
+		Convey("pages should be served with the client injected", func() {
+			body := getBody(t, "http://"+addr+"/")
+			So(body, ShouldEqual, `<html><body><h1>Hi</h1><script src="/livereload.js"></script></body></html>`)
+			So(getBody(t, "http://"+addr+"/css/main.css"), ShouldEqual, "body {}")
+			So(stop(), ShouldBeNil)
+		})
+
+		Convey("changed files should reload connected pages", func() {
+			conn, _, err := new(websocket.Dialer).Dial("ws://"+addr+"/livereload", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			So(conn.ReadJSON(new(serverHello)), ShouldBeNil)
+			So(conn.WriteJSON(clientHello), ShouldBeNil)
+			time.Sleep(10 * time.Millisecond)
+
+			err = ioutil.WriteFile(filepath.Join(dir, "css", "main.css"), []byte("body { color: red }"), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "css/main.css")
+			So(stop(), ShouldBeNil)
+		})
+	})
+
+	Convey("Given a dev server proxying an application", t, func() {
+		app := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(rw, "<html><head></head><body>"+req.URL.Path+"</body></html>")
+		}))
+		defer app.Close()
+
+		d, err := lrserver.NewDevServer(lrserver.DevConfig{
+			Addr:     "127.0.0.1:0",
+			ProxyURL: app.URL,
+		})
+		So(err, ShouldBeNil)
+		addr, stop := runDevServer(t, d)
+		defer stop()
+
+		Convey("proxied pages should have the client injected", func() {
+			So(getBody(t, "http://"+addr+"/about"), ShouldEqual,
+				`<html><head></head><body>/about<script src="/livereload.js"></script></body></html>`)
+		})
+	})
+
+	Convey("A dev server needs exactly one of a directory and a proxy URL", t, func() {
+		_, err := lrserver.NewDevServer(lrserver.DevConfig{})
+		So(err, ShouldNotBeNil)
+		_, err = lrserver.NewDevServer(lrserver.DevConfig{Dir: ".", ProxyURL: "http://localhost:3000"})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,159 @@
+package lrserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// EventType is the kind of an Event
+type EventType string
+
+// Event types
+const (
+	EventListening    EventType = "listening"
+	EventConnected    EventType = "connected"
+	EventDisconnected EventType = "disconnected"
+	EventReload       EventType = "reload"
+	EventAlert        EventType = "alert"
+	EventDelivered    EventType = "delivered"
+	EventError        EventType = "error"
+
+	// EventHandshakeFailed is a client failing the websocket upgrade, or
+	// sending an invalid hello
+	EventHandshakeFailed EventType = "handshake-failed"
+
+	// EventDropped is messages discarded for a client that can't keep
+	// up, with their number in Count
+	EventDropped EventType = "dropped"
+)
+
+// eventBuffer is how many events a channel from Events buffers
+const eventBuffer = 256
+
+// Event is a machine-readable record of something the server did
+type Event struct {
+	Type    EventType `json:"event"`
+	Time    time.Time `json:"time"`
+	Addr    string    `json:"addr,omitempty"`
+	Remote  string    `json:"remote,omitempty"`
+	Command string    `json:"command,omitempty"`
+	Path    string    `json:"path,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Count   int       `json:"count,omitempty"`
+}
+
+// eventWriter encodes events as newline-delimited JSON
+type eventWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// SetEventWriter sets a writer receiving every event as a line of JSON,
+// so wrapping tools can follow what the server does without parsing the
+// status log. It can be set to nil.
+//
+//	{"event":"connected","time":"...","remote":"127.0.0.1"}
+//	{"event":"reload","time":"...","path":"css/main.css"}
+//	{"event":"delivered","time":"...","remote":"127.0.0.1","command":"reload","path":"css/main.css"}
+func (s *Server) SetEventWriter(w io.Writer) {
+	var ew *eventWriter
+	if w != nil {
+		ew = &eventWriter{enc: json.NewEncoder(w)}
+	}
+	s.update(func(cfg *settings) { cfg.eventWriter = ew })
+}
+
+// eventSubs are the channels returned by Events, by their
+// receive-only form
+type eventSubs struct {
+	mu     sync.Mutex
+	subs   map[<-chan Event]chan Event
+	closed bool
+}
+
+// Events gets a channel receiving every event from now on, so wrapping
+// tools can build their own UIs and metrics without parsing the status
+// log: EventConnected and EventDisconnected as clients come and go,
+// EventReload and EventAlert as they're broadcast, EventDelivered as
+// each client is sent them, EventHandshakeFailed and EventDropped. The
+// channel buffers a few hundred events; those arriving while it's full
+// are discarded rather than holding up the server. It's closed by
+// Unsubscribe, or once the server is closed or shut down.
+func (s *Server) Events() <-chan Event {
+	ch := make(chan Event, eventBuffer)
+	s.events.mu.Lock()
+	defer s.events.mu.Unlock()
+	if s.events.closed {
+		close(ch)
+		return ch
+	}
+	if s.events.subs == nil {
+		s.events.subs = make(map[<-chan Event]chan Event)
+	}
+	s.events.subs[ch] = ch
+	return ch
+}
+
+// Unsubscribe stops sending events to a channel from Events, and
+// closes it. Unsubscribing a channel more than once does nothing.
+func (s *Server) Unsubscribe(events <-chan Event) {
+	s.events.mu.Lock()
+	defer s.events.mu.Unlock()
+	if ch, ok := s.events.subs[events]; ok {
+		delete(s.events.subs, events)
+		close(ch)
+	}
+}
+
+// publish sends e to every channel with room for it
+func (es *eventSubs) publish(e Event) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	for _, ch := range es.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// close closes every channel
+func (es *eventSubs) close() {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if es.closed {
+		return
+	}
+	es.closed = true
+	for _, ch := range es.subs {
+		close(ch)
+	}
+	es.subs = nil
+}
+
+// emit records an event
+func (s *Server) emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = s.now()
+	}
+	s.stats.count(e)
+	s.record(e)
+	s.dash.publish(e)
+	s.events.publish(e)
+	ew := s.settings().eventWriter
+	if ew == nil {
+		return
+	}
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+	ew.enc.Encode(e)
+}
+
+// emitError records an error event
+func (s *Server) emitError(msg ...interface{}) {
+	s.emit(Event{Type: EventError, Error: fmt.Sprint(msg...)})
+}
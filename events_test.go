@@ -0,0 +1,158 @@
+package lrserver_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEventWriter(t *testing.T) {
+	Convey("Given a running server writing events", t, func() {
+		srv := startServer(t)
+		r, w := io.Pipe()
+		defer r.Close()
+		srv.SetEventWriter(w)
+
+		events := make(chan lrserver.Event, 16)
+		go func() {
+			scanner := bufio.NewScanner(r)
+			for scanner.Scan() {
+				var e lrserver.Event
+				if json.Unmarshal(scanner.Bytes(), &e) == nil {
+					events <- e
+				}
+			}
+		}()
+		next := func() lrserver.Event {
+			select {
+			case e := <-events:
+				return e
+			case <-time.After(time.Second):
+				t.Fatal("no event received")
+			}
+			return lrserver.Event{}
+		}
+
+		Convey("connections, reloads and deliveries should be recorded", func() {
+			conn := connect(t, srv)
+
+			e := next()
+			So(e.Type, ShouldEqual, lrserver.EventConnected)
+			So(e.Remote, ShouldEqual, "127.0.0.1")
+
+			srv.Reload("css/main.css")
+			e = next()
+			So(e.Type, ShouldEqual, lrserver.EventReload)
+			So(e.Path, ShouldEqual, "css/main.css")
+
+			_, err := readReload(conn)
+			So(err, ShouldBeNil)
+			e = next()
+			So(e.Type, ShouldEqual, lrserver.EventDelivered)
+			So(e.Command, ShouldEqual, "reload")
+			So(e.Path, ShouldEqual, "css/main.css")
+			So(e.Time.IsZero(), ShouldBeFalse)
+
+			conn.Close()
+			var types []lrserver.EventType
+			for len(types) < 2 {
+				types = append(types, next().Type)
+			}
+			So(types, ShouldContain, lrserver.EventError)
+			So(types, ShouldContain, lrserver.EventDisconnected)
+		})
+	})
+}
+
+func TestEvents(t *testing.T) {
+	Convey("Given a running server and a channel of its events", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		events := srv.Events()
+		defer srv.Unsubscribe(events)
+		next := func() lrserver.Event {
+			select {
+			case e := <-events:
+				return e
+			case <-time.After(time.Second):
+				t.Fatal("no event received")
+			}
+			return lrserver.Event{}
+		}
+
+		Convey("connections, reloads and alerts should be sent", func() {
+			conn := connect(t, srv)
+			defer conn.Close()
+			So(next().Type, ShouldEqual, lrserver.EventConnected)
+
+			srv.Reload("css/main.css")
+			e := next()
+			So(e.Type, ShouldEqual, lrserver.EventReload)
+			So(e.Path, ShouldEqual, "css/main.css")
+			So(next().Type, ShouldEqual, lrserver.EventDelivered)
+
+			srv.Alert("build failed")
+			e = next()
+			So(e.Type, ShouldEqual, lrserver.EventAlert)
+			So(e.Message, ShouldEqual, "build failed")
+		})
+
+		Convey("invalid hellos should be sent as failed handshakes", func() {
+			conn, _ := dial(t, srv, nil)
+			defer conn.Close()
+			So(conn.WriteJSON(map[string]string{"command": "hello"}), ShouldBeNil)
+
+			e := next()
+			So(e.Type, ShouldEqual, lrserver.EventHandshakeFailed)
+			So(e.Remote, ShouldEqual, "127.0.0.1")
+		})
+
+		Convey("messages dropped for a client should be counted", func() {
+			clock := lrserver.NewManualClock(time.Now())
+			srv.SetClock(clock)
+			srv.SetKeepalive(0, 0)
+			srv.SetRateLimit(lrserver.RateLimit{PerSecond: 1, Burst: 1})
+			conn := connect(t, srv)
+			defer conn.Close()
+			next()
+
+			srv.Reload("css/a.css")
+			srv.Reload("css/b.css")
+			srv.Reload("css/c.css")
+			clock.Advance(time.Second)
+			for {
+				if e := next(); e.Type == lrserver.EventDropped {
+					So(e.Remote, ShouldEqual, "127.0.0.1")
+					So(e.Count, ShouldEqual, 2)
+					break
+				}
+			}
+		})
+
+		Convey("closing the server should close the channel", func() {
+			srv.Close()
+			_, ok := <-events
+			So(ok, ShouldBeFalse)
+			later := srv.Events()
+			defer srv.Unsubscribe(later)
+			_, ok = <-later
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("unsubscribing should close the channel and stop sending to it", func() {
+			other := srv.Events()
+			srv.Unsubscribe(other)
+			srv.Unsubscribe(other)
+			_, ok := <-other
+			So(ok, ShouldBeFalse)
+
+			srv.Alert("still listening")
+			So(next().Message, ShouldEqual, "still listening")
+		})
+	})
+}
@@ -1,8 +1,10 @@
 package lrserver_test
 
 import (
+	"fmt"
 	"log"
 	"net/http"
+	"sync"
 
 	"github.com/jaschaephraim/lrserver"
 	"gopkg.in/fsnotify.v1"
@@ -34,7 +36,7 @@ func Example() {
 	}
 
 	// Start LiveReload server
-	lr, err := lrserver.New(lrserver.DefaultName, lrserver.DefaultPort)
+	lr, err := lrserver.New(lrserver.DefaultName, lrserver.DefaultHost, lrserver.DefaultPort)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -58,3 +60,106 @@ func Example() {
 	})
 	http.ListenAndServe(":3000", nil)
 }
+
+// pubSub is the part of a message bus client, such as a Redis or NATS
+// one, that a Broker needs
+type pubSub interface {
+	Publish(channel string, payload []byte) error
+	Subscribe(channel string) (messages <-chan []byte, unsubscribe func())
+}
+
+// busBroker adapts a pub/sub client to lrserver.Broker, sharing
+// broadcasts over one channel
+type busBroker struct {
+	bus     pubSub
+	channel string
+}
+
+func (b *busBroker) Publish(msg []byte) error {
+	return b.bus.Publish(b.channel, msg)
+}
+
+func (b *busBroker) Subscribe(f func(msg []byte)) (func(), error) {
+	messages, unsubscribe := b.bus.Subscribe(b.channel)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for msg := range messages {
+			f(msg)
+		}
+	}()
+	return func() {
+		unsubscribe()
+		<-done
+	}, nil
+}
+
+// fakeBus stands in for a Redis server. Like Redis pub/sub and NATS, it
+// delivers each message to every subscriber of the channel, the
+// publisher's own subscription included.
+type fakeBus struct {
+	mu   sync.Mutex
+	subs map[string][]chan []byte
+}
+
+func (fb *fakeBus) Publish(channel string, payload []byte) error {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	for _, ch := range fb.subs[channel] {
+		ch <- payload
+	}
+	return nil
+}
+
+func (fb *fakeBus) Subscribe(channel string) (<-chan []byte, func()) {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	if fb.subs == nil {
+		fb.subs = make(map[string][]chan []byte)
+	}
+	ch := make(chan []byte, 16)
+	fb.subs[channel] = append(fb.subs[channel], ch)
+	return ch, func() {
+		fb.mu.Lock()
+		defer fb.mu.Unlock()
+		subs := fb.subs[channel]
+		for i, sub := range subs {
+			if sub == ch {
+				fb.subs[channel] = append(subs[:i:i], subs[i+1:]...)
+				close(ch)
+				return
+			}
+		}
+	}
+}
+
+func ExampleBroker() {
+	broker := &busBroker{bus: new(fakeBus), channel: "livereload"}
+
+	// Publishers receive their own messages; servers skip their own
+	// broadcasts by the origin they tag them with
+	received := make(chan string, 1)
+	unsubscribe, err := broker.Subscribe(func(msg []byte) {
+		received <- string(msg)
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	broker.Publish([]byte("hello"))
+	fmt.Println(<-received)
+	unsubscribe()
+
+	// Reloading on either dev server, once serving, reloads the clients
+	// of both
+	for _, port := range []uint16{35729, 35730} {
+		lr, err := lrserver.NewServer(lrserver.WithPort(port))
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if err := lr.SetBroker(broker); err != nil {
+			log.Fatalln(err)
+		}
+		defer lr.SetBroker(nil)
+	}
+	// Output: hello
+}
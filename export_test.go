@@ -0,0 +1,92 @@
+package lrserver
+
+import "path/filepath"
+
+// SetNewFSNotifySource replaces the fsnotify source constructor,
+// returning a function that restores it
+func SetNewFSNotifySource(f func(string) (*FSNotifySource, error)) func() {
+	orig := newFSNotifySource
+	newFSNotifySource = func(root string, _ *ignoreRules, _ bool) (*FSNotifySource, error) {
+		return f(root)
+	}
+	return func() {
+		newFSNotifySource = orig
+	}
+}
+
+// WatchesRecursively reports whether the source Watch started for dir
+// watches the directories below it too
+func WatchesRecursively(s *Server, dir string) bool {
+	key, _ := filepath.Abs(dir)
+	s.watches.mu.Lock()
+	defer s.watches.mu.Unlock()
+	switch src := s.watches.srcs[key].(*filterSource).src.(type) {
+	case *FSNotifySource:
+		return src.recursive
+	case *PollingSource:
+		return src.recursive
+	}
+	return false
+}
+
+// WatchIgnores reports whether a watch of root with opts ignores rel,
+// a directory if dir
+func WatchIgnores(root, rel string, dir bool, opts ...WatchOption) (bool, error) {
+	var o watchOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	rules, err := newIgnoreRules(root, &o)
+	return rules.ignored(rel, dir), err
+}
+
+// SetOpenURL replaces how the browser is opened, returning a function
+// that restores it
+func SetOpenURL(f func(string) error) func() {
+	orig := openURL
+	openURL = f
+	return func() {
+		openURL = orig
+	}
+}
+
+// BrowserCommand gets the command opening u in the browser on goos
+func BrowserCommand(goos, u string) []string {
+	return browserCommand(goos, u).Args
+}
+
+// CacheScript encodes script through s's script cache
+func CacheScript(s *Server, script string) {
+	s.scripts.get(script)
+}
+
+// ScriptCached reports whether s's script cache holds script
+func ScriptCached(s *Server, script string) bool {
+	s.scripts.mu.Lock()
+	defer s.scripts.mu.Unlock()
+	_, ok := s.scripts.scripts[script]
+	return ok
+}
+
+// CSPViolations lists the CSP-hostile constructs found in src
+func CSPViolations(src string) []string {
+	return cspViolations(src)
+}
+
+// MDNSRecords encodes the mDNS records announcing s
+func MDNSRecords(s *Server) ([]byte, error) {
+	zone, err := s.mdnsZone()
+	if err != nil {
+		return nil, err
+	}
+	return zone.records(mdnsTTL), nil
+}
+
+// MDNSAnswers reports whether s would answer the mDNS query
+func MDNSAnswers(s *Server, query []byte) (bool, error) {
+	zone, err := s.mdnsZone()
+	if err != nil {
+		return false, err
+	}
+	return zone.answers(query), nil
+}
@@ -0,0 +1,158 @@
+package lrserver
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/fsnotify.v1"
+)
+
+// FSNotifySource is an EventSource backed by filesystem notifications,
+// watching a directory tree recursively
+type FSNotifySource struct {
+	root      string
+	ignore    *ignoreRules
+	recursive bool
+	watcher   *fsnotify.Watcher
+	events    chan ChangeEvent
+	errors    chan error
+	done      chan struct{}
+	once      sync.Once
+}
+
+// NewFSNotifySource starts watching root and every directory below it,
+// including directories created later
+func NewFSNotifySource(root string) (*FSNotifySource, error) {
+	return newIgnoringFSNotifySource(root, nil, true)
+}
+
+// newIgnoringFSNotifySource watches root, and the directories under it
+// that aren't ignored if recursive
+func newIgnoringFSNotifySource(root string, ignore *ignoreRules, recursive bool) (*FSNotifySource, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &FSNotifySource{
+		root:      root,
+		ignore:    ignore,
+		recursive: recursive,
+		watcher:   watcher,
+		events:    make(chan ChangeEvent),
+		errors:    make(chan error),
+		done:      make(chan struct{}),
+	}
+	if recursive {
+		err = f.addTree(root)
+	} else {
+		err = watcher.Add(root)
+	}
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	go f.watch()
+	return f, nil
+}
+
+// Events gets the channel of changes
+func (f *FSNotifySource) Events() <-chan ChangeEvent {
+	return f.events
+}
+
+// Errors gets the channel of watch errors
+func (f *FSNotifySource) Errors() <-chan error {
+	return f.errors
+}
+
+// Close stops watching
+func (f *FSNotifySource) Close() error {
+	var err error
+	f.once.Do(func() {
+		close(f.done)
+		err = f.watcher.Close()
+	})
+	return err
+}
+
+// addTree watches dir and every directory below it that isn't ignored
+func (f *FSNotifySource) addTree(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if f.ignores(path, true) {
+			return filepath.SkipDir
+		}
+		return f.watcher.Add(path)
+	})
+}
+
+func (f *FSNotifySource) watch() {
+	defer close(f.events)
+	for {
+		select {
+		case <-f.done:
+			return
+
+		case event, ok := <-f.watcher.Events:
+			if !ok {
+				return
+			}
+
+			var op ChangeOp
+			switch {
+			case event.Op&fsnotify.Create != 0:
+				op = ChangeCreate
+
+				// Watch new directories too
+				if info, err := os.Stat(event.Name); f.recursive && err == nil && info.IsDir() {
+					if err = f.addTree(event.Name); err != nil {
+						f.sendError(err)
+					}
+				}
+			case event.Op&fsnotify.Write != 0:
+				op = ChangeWrite
+			case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+				op = ChangeRemove
+			default:
+				continue
+			}
+
+			select {
+			case f.events <- ChangeEvent{event.Name, f.root, op}:
+			case <-f.done:
+				return
+			}
+
+		case err, ok := <-f.watcher.Errors:
+			if !ok {
+				return
+			}
+			f.sendError(err)
+		}
+	}
+}
+
+// ignores reports whether path, below the watched root, is ignored
+func (f *FSNotifySource) ignores(path string, dir bool) bool {
+	if f.ignore == nil {
+		return false
+	}
+	rel, err := filepath.Rel(f.root, path)
+	return err == nil && rel != "." && f.ignore.ignored(rel, dir)
+}
+
+// sendError reports err if anyone is listening
+func (f *FSNotifySource) sendError(err error) {
+	select {
+	case f.errors <- err:
+	default:
+	}
+}
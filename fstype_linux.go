@@ -0,0 +1,26 @@
+package lrserver
+
+import "syscall"
+
+// Magic numbers of filesystems that don't deliver inotify events for
+// changes made elsewhere, from statfs(2)
+var pollingFilesystems = map[int64]string{
+	0x6969:     "NFS",
+	0x517b:     "SMB",
+	0xff534d42: "CIFS",
+	0xfe534d42: "SMB2",
+	0x01021997: "9p",
+	0x65735546: "FUSE",
+	0x786f4256: "vboxsf",
+}
+
+// remoteFilesystem gets the name of the filesystem path is on if
+// changes to it may not be notified, such as a network share or a
+// Docker bind mount served over FUSE or 9p, or an empty string
+func remoteFilesystem(path string) string {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return ""
+	}
+	return pollingFilesystems[int64(st.Type)]
+}
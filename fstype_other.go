@@ -0,0 +1,8 @@
+//go:build !linux
+
+package lrserver
+
+// remoteFilesystem can't tell filesystems apart on this platform
+func remoteFilesystem(path string) string {
+	return ""
+}
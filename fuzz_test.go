@@ -0,0 +1,67 @@
+package lrserver
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func FuzzDecodeClientMessage(f *testing.F) {
+	f.Add([]byte(`{"command":"hello","protocols":["http://livereload.com/protocols/official-7"]}`), true)
+	f.Add([]byte(`{"command":"info","url":"http://localhost/","plugins":{}}`), true)
+	f.Add([]byte(`{"command":""}`), true)
+	f.Add([]byte(`{"protocols":null}`), true)
+	f.Add([]byte(`[1,2,3]`), true)
+	f.Add([]byte(`{"command":"hello"`), true)
+	f.Add([]byte("\x00\xff"), false)
+
+	f.Fuzz(func(t *testing.T, data []byte, text bool) {
+		msgType := websocket.BinaryMessage
+		if text {
+			msgType = websocket.TextMessage
+		}
+
+		msg, closeCode, err := decodeClientMessage(msgType, bytes.NewReader(data))
+		if err != nil {
+			if msg != nil {
+				t.Fatalf("got message %+v along with error %v", msg, err)
+			}
+			if closeCode != websocket.CloseUnsupportedData && closeCode != websocket.ClosePolicyViolation {
+				t.Fatalf("unexpected close code %d for %v", closeCode, err)
+			}
+			return
+		}
+		if msg.Command == "" {
+			t.Fatal("decoded a message without a command")
+		}
+
+		// Validating must hold for any decoded message
+		if validateHello(msg) && msg.Command != "hello" {
+			t.Fatalf("validated %q as a hello", msg.Command)
+		}
+	})
+}
+
+func FuzzParseCloseError(f *testing.F) {
+	f.Add("websocket: close 1001 (going away): bye")
+	f.Add("websocket: close 1006 (abnormal closure): unexpected EOF")
+	f.Add("websocket: close 1000")
+	f.Add("websocket: close abcd")
+	f.Add("websocket: close ")
+	f.Add("read tcp: connection reset")
+
+	f.Fuzz(func(t *testing.T, errMsg string) {
+		code, text, ok := parseCloseError(errMsg)
+		if !ok {
+			return
+		}
+		if code < 1000 || code > 4999 {
+			t.Fatalf("parsed invalid close code %d from %q", code, errMsg)
+		}
+		if !strings.HasSuffix(errMsg, text) {
+			t.Fatalf("parsed text %q is not the end of %q", text, errMsg)
+		}
+	})
+}
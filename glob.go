@@ -0,0 +1,86 @@
+package lrserver
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// compileGlob converts a glob pattern into a regular expression matching
+// slash-separated paths. Besides the filepath.Match syntax, ** matches
+// any number of path segments. Patterns starting with a slash match
+// from the root; others match any trailing run of whole segments, so
+// *.css matches every stylesheet and css/*.css those in a css directory.
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	// Validate the pattern's syntax
+	if _, err := filepath.Match(strings.Replace(pattern, "**", "*", -1), ""); err != nil {
+		return nil, err
+	}
+
+	pattern = filepath.ToSlash(pattern)
+	var re strings.Builder
+	if strings.HasPrefix(pattern, "/") {
+		re.WriteString("^/")
+		pattern = pattern[1:]
+	} else {
+		re.WriteString("(?:^|/)")
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					re.WriteString("(?:.*/)?")
+				} else {
+					re.WriteString(".*")
+				}
+			} else {
+				re.WriteString("[^/]*")
+			}
+		case '?':
+			re.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "^") {
+				class = "^" + class[1:]
+			} else if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				re.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			}
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	return regexp.Compile(re.String())
+}
+
+// globMatcher matches paths against a compiled glob
+type globMatcher struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+func newGlobMatcher(pattern string) (*globMatcher, error) {
+	re, err := compileGlob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &globMatcher{pattern, re}, nil
+}
+
+// match reports whether path, with any separators, matches the glob
+func (g *globMatcher) match(path string) bool {
+	return g.re.MatchString(filepath.ToSlash(path))
+}
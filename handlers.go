@@ -1,33 +1,211 @@
 package lrserver
 
 import (
+	"bytes"
+	"encoding/json"
+	"html/template"
 	"net/http"
-
-	"github.com/gorilla/websocket"
+	"strings"
+	"time"
 )
 
+// Attach mounts the endpoints of s on an existing mux, so they share the
+// application's host and port. The served client then targets the host
+// and port of the page that loaded it, instead of the server's own
+// listener, which doesn't need to be started.
+func Attach(mux *http.ServeMux, s *Server) {
+	s.update(func(cfg *settings) { cfg.sameOrigin = true })
+	mount(mux, s)
+}
+
+// Handler gets an http.Handler serving the endpoints of s, for mounting
+// on any router, e.g. chi's r.Handle("/livereload*", lr.Handler()). Like
+// Attach, the served client then targets the page's own host and port.
+// Requests for any other path get a 404.
+func (s *Server) Handler() http.Handler {
+	s.update(func(cfg *settings) { cfg.sameOrigin = true })
+	return limitBodies(s, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		h, ok := s.endpoints.lookup(req.URL.Path)
+		if !ok {
+			http.NotFound(rw, req)
+			return
+		}
+		h(rw, req)
+	}))
+}
+
+func mount(mux *http.ServeMux, s *Server) {
+	s.endpoints.mount(mux)
+}
+
+// builtinEndpoints gets the handlers for the paths every server serves
+func builtinEndpoints(s *Server) map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		// Handle JS
+		s.scriptPath:                 jsHandler(s),
+		s.prefix + "/livereload.mjs": jsModuleHandler(s),
+
+		// Handle reload requests
+		s.prefix + "/livereload": webSocketHandler(s),
+
+		// Handle clients that can't open a websocket
+		s.prefix + "/livereload/sse": sseHandler(s),
+	}
+}
+
 func jsHandler(s *Server) http.HandlerFunc {
 	return func(rw http.ResponseWriter, req *http.Request) {
-		rw.Header().Set("Content-Type", "application/javascript")
-		_, err := rw.Write([]byte(s.js))
-		if err != nil {
-			s.logError(err)
-		}
+		writeScript(s, rw, req, s.clientScript(req, false))
 	}
 }
 
-func webSocketHandler(s *Server) http.HandlerFunc {
-	// Do not check origin
-	upgrader := websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool { return true },
+func jsModuleHandler(s *Server) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		writeScript(s, rw, req, s.clientScript(req, true))
+	}
+}
+
+func writeScript(s *Server, rw http.ResponseWriter, req *http.Request, script string) {
+	if !s.checkHost(rw, req) {
+		return
+	}
+
+	// Pretend the endpoint doesn't exist if JS is disabled
+	cfg := s.settings()
+	if cfg.jsDisabled {
+		if cfg.jsDisabledMsg == "" {
+			http.NotFound(rw, req)
+		} else {
+			http.Error(rw, cfg.jsDisabledMsg, http.StatusNotFound)
+		}
+		return
+	}
+
+	// Refuse to serve anything a strict CSP would block
+	if cfg.strictCSP {
+		if v := cspViolations(script); len(v) > 0 {
+			s.logError("refusing to serve script in strict CSP mode:", strings.Join(v, ", "))
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		rw.Header().Set("X-Content-Type-Options", "nosniff")
 	}
 
+	// Let browsers revalidate their cached copy rather than download the
+	// script again, since it changes whenever the server's address does
+	e, err := s.scripts.get(script)
+	if err != nil {
+		s.logError(err)
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	body, etag := []byte(script), e.etag
+	h := rw.Header()
+	h.Set("Content-Type", "application/javascript")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Vary", "Accept-Encoding")
+	if acceptsGzip(req) {
+		body, etag = e.gz, etag[:len(etag)-1]+`-gzip"`
+		h.Set("Content-Encoding", "gzip")
+	}
+	h.Set("ETag", etag)
+	http.ServeContent(rw, req, "", time.Time{}, bytes.NewReader(body))
+}
+
+func webSocketHandler(s *Server) http.HandlerFunc {
 	return func(rw http.ResponseWriter, req *http.Request) {
-		conn, err := upgrader.Upgrade(rw, req, nil)
+		if !s.checkHost(rw, req) {
+			return
+		}
+
+		// Explain the endpoint to browsers navigating to it
+		if !wantsUpgrade(req) {
+			writeEndpointInfo(s, rw, req)
+			return
+		}
+		if f := diagnoseUpgrade(req); f != nil {
+			writeUpgradeFailure(s, rw, req, f)
+			return
+		}
+		if !s.originAllowed(req) {
+			writeUpgradeFailure(s, rw, req, &upgradeFailure{
+				http.StatusForbidden,
+				"pages from " + req.Header.Get("Origin") + " may not connect",
+				"allow the page's host with SetAllowedOrigins",
+			})
+			return
+		}
+		if !s.authorized(req) {
+			writeUpgradeFailure(s, rw, req, &upgradeFailure{
+				http.StatusUnauthorized,
+				"missing or wrong auth token",
+				"load livereload.js with ?token=, as ScriptTag does",
+			})
+			return
+		}
+
+		conn, err := s.upgrader().Upgrade(rw, req, nil)
 		if err != nil {
-			s.logError(err)
+			writeUpgradeFailure(s, rw, req, &upgradeFailure{
+				http.StatusBadRequest,
+				err.Error(),
+				"check the client and any proxies in between",
+			})
 			return
 		}
-		s.newConn(conn)
+		s.newConn(conn, s.clientAddr(req), req.UserAgent())
+	}
+}
+
+// endpointInfo describes the websocket endpoint to plain HTTP requests
+type endpointInfo struct {
+	Endpoint  string   `json:"endpoint"`
+	Protocols []string `json:"protocols"`
+	ScriptURL string   `json:"scriptURL"`
+	ScriptTag string   `json:"scriptTag"`
+}
+
+var endpointInfoPage = template.Must(template.New("info").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Endpoint}}</title></head>
+<body>
+<h1>{{.Endpoint}}</h1>
+<p>This is the websocket endpoint of a LiveReload server. Browsers connect to
+it through the LiveReload client script, and reload when files change.</p>
+<p>To enable live reloading, include the script in your pages:</p>
+<pre><code>{{.ScriptTag}}</code></pre>
+<p>or use a LiveReload browser extension.</p>
+</body>
+</html>
+`))
+
+// writeEndpointInfo explains what the websocket endpoint is, as HTML for
+// browsers and JSON otherwise, with a 426 Upgrade Required status. The
+// script URL only carries the auth token if req presents it.
+func writeEndpointInfo(s *Server, rw http.ResponseWriter, req *http.Request) {
+	scriptURL := s.scriptLocation(nil)
+	if s.authorized(req) {
+		scriptURL += s.tokenQuery("?")
+	}
+	info := endpointInfo{
+		Endpoint:  s.Name() + " websocket endpoint",
+		Protocols: s.protocols(),
+		ScriptURL: scriptURL,
+		ScriptTag: scriptElement(scriptURL),
+	}
+
+	rw.Header().Set("Upgrade", "websocket")
+	var err error
+	if strings.Contains(req.Header.Get("Accept"), "text/html") {
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		rw.WriteHeader(http.StatusUpgradeRequired)
+		err = endpointInfoPage.Execute(rw, info)
+	} else {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusUpgradeRequired)
+		err = json.NewEncoder(rw).Encode(info)
+	}
+	if err != nil {
+		s.logError(err)
 	}
 }
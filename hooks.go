@@ -0,0 +1,119 @@
+package lrserver
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+)
+
+// ConnInfo describes a connected client
+type ConnInfo struct {
+	// ID identifies the connection among all of the process's servers
+	ID uint64
+
+	// RemoteAddr is the client's address, as forwarded by any trusted proxy
+	RemoteAddr string
+
+	// Protocol is the newest official LiveReload protocol the client
+	// and server both speak
+	Protocol string
+
+	// ProtocolVersion is the version number of Protocol, e.g. 7.
+	// Messages to protocol 6 clients are translated to its array
+	// format, and those it has no form for, like alerts, are skipped.
+	ProtocolVersion int
+
+	// ConnectedAt is when the client completed the handshake
+	ConnectedAt time.Time
+
+	// URL is the page's URL, once the client has reported it
+	URL string
+
+	// UserAgent is the User-Agent header the client connected with
+	UserAgent string
+
+	// Client is what the client has reported about itself
+	Client ClientInfo
+}
+
+// info describes the connection, which must have completed the handshake
+func (c *conn) info() ConnInfo {
+	return ConnInfo{
+		ID:              c.id,
+		RemoteAddr:      c.remoteAddr,
+		Protocol:        c.protocol,
+		ProtocolVersion: protocolVersion(c.protocol),
+		ConnectedAt:     c.connectedAt,
+		URL:             c.pageURL(),
+		UserAgent:       c.userAgent,
+		Client:          c.clientInfo(),
+	}
+}
+
+// ConnectionCount gets the number of clients that have completed the
+// handshake, so tools can skip work nobody would see
+func (s *Server) ConnectionCount() int {
+	n := 0
+	s.conns.each(func(c *conn) {
+		if c.shookHands() {
+			n++
+		}
+	})
+	return n
+}
+
+// Connections describes the clients that have completed the handshake,
+// ordered by ID
+func (s *Server) Connections() []ConnInfo {
+	var infos []ConnInfo
+	s.conns.each(func(c *conn) {
+		if c.shookHands() {
+			infos = append(infos, c.info())
+		}
+	})
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// maxCloseReason is the longest close reason that fits in a close frame
+const maxCloseReason = 123
+
+// CloseConnection disconnects the client with id, as listed by
+// Connections, sending reason in the close frame. Reasons are cut off at
+// 123 bytes, the most a close frame can carry.
+func (s *Server) CloseConnection(id uint64, reason string) error {
+	c := s.conns.get(id)
+	if c == nil {
+		return fmt.Errorf("lrserver: no connection %d", id)
+	}
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return nil
+	}
+	if len(reason) > maxCloseReason {
+		n := maxCloseReason
+		for n > 0 && !utf8.RuneStart(reason[n]) {
+			n--
+		}
+		reason = reason[:n]
+	}
+	s.logStatus("closing " + c.remoteAddr + ": " + reason)
+	return c.closeWith(websocket.CloseNormalClosure, reason)
+}
+
+// SetConnectHandler sets a function called whenever a client completes
+// the handshake, e.g. to hold off a build until a browser is listening.
+// It's called on the connection's own goroutine, so it should return
+// quickly. It can be set to nil.
+func (s *Server) SetConnectHandler(h func(ConnInfo)) {
+	s.update(func(cfg *settings) { cfg.connectHandler = h })
+}
+
+// SetDisconnectHandler sets a function called whenever a client that
+// completed the handshake disconnects. It can be set to nil.
+func (s *Server) SetDisconnectHandler(h func(ConnInfo)) {
+	s.update(func(cfg *settings) { cfg.disconnectHandler = h })
+}
@@ -0,0 +1,151 @@
+package lrserver_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestConnectionHooks(t *testing.T) {
+	Convey("Given a running server with connection hooks", t, func() {
+		srv := startServer(t)
+		connected := make(chan lrserver.ConnInfo, 1)
+		disconnected := make(chan lrserver.ConnInfo, 1)
+		srv.SetConnectHandler(func(info lrserver.ConnInfo) { connected <- info })
+		srv.SetDisconnectHandler(func(info lrserver.ConnInfo) { disconnected <- info })
+
+		Convey("they should be called as clients come and go", func() {
+			conn := connect(t, srv)
+
+			var info lrserver.ConnInfo
+			select {
+			case info = <-connected:
+			case <-time.After(time.Second):
+				t.Fatal("connect handler not called")
+			}
+			So(info.RemoteAddr, ShouldEqual, "127.0.0.1")
+			So(info.Protocol, ShouldEqual, "http://livereload.com/protocols/official-8")
+			So(info.ConnectedAt, ShouldNotBeZeroValue)
+
+			conn.Close()
+			select {
+			case gone := <-disconnected:
+				So(gone, ShouldResemble, info)
+			case <-time.After(time.Second):
+				t.Fatal("disconnect handler not called")
+			}
+		})
+
+		Convey("clients failing the handshake should not be reported", func() {
+			conn, _ := dial(t, srv, nil)
+			conn.WriteJSON(map[string]string{"command": "nonsense"})
+			conn.ReadMessage()
+			conn.Close()
+
+			select {
+			case <-connected:
+				t.Fatal("connect handler called")
+			case <-disconnected:
+				t.Fatal("disconnect handler called")
+			case <-time.After(50 * time.Millisecond):
+			}
+		})
+	})
+}
+
+func TestConnections(t *testing.T) {
+	Convey("Given a running server", t, func() {
+		srv := startServer(t)
+
+		Convey("no connections should be listed at first", func() {
+			So(srv.ConnectionCount(), ShouldEqual, 0)
+			So(srv.Connections(), ShouldBeEmpty)
+		})
+
+		Convey("connected clients should be described", func() {
+			conn, _ := dial(t, srv, http.Header{"User-Agent": {"lrserver-test"}})
+			defer conn.Close()
+			So(conn.WriteJSON(clientHello), ShouldBeNil)
+			So(conn.WriteJSON(map[string]string{"command": "info", "url": "http://localhost:8080/"}), ShouldBeNil)
+			time.Sleep(10 * time.Millisecond)
+
+			So(srv.ConnectionCount(), ShouldEqual, 1)
+			conns := srv.Connections()
+			So(conns, ShouldHaveLength, 1)
+			So(conns[0].RemoteAddr, ShouldEqual, "127.0.0.1")
+			So(conns[0].URL, ShouldEqual, "http://localhost:8080/")
+			So(conns[0].UserAgent, ShouldEqual, "lrserver-test")
+		})
+	})
+}
+
+func TestCloseConnection(t *testing.T) {
+	Convey("Given a running server with two connected clients", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		kicked := connect(t, srv)
+		defer kicked.Close()
+		kept := connect(t, srv)
+		defer kept.Close()
+		conns := srv.Connections()
+		So(conns, ShouldHaveLength, 2)
+
+		Convey("closing one should send it the reason and keep the other", func() {
+			So(srv.CloseConnection(conns[0].ID, "misbehaving"), ShouldBeNil)
+			kicked.SetReadDeadline(time.Now().Add(time.Second))
+			_, _, err := kicked.ReadMessage()
+			So(websocket.IsCloseError(err, websocket.CloseNormalClosure), ShouldBeTrue)
+			So(err.(*websocket.CloseError).Text, ShouldEqual, "misbehaving")
+
+			So(waitFor(func() bool { return srv.ConnectionCount() == 1 }), ShouldBeTrue)
+			So(srv.Connections()[0].ID, ShouldEqual, conns[1].ID)
+		})
+
+		Convey("closing an unknown connection should fail", func() {
+			So(srv.CloseConnection(conns[1].ID+1000, "gone"), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestClientInfo(t *testing.T) {
+	Convey("Given a running server and a client describing itself", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		conn, _ := dial(t, srv, nil)
+		defer conn.Close()
+		So(conn.WriteJSON(map[string]interface{}{
+			"command":   "hello",
+			"protocols": clientHello.Protocols,
+			"ver":       "2.2.2",
+			"ext":       "Chrome",
+			"extver":    "2.1.0",
+			"snipver":   1,
+		}), ShouldBeNil)
+		So(conn.WriteJSON(map[string]interface{}{
+			"command": "info",
+			"url":     "http://localhost:8080/",
+			"plugins": map[string]interface{}{
+				"less": map[string]interface{}{"version": "1.0", "disable": false},
+			},
+		}), ShouldBeNil)
+		time.Sleep(10 * time.Millisecond)
+
+		Convey("its hello and info should be parsed", func() {
+			conns := srv.Connections()
+			So(conns, ShouldHaveLength, 1)
+			client := conns[0].Client
+			So(client.Protocols, ShouldResemble, clientHello.Protocols)
+			So(client.Version, ShouldEqual, "2.2.2")
+			So(client.Extension, ShouldEqual, "Chrome")
+			So(client.ExtensionVersion, ShouldEqual, "2.1.0")
+			So(client.SnippetVersion, ShouldEqual, "1")
+			So(client.Plugins, ShouldResemble, map[string]string{"less": "1.0"})
+			So(client.HasPlugin("less"), ShouldBeTrue)
+			So(client.HasPlugin("images"), ShouldBeFalse)
+		})
+	})
+}
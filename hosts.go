@@ -0,0 +1,83 @@
+package lrserver
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AllowHosts restricts the client JavaScript and websocket endpoints to
+// requests whose Host header names one of hosts, and whose Referer or
+// Origin, when sent, does too. This stops other sites open in the
+// browser from probing or hotlinking the server. Hosts are matched
+// without ports, and a leading *. matches any subdomain. Calling it with
+// no hosts lifts the restriction.
+func (s *Server) AllowHosts(hosts ...string) {
+	allowed := make([]string, len(hosts))
+	for i, h := range hosts {
+		allowed[i] = strings.ToLower(h)
+	}
+	if len(allowed) == 0 {
+		allowed = nil
+	}
+	s.update(func(cfg *settings) { cfg.allowedHosts = allowed })
+}
+
+// AllowedHosts gets the hosts allowed to use the endpoints,
+// or nil if any may
+func (s *Server) AllowedHosts() []string {
+	return append([]string(nil), s.settings().allowedHosts...)
+}
+
+// checkHost reports whether req may use the endpoints, responding
+// with 403 Forbidden if not
+func (s *Server) checkHost(rw http.ResponseWriter, req *http.Request) bool {
+	allowed := s.settings().allowedHosts
+	if allowed == nil {
+		return true
+	}
+
+	reason := ""
+	if !hostAllowed(allowed, req.Host) {
+		reason = "host " + req.Host
+	} else {
+		for _, header := range []string{"Origin", "Referer"} {
+			v := req.Header.Get(header)
+			if v == "" {
+				continue
+			}
+			u, err := url.Parse(v)
+			if err != nil || !hostAllowed(allowed, u.Host) {
+				reason = strings.ToLower(header) + " " + v
+				break
+			}
+		}
+	}
+	if reason == "" {
+		return true
+	}
+
+	s.logError("refusing request for " + req.URL.Path + " from disallowed " + reason)
+	http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	return false
+}
+
+// hostAllowed reports whether hostport's host is in allowed
+func hostAllowed(allowed []string, hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	if host == "" {
+		return false
+	}
+
+	for _, a := range allowed {
+		if a == host || strings.HasPrefix(a, "*.") && strings.HasSuffix(host, a[1:]) {
+			return true
+		}
+	}
+	return false
+}
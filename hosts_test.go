@@ -0,0 +1,61 @@
+package lrserver_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAllowHosts(t *testing.T) {
+	Convey("Given a running server allowing only some hosts", t, func() {
+		srv := startServer(t)
+		srv.AllowHosts("localhost", "127.0.0.1", "*.dev.example.com")
+
+		get := func(host, referer string) int {
+			req, err := http.NewRequest("GET", fmt.Sprintf("http%s:%d/livereload.js", localhost, srv.Port()), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Host = host
+			if referer != "" {
+				req.Header.Set("Referer", referer)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+
+		Convey("allowed hosts and referers should get the script", func() {
+			So(get(fmt.Sprintf("localhost:%d", srv.Port()), ""), ShouldEqual, http.StatusOK)
+			So(get("127.0.0.1", "http://localhost:3000/"), ShouldEqual, http.StatusOK)
+			So(get("app.dev.example.com", "https://app.dev.example.com/page"), ShouldEqual, http.StatusOK)
+		})
+
+		Convey("other hosts and referers should be refused", func() {
+			So(get("evil.example.org", ""), ShouldEqual, http.StatusForbidden)
+			So(get("localhost", "https://evil.example.org/"), ShouldEqual, http.StatusForbidden)
+			So(get("dev.example.com", ""), ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("the websocket should refuse foreign origins", func() {
+			_, resp, err := new(websocket.Dialer).Dial(
+				fmt.Sprintf("ws%s:%d/livereload", localhost, srv.Port()),
+				http.Header{"Origin": {"https://evil.example.org"}},
+			)
+			So(err, ShouldNotBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("allowing no hosts should lift the restriction", func() {
+			srv.AllowHosts()
+			So(srv.AllowedHosts(), ShouldBeNil)
+			So(get("evil.example.org", ""), ShouldEqual, http.StatusOK)
+		})
+	})
+}
@@ -0,0 +1,78 @@
+package lrserver
+
+import (
+	"sync"
+	"time"
+)
+
+// idleWatch times how long a server has gone without connections
+type idleWatch struct {
+	mu    sync.Mutex
+	timer Timer
+	gen   uint64
+}
+
+// IdleShutdown gets how long the server may go without connections
+// before it's stopped
+func (s *Server) IdleShutdown() time.Duration {
+	return s.settings().idleShutdown
+}
+
+// SetIdleShutdown stops the server once it has been serving without any
+// websocket or event stream connections for d, so servers spawned by
+// editors and left behind don't accumulate. If onIdle isn't nil, it's
+// called instead, and may stop the server itself or not. The wait starts
+// over whenever a client connects, and there's none by default; zero or
+// less removes it.
+func (s *Server) SetIdleShutdown(d time.Duration, onIdle func()) {
+	s.update(func(cfg *settings) { cfg.idleShutdown, cfg.onIdle = d, onIdle })
+	s.checkIdle()
+}
+
+// checkIdle starts the idle timer if the server is serving without
+// connections, and stops it otherwise. It's called whenever either
+// changes.
+func (s *Server) checkIdle() {
+	d := s.settings().idleShutdown
+	l, _ := s.listener.Load().(*listenerInfo)
+
+	s.idle.mu.Lock()
+	defer s.idle.mu.Unlock()
+	if d <= 0 || l == nil || s.conns.len() > 0 {
+		if s.idle.timer != nil {
+			s.idle.timer.Stop()
+			s.idle.timer = nil
+			s.idle.gen++
+		}
+		return
+	}
+	if s.idle.timer == nil {
+		gen := s.idle.gen
+		s.idle.timer = s.Clock().AfterFunc(d, func() { s.idleExpired(gen) })
+	}
+}
+
+// idleExpired stops the server, or calls the idle callback, unless the
+// timer was stopped or a client connected in the meantime
+func (s *Server) idleExpired(gen uint64) {
+	s.idle.mu.Lock()
+	if gen != s.idle.gen {
+		s.idle.mu.Unlock()
+		return
+	}
+	s.idle.timer = nil
+	s.idle.gen++
+	idle := s.conns.len() == 0
+	s.idle.mu.Unlock()
+	if !idle {
+		return
+	}
+
+	cfg := s.settings()
+	if cfg.onIdle != nil {
+		cfg.onIdle()
+		return
+	}
+	s.logStatus("no clients for " + cfg.idleShutdown.String() + ", shutting down")
+	s.Close()
+}
@@ -0,0 +1,68 @@
+package lrserver_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIdleShutdown(t *testing.T) {
+	Convey("Given a running server with an idle callback", t, func() {
+		clock := lrserver.NewManualClock(time.Unix(0, 0))
+		srv := startServer(t)
+		defer srv.Close()
+		srv.SetClock(clock)
+		srv.SetKeepalive(0, 0)
+		idle := make(chan struct{}, 1)
+		srv.SetIdleShutdown(time.Minute, func() { idle <- struct{}{} })
+		So(srv.IdleShutdown(), ShouldEqual, time.Minute)
+
+		Convey("it should be called once there have been no clients for the duration", func() {
+			clock.Advance(59 * time.Second)
+			So(idle, ShouldBeEmpty)
+			clock.Advance(time.Second)
+			So(waitFor(func() bool { return len(idle) == 1 }), ShouldBeTrue)
+			So(srv.Status().Listening, ShouldBeTrue)
+		})
+
+		Convey("a client connecting should restart the wait", func() {
+			clock.Advance(30 * time.Second)
+			conn := connect(t, srv)
+			So(clock.Pending(), ShouldEqual, 0)
+			clock.Advance(2 * time.Minute)
+			time.Sleep(10 * time.Millisecond)
+			So(idle, ShouldBeEmpty)
+
+			conn.Close()
+			So(waitFor(func() bool { return clock.Pending() > 0 }), ShouldBeTrue)
+			clock.Advance(59 * time.Second)
+			So(idle, ShouldBeEmpty)
+			clock.Advance(time.Minute)
+			So(waitFor(func() bool { return len(idle) == 1 }), ShouldBeTrue)
+		})
+	})
+
+	Convey("A server created with WithIdleShutdown should stop once idle", t, func() {
+		clock := lrserver.NewManualClock(time.Unix(0, 0))
+		srv, err := lrserver.NewServer(
+			lrserver.WithHost("127.0.0.1"),
+			lrserver.WithPort(0),
+			lrserver.WithLogger(nil),
+			lrserver.WithIdleShutdown(time.Minute),
+		)
+		So(err, ShouldBeNil)
+		srv.SetClock(clock)
+		done := make(chan error, 1)
+		go func() { done <- srv.ListenAndServe() }()
+		So(waitFor(func() bool { return clock.Pending() > 0 }), ShouldBeTrue)
+
+		clock.Advance(time.Minute)
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("server still serving")
+		}
+	})
+}
@@ -0,0 +1,201 @@
+package lrserver
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// GitIgnore skips the paths ignored by git: .git directories, and those
+// matching the rules in .git/info/exclude and every .gitignore in the
+// watched tree, read when the watch starts. Ignored directories aren't
+// watched at all, which keeps large trees like node_modules from using
+// up the system's watch limits.
+var GitIgnore WatchOption = func(o *watchOptions) { o.gitIgnore = true }
+
+// IgnoreFile skips the paths matching the rules in each of the ignore
+// files at paths, written in .gitignore syntax and matched relative to
+// the watched directory. Like GitIgnore, ignored directories aren't
+// watched at all.
+func IgnoreFile(paths ...string) WatchOption {
+	return func(o *watchOptions) { o.ignoreFiles = append(o.ignoreFiles, paths...) }
+}
+
+// Ignore skips the paths matching any of the glob patterns, matched as
+// for Exclude, e.g. "node_modules/**". Unlike Exclude, directories whose
+// contents all match aren't watched at all.
+func Ignore(patterns ...string) WatchOption {
+	return func(o *watchOptions) { o.ignore = append(o.ignore, patterns...) }
+}
+
+// ignoreRule is a line of an ignore file, or a pattern given to Ignore
+type ignoreRule struct {
+	base    string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+
+	// glob rules also ignore directories they match everything in
+	glob bool
+}
+
+// ignoreRules matches paths relative to a watched directory against
+// rules in .gitignore syntax. A nil set ignores nothing.
+type ignoreRules struct {
+	rules []ignoreRule
+}
+
+// newIgnoreRules gets the rules selected by a watch's options for root,
+// or nil if there are none
+func newIgnoreRules(root string, o *watchOptions) (*ignoreRules, error) {
+	if !o.gitIgnore && len(o.ignoreFiles) == 0 && len(o.ignore) == 0 {
+		return nil, nil
+	}
+	r := new(ignoreRules)
+	for _, path := range o.ignoreFiles {
+		if err := r.loadFile(path, ""); err != nil {
+			return nil, err
+		}
+	}
+	if o.gitIgnore {
+		if err := r.loadGitIgnores(root); err != nil {
+			return nil, err
+		}
+	}
+
+	// Ignore patterns come last, so no ignore file re-includes their paths
+	for _, pattern := range o.ignore {
+		re, err := compileGlob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		r.rules = append(r.rules, ignoreRule{re: re, glob: true})
+	}
+	return r, nil
+}
+
+// loadGitIgnores adds the rules git applies under root, reading the
+// .gitignore in each directory that isn't itself ignored
+func (r *ignoreRules) loadGitIgnores(root string) error {
+	r.add(".git/", "")
+	err := r.loadFile(filepath.Join(root, ".git", "info", "exclude"), "")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			rel = ""
+		} else if r.ignored(rel, true) {
+			return filepath.SkipDir
+		}
+		err = r.loadFile(filepath.Join(path, ".gitignore"), filepath.ToSlash(rel))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	})
+}
+
+// loadFile adds the rules in the ignore file at path, matched relative
+// to the base directory
+func (r *ignoreRules) loadFile(path, base string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if err := r.add(scanner.Text(), base); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// add adds the rule on a line of an ignore file
+func (r *ignoreRules) add(line, base string) error {
+	line = strings.TrimSuffix(line, "\r")
+	if !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || line[0] == '#' {
+		return nil
+	}
+
+	var rule ignoreRule
+	rule.base = base
+	if line[0] == '!' {
+		rule.negate, line = true, line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly, line = true, strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return nil
+	}
+
+	// Patterns with a slash are relative to the ignore file's directory
+	if strings.Contains(line, "/") && !strings.HasPrefix(line, "/") {
+		line = "/" + line
+	}
+	re, err := compileGlob(line)
+	if err != nil {
+		return err
+	}
+	rule.re = re
+	r.rules = append(r.rules, rule)
+	return nil
+}
+
+// ignored reports whether the path, relative to the watched directory,
+// is ignored. Nothing below an ignored directory can be re-included.
+func (r *ignoreRules) ignored(rel string, dir bool) bool {
+	if r == nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	for i := strings.IndexByte(rel, '/'); i >= 0; {
+		if r.match(rel[:i], true) {
+			return true
+		}
+		next := strings.IndexByte(rel[i+1:], '/')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return r.match(rel, dir)
+}
+
+// match applies the rules to the path itself, the last matching one
+// deciding whether it's ignored
+func (r *ignoreRules) match(rel string, dir bool) bool {
+	ignored := false
+	for _, rule := range r.rules {
+		if rule.dirOnly && !dir {
+			continue
+		}
+		path := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(path, rule.base+"/") {
+				continue
+			}
+			path = path[len(rule.base)+1:]
+		}
+		if rule.re.MatchString("/"+path) || rule.glob && dir && rule.re.MatchString("/"+path+"/") {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,119 @@
+package lrserver
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Middleware wraps next, an application's handler, inserting the script
+// tag into the HTML pages it serves so they connect without editing
+// templates. The tag targets the server's own listener, on the page's
+// host when listening on all interfaces, over https while serving TLS.
+// Compressed responses are passed through untouched, so it belongs
+// inside any compressing middleware.
+func (s *Server) Middleware(next http.Handler) http.Handler {
+	return injectScript(s, next)
+}
+
+// injectScript wraps h, inserting the script tag into the HTML pages it
+// serves so they connect without edits. Compressed responses are passed
+// through untouched.
+func injectScript(s *Server, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		iw := &injectWriter{ResponseWriter: rw}
+		h.ServeHTTP(iw, req)
+		iw.finish(s.scriptTag(req))
+	})
+}
+
+// injectWriter buffers HTML responses so the script tag can be inserted,
+// writing anything else straight through
+type injectWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	buffering   bool
+	buf         bytes.Buffer
+}
+
+func (w *injectWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+
+	h := w.Header()
+	w.buffering = strings.HasPrefix(h.Get("Content-Type"), "text/html") &&
+		h.Get("Content-Encoding") == "" &&
+		status != http.StatusNoContent && status != http.StatusNotModified
+	if w.buffering {
+		h.Del("Content-Length")
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *injectWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buffering {
+		return w.buf.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush flushes responses that aren't being buffered
+func (w *injectWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && !w.buffering {
+		f.Flush()
+	}
+}
+
+// Hijack lets the wrapped handler take over the connection, e.g. to
+// upgrade it to a websocket
+func (w *injectWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("lrserver: response does not support hijacking")
+	}
+	return hj.Hijack()
+}
+
+// finish writes the buffered page, with tag inserted
+func (w *injectWriter) finish(tag string) {
+	if !w.buffering {
+		return
+	}
+	page := insertTag(w.buf.Bytes(), tag)
+	w.Header().Set("Content-Length", strconv.Itoa(len(page)))
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(page)
+}
+
+// insertTag inserts tag before the closing body tag of page, or the
+// closing head or html tag if there isn't one, or else at its end
+func insertTag(page []byte, tag string) []byte {
+	lower := bytes.ToLower(page)
+	for _, closing := range []string{"</body>", "</head>", "</html>"} {
+		if i := bytes.LastIndex(lower, []byte(closing)); i >= 0 {
+			out := make([]byte, 0, len(page)+len(tag))
+			out = append(out, page[:i]...)
+			out = append(out, tag...)
+			return append(out, page[i:]...)
+		}
+	}
+	return append(page, tag...)
+}
@@ -0,0 +1,47 @@
+package lrserver_test
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIPv6(t *testing.T) {
+	l, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 loopback unavailable:", err)
+	}
+	l.Close()
+
+	Convey("Given a server listening on a bracketed IPv6 host", t, func() {
+		srv, err := lrserver.NewServer(lrserver.WithHost("[::1]"), lrserver.WithPort(0), lrserver.WithLogger(nil))
+		So(err, ShouldBeNil)
+		go srv.ListenAndServe()
+		defer srv.Close()
+		waitFor(func() bool { return srv.Port() != 0 })
+
+		Convey("its address should be bracketed", func() {
+			So(srv.Host(), ShouldEqual, "::1")
+			So(srv.Addr(), ShouldEqual, fmt.Sprintf("[::1]:%d", srv.Port()))
+			So(srv.ScriptURL(), ShouldEqual, fmt.Sprintf("http://[::1]:%d/livereload.js", srv.Port()))
+		})
+
+		Convey("the script should be served with the literal host", func() {
+			So(getBody(t, srv.ScriptURL()), ShouldContainSubstring, `this.host = "::1";`)
+		})
+
+		Convey("browsers should be able to connect", func() {
+			conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://[::1]:%d/livereload", srv.Port()), nil)
+			So(err, ShouldBeNil)
+			defer conn.Close()
+			So(conn.WriteJSON(clientHello), ShouldBeNil)
+			sh := new(serverHello)
+			So(conn.ReadJSON(sh), ShouldBeNil)
+			So(sh.Command, ShouldEqual, "hello")
+		})
+	})
+}
@@ -21,7 +21,11 @@ package lrserver
 // OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
 // WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 
-const js string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=typeof require=="function"&&require;if(!u&&a)return a(o,!0);if(i)return i(o,!0);var f=new Error("Cannot find module '"+o+"'");throw f.code="MODULE_NOT_FOUND",f}var l=n[o]={exports:{}};t[o][0].call(l.exports,function(e){var n=t[o][1][e];return s(n?n:e)},l,l.exports,e,t,n,r)}return n[o].exports}var i=typeof require=="function"&&require;for(var o=0;o<r.length;o++)s(r[o]);return s})({1:[function(require,module,exports){
+// clientVersion is the version of the embedded livereload.js,
+// which must match Version in jsBundle
+const clientVersion = "2.2.2"
+
+const jsBundle string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=typeof require=="function"&&require;if(!u&&a)return a(o,!0);if(i)return i(o,!0);var f=new Error("Cannot find module '"+o+"'");throw f.code="MODULE_NOT_FOUND",f}var l=n[o]={exports:{}};t[o][0].call(l.exports,function(e){var n=t[o][1][e];return s(n?n:e)},l,l.exports,e,t,n,r)}return n[o].exports}var i=typeof require=="function"&&require;for(var o=0;o<r.length;o++)s(r[o]);return s})({1:[function(require,module,exports){
 (function() {
   var Connector, PROTOCOL_6, PROTOCOL_7, Parser, Version, _ref;
 
@@ -35,7 +39,9 @@ const js string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=
       this.WebSocket = WebSocket;
       this.Timer = Timer;
       this.handlers = handlers;
-      this._uri = "ws" + (this.options.https ? "s" : "") + "://" + this.options.host + ":" + this.options.port + "/livereload";
+      this._uri = (this.options.secure() ? "wss" : "ws") + "://" + this.options.hostPort() + this.options.path + "/livereload" + this.options.tokenQuery('?');
+      this._sseUri = (this.options.secure() ? "https" : "http") + "://" + this.options.hostPort() + this.options.path + "/livereload/sse";
+      this._sse = false;
       this._nextDelay = this.options.mindelay;
       this._connectionDesired = false;
       this.protocol = 0;
@@ -67,7 +73,7 @@ const js string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=
             return;
           }
           _this._disconnectionReason = 'handshake-timeout';
-          return _this.socket.close();
+          return _this._closeSocket();
         };
       })(this));
       this._reconnectTimer = new Timer((function(_this) {
@@ -94,6 +100,7 @@ const js string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=
       this._disconnectionReason = 'cannot-connect';
       this.protocolParser.reset();
       this.handlers.connecting();
+      this._sse = false;
       this.socket = new this.WebSocket(this._uri);
       this.socket.onopen = (function(_this) {
         return function(e) {
@@ -124,7 +131,36 @@ const js string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=
         return;
       }
       this._disconnectionReason = 'manual';
-      return this.socket.close();
+      return this._closeSocket();
+    };
+
+    Connector.prototype._connectSSE = function() {
+      var query, _ref1;
+      this._sse = true;
+      this._disconnectionReason = 'cannot-connect';
+      this.protocolParser.reset();
+      query = '?url=' + encodeURIComponent(typeof window !== 'undefined' && ((_ref1 = window.location) != null ? _ref1.href : void 0) || '');
+      if (this.protocolParser.epoch) {
+        query += '&epoch=' + encodeURIComponent(this.protocolParser.epoch);
+      }
+      this.socket = new EventSource(this._sseUri + query + this.options.tokenQuery('&'));
+      this.socket.onmessage = (function(_this) {
+        return function(e) {
+          return _this._onmessage(e);
+        };
+      })(this);
+      return this.socket.onerror = (function(_this) {
+        return function(e) {
+          return _this._closeSocket();
+        };
+      })(this);
+    };
+
+    Connector.prototype._closeSocket = function() {
+      this.socket.close();
+      if (this._sse) {
+        return this._onclose();
+      }
     };
 
     Connector.prototype._scheduleReconnection = function() {
@@ -145,13 +181,16 @@ const js string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=
     };
 
     Connector.prototype._sendCommand = function(command) {
+      if (this._sse) {
+        return;
+      }
       return this.socket.send(JSON.stringify(command));
     };
 
     Connector.prototype._closeOnError = function() {
       this._handshakeTimeout.stop();
       this._disconnectionReason = 'error';
-      return this.socket.close();
+      return this._closeSocket();
     };
 
     Connector.prototype._onopen = function(e) {
@@ -172,11 +211,17 @@ const js string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=
       if (this.options.snipver) {
         hello.snipver = this.options.snipver;
       }
+      if (this.protocolParser.epoch) {
+        hello.epoch = this.protocolParser.epoch;
+      }
       this._sendCommand(hello);
       return this._handshakeTimeout.start(this.options.handshake_timeout);
     };
 
     Connector.prototype._onclose = function(e) {
+      if (!this._sse && this._disconnectionReason === 'cannot-connect' && typeof EventSource !== 'undefined') {
+        return this._connectSSE();
+      }
       this.protocol = 0;
       this.handlers.disconnected(this._disconnectionReason, this._nextDelay);
       return this._scheduleReconnection();
@@ -339,11 +384,7 @@ const js string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=
           this.options.set(k, v);
         }
       } else {
-        this.options = Options.extract(this.window.document);
-        if (!this.options) {
-          this.console.error("LiveReload disabled because it could not find its own <SCRIPT> tag");
-          return;
-        }
+        this.options = Options.extract(this.window.document) || new Options();
       }
       this.reloader = new Reloader(this.window, this.console, Timer);
       this.connector = new Connector(this.options, this.WebSocket, Timer, {
@@ -359,7 +400,7 @@ const js string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=
             if (typeof (_base = _this.listeners).connect === "function") {
               _base.connect();
             }
-            _this.log("LiveReload is connected to " + _this.options.host + ":" + _this.options.port + " (protocol v" + protocol + ").");
+            _this.log("LiveReload is connected to " + _this.options.hostPort() + " (protocol v" + protocol + ").");
             return _this.analyze();
           };
         })(this),
@@ -384,19 +425,19 @@ const js string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=
             }
             switch (reason) {
               case 'cannot-connect':
-                return _this.log("LiveReload cannot connect to " + _this.options.host + ":" + _this.options.port + ", will retry in " + nextDelay + " sec.");
+                return _this.log("LiveReload cannot connect to " + _this.options.hostPort() + ", will retry in " + nextDelay + " sec.");
               case 'broken':
-                return _this.log("LiveReload disconnected from " + _this.options.host + ":" + _this.options.port + ", reconnecting in " + nextDelay + " sec.");
+                return _this.log("LiveReload disconnected from " + _this.options.hostPort() + ", reconnecting in " + nextDelay + " sec.");
               case 'handshake-timeout':
-                return _this.log("LiveReload cannot connect to " + _this.options.host + ":" + _this.options.port + " (handshake timeout), will retry in " + nextDelay + " sec.");
+                return _this.log("LiveReload cannot connect to " + _this.options.hostPort() + " (handshake timeout), will retry in " + nextDelay + " sec.");
               case 'handshake-failed':
-                return _this.log("LiveReload cannot connect to " + _this.options.host + ":" + _this.options.port + " (handshake failed), will retry in " + nextDelay + " sec.");
+                return _this.log("LiveReload cannot connect to " + _this.options.hostPort() + " (handshake failed), will retry in " + nextDelay + " sec.");
               case 'manual':
                 break;
               case 'error':
                 break;
               default:
-                return _this.log("LiveReload disconnected from " + _this.options.host + ":" + _this.options.port + " (" + reason + "), reconnecting in " + nextDelay + " sec.");
+                return _this.log("LiveReload disconnected from " + _this.options.hostPort() + " (" + reason + "), reconnecting in " + nextDelay + " sec.");
             }
           };
         })(this),
@@ -407,6 +448,8 @@ const js string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=
                 return _this.performReload(message);
               case 'alert':
                 return _this.performAlert(message);
+              default:
+                return _this.performCommand(message);
             }
           };
         })(this)
@@ -430,12 +473,69 @@ const js string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=
         liveImg: (_ref1 = message.liveImg) != null ? _ref1 : true,
         originalPath: message.originalPath || '',
         overrideURL: message.overrideURL || '',
-        serverURL: "http://" + this.options.host + ":" + this.options.port
+        serverURL: "http://" + this.options.hostPort()
       });
     };
 
+    LiveReload.prototype.performCommand = function(message) {
+      var plugin, _i, _len, _ref;
+      this.log("LiveReload received " + message.command + " command: " + (JSON.stringify(message, null, 2)));
+      _ref = this.plugins;
+      for (_i = 0, _len = _ref.length; _i < _len; _i++) {
+        plugin = _ref[_i];
+        if (typeof plugin.command === 'function') {
+          plugin.command(message);
+        }
+      }
+      if (typeof this.window.CustomEvent === 'function') {
+        return this.window.document.dispatchEvent(new this.window.CustomEvent('LiveReloadCommand', {
+          detail: message
+        }));
+      }
+    };
+
     LiveReload.prototype.performAlert = function(message) {
-      return alert(message.message);
+      var colors, container, document, remove, toast;
+      document = this.window.document;
+      if (!(document && document.body)) {
+        return alert(message.message);
+      }
+      if (!(container = document.getElementById('livereload-toasts'))) {
+        container = document.createElement('div');
+        container.id = 'livereload-toasts';
+        container.style.position = 'fixed';
+        container.style.right = '1em';
+        container.style.bottom = '1em';
+        container.style.zIndex = '2147483647';
+        container.style.maxWidth = '40em';
+        document.body.appendChild(container);
+      }
+      colors = {
+        info: '#2f6fb0',
+        warning: '#a8700c',
+        error: '#b0302f'
+      };
+      toast = document.createElement('div');
+      toast.textContent = message.message;
+      toast.style.marginTop = '0.5em';
+      toast.style.padding = '0.75em 1em';
+      toast.style.borderRadius = '4px';
+      toast.style.boxShadow = '0 2px 8px rgba(0, 0, 0, 0.3)';
+      toast.style.color = '#fff';
+      toast.style.background = colors[message.level] || colors.info;
+      toast.style.font = '14px/1.4 sans-serif';
+      toast.style.whiteSpace = 'pre-wrap';
+      toast.style.cursor = 'pointer';
+      remove = function() {
+        if (toast.parentNode) {
+          return toast.parentNode.removeChild(toast);
+        }
+      };
+      toast.addEventListener('click', remove);
+      container.appendChild(toast);
+      if (!message.sticky) {
+        return this.window.setTimeout(remove, message.duration || 5000);
+      }
     };
 
     LiveReload.prototype.shutDown = function() {
@@ -511,9 +611,12 @@ const js string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=
 
   exports.Options = Options = (function() {
     function Options() {
-      this.https = false;
-      this.host = "%s";
+      this.https = %t || (typeof window !== 'undefined' && window.location != null && window.location.protocol === 'https:');
+      this.scheme = null;
+      this.host = %s;
       this.port = %d;
+      this.path = %s;
+      this.token = %s;
       this.snipver = null;
       this.ext = null;
       this.extver = null;
@@ -526,12 +629,34 @@ const js string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=
       if (typeof value === 'undefined') {
         return;
       }
-      if (!isNaN(+value)) {
+      if (name !== 'token' && !isNaN(+value)) {
         value = +value;
       }
       return this[name] = value;
     };
 
+    Options.prototype.hostPort = function() {
+      var host = String(this.host);
+      if (host.indexOf(':') !== -1 && host.charAt(0) !== '[') {
+        host = '[' + host + ']';
+      }
+      return host + ":" + this.port;
+    };
+
+    Options.prototype.tokenQuery = function(sep) {
+      if (this.token == null || this.token === '') {
+        return '';
+      }
+      return sep + 'token=' + encodeURIComponent(String(this.token));
+    };
+
+    Options.prototype.secure = function() {
+      if (this.scheme === 'wss' || this.scheme === 'ws') {
+        return this.scheme === 'wss';
+      }
+      return !!this.https && this.https !== 'false';
+    };
+
     return Options;
 
   })();
@@ -544,8 +669,8 @@ const js string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=
       if ((src = element.src) && (m = src.match(/^[^:]+:\/\/(.*)\/z?livereload\.js(?:\?(.*))?$/))) {
         options = new Options();
         options.https = src.indexOf("https") === 0;
-        if (mm = m[1].match(/^([^\/:]+)(?::(\d+))?$/)) {
-          options.host = mm[1];
+        if (mm = m[1].match(/^(\[[^\]\/]+\]|[^\/:\[]+)(?::(\d+))?$/)) {
+          options.host = mm[1].replace(/^\[|\]$/g, '');
           if (mm[2]) {
             options.port = parseInt(mm[2], 10);
           }
@@ -602,6 +727,9 @@ const js string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=
           if (data.match(/^!!ver:([\d.]+)$/)) {
             this.protocol = 6;
           } else if (message = this._parseMessage(data, ['hello'])) {
+            if (message.epoch) {
+              this.epoch = message.epoch;
+            }
             if (!message.protocols.length) {
               throw new ProtocolError("no protocols specified in handshake message");
             } else if (__indexOf.call(message.protocols, PROTOCOL_7) >= 0) {
@@ -628,7 +756,10 @@ const js string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=
             liveCSS: (_ref = options.apply_css_live) != null ? _ref : true
           });
         } else {
-          message = this._parseMessage(data, ['reload', 'alert']);
+          message = this._parseMessage(data, null);
+          if (message.command === 'hello') {
+            throw new ProtocolError("unexpected handshake message", data);
+          }
           return this.handlers.message(message);
         }
       } catch (_error) {
@@ -652,7 +783,7 @@ const js string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=
       if (!message.command) {
         throw new ProtocolError('missing "command" key', data);
       }
-      if (_ref = message.command, __indexOf.call(validCommands, _ref) < 0) {
+      if (validCommands && (_ref = message.command, __indexOf.call(validCommands, _ref) < 0)) {
         throw new ProtocolError("invalid command '" + message.command + "', only valid commands are: " + (validCommands.join(', ')) + ")", data);
       }
       return message;
@@ -1203,4 +1334,62 @@ const js string = `(function e(t,n,r){function s(o,u){if(!n[o]){if(!t[o]){var a=
 
 }).call(this);
 
-},{}]},{},[8]);`
+},{}]}`
+
+// js is the classic client script, which starts itself on load
+const js string = jsBundle + `,{},[8]);`
+
+// jsModule is the ES module variant of the client script, which exports an
+// init function instead of starting itself on load
+const jsModule string = `var lrRequire = ` + jsBundle + `,{},[]);
+
+export function init(options) {
+  var CustomEvents, LiveReload, k, opts;
+
+  if (window.LiveReload) {
+    return window.LiveReload;
+  }
+
+  opts = {};
+  if (options) {
+    for (k in options) {
+      if (Object.prototype.hasOwnProperty.call(options, k)) {
+        opts[k] = options[k];
+      }
+    }
+  }
+  window.LiveReloadOptions = opts;
+
+  CustomEvents = lrRequire(2);
+
+  LiveReload = window.LiveReload = new (lrRequire(4).LiveReload)(window);
+
+  for (k in window) {
+    if (k.match(/^LiveReloadPlugin/)) {
+      LiveReload.addPlugin(window[k]);
+    }
+  }
+
+  LiveReload.addPlugin(lrRequire(3));
+
+  LiveReload.on('shutdown', function() {
+    return delete window.LiveReload;
+  });
+
+  LiveReload.on('connect', function() {
+    return CustomEvents.fire(document, 'LiveReloadConnect');
+  });
+
+  LiveReload.on('disconnect', function() {
+    return CustomEvents.fire(document, 'LiveReloadDisconnect');
+  });
+
+  CustomEvents.bind(document, 'LiveReloadShutDown', function() {
+    return LiveReload.shutDown();
+  });
+
+  return LiveReload;
+}
+
+export default init;
+`
@@ -0,0 +1,70 @@
+package lrserver
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Default keepalive timings
+const (
+	DefaultPingInterval = 30 * time.Second
+	DefaultPongTimeout  = 10 * time.Second
+)
+
+// PingInterval gets how long a websocket may sit idle before it's pinged
+func (s *Server) PingInterval() time.Duration {
+	return s.settings().pingInterval
+}
+
+// PongTimeout gets how long a pinged websocket has to answer
+func (s *Server) PongTimeout() time.Duration {
+	return s.settings().pongTimeout
+}
+
+// SetKeepalive sets how often websockets are pinged, DefaultPingInterval
+// by default, and how long they have to answer with a pong,
+// DefaultPongTimeout by default. Clients that don't answer, such as
+// those on a laptop that went to sleep, are disconnected rather than
+// left to hold up broadcasts. An interval of zero or less disables
+// pings for connections made from then on.
+func (s *Server) SetKeepalive(interval, timeout time.Duration) {
+	s.update(func(cfg *settings) {
+		cfg.pingInterval, cfg.pongTimeout = interval, timeout
+	})
+}
+
+// keepalive pings the client until the connection closes, closing it
+// if a ping goes unanswered
+func (c *conn) keepalive() {
+	clock := c.server.Clock()
+	for {
+		cfg := c.server.settings()
+		if cfg.pingInterval <= 0 {
+			return
+		}
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-clock.After(cfg.pingInterval):
+		}
+
+		pongs := atomic.LoadUint32(&c.pongs)
+		err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(cfg.pongTimeout))
+		if err != nil {
+			c.close(websocket.CloseGoingAway, err)
+			return
+		}
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-clock.After(cfg.pongTimeout):
+		}
+		if atomic.LoadUint32(&c.pongs) == pongs {
+			c.server.logStatus("unresponsive, disconnecting: " + c.remoteAddr)
+			c.close(websocket.CloseGoingAway, nil)
+			return
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package lrserver_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// waitFor polls cond for up to a second
+func waitFor(cond func() bool) bool {
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestKeepalive(t *testing.T) {
+	Convey("Given a server pinging connected websockets", t, func() {
+		clock := lrserver.NewManualClock(time.Unix(0, 0))
+		srv := startServer(t)
+		defer srv.Close()
+		srv.SetClock(clock)
+		srv.SetKeepalive(time.Minute, 5*time.Second)
+
+		conn := connect(t, srv)
+		defer conn.Close()
+		So(srv.ConnectionCount(), ShouldEqual, 1)
+
+		// ping, then wait out the pong timeout
+		ping := func() {
+			clock.Advance(time.Minute)
+			time.Sleep(20 * time.Millisecond)
+			clock.Advance(5 * time.Second)
+		}
+
+		Convey("a client answering pings should stay connected", func() {
+			go func() {
+				for {
+					if _, _, err := conn.ReadMessage(); err != nil {
+						return
+					}
+				}
+			}()
+			ping()
+			time.Sleep(20 * time.Millisecond)
+			So(srv.ConnectionCount(), ShouldEqual, 1)
+		})
+
+		Convey("an unresponsive client should be disconnected", func() {
+			ping()
+			So(waitFor(func() bool { return srv.ConnectionCount() == 0 }), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,145 @@
+package lrserver
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// goroutineGroup counts running goroutines like a sync.WaitGroup, but
+// can be waited on with a context while goroutines keep being added
+type goroutineGroup struct {
+	mu   sync.Mutex
+	n    int
+	idle chan struct{}
+}
+
+func (g *goroutineGroup) add() {
+	g.mu.Lock()
+	if g.n == 0 {
+		g.idle = make(chan struct{})
+	}
+	g.n++
+	g.mu.Unlock()
+}
+
+func (g *goroutineGroup) done() {
+	g.mu.Lock()
+	g.n--
+	if g.n == 0 {
+		close(g.idle)
+	}
+	g.mu.Unlock()
+}
+
+func (g *goroutineGroup) count() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.n
+}
+
+// wait blocks until no goroutines are running or ctx is done
+func (g *goroutineGroup) wait(ctx context.Context) error {
+	g.mu.Lock()
+	if g.n == 0 {
+		g.mu.Unlock()
+		return nil
+	}
+	idle := g.idle
+	g.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// spawn runs f in a goroutine tracked by the server
+func (s *Server) spawn(f func()) {
+	s.goroutines.add()
+	go func() {
+		defer s.goroutines.done()
+		f()
+	}()
+}
+
+// ActiveGoroutines gets the number of connection and event source
+// goroutines still running
+func (s *Server) ActiveGoroutines() int {
+	return s.goroutines.count()
+}
+
+// WaitIdle blocks until every connection and event source goroutine
+// has exited, or returns ctx's error if it's done first. It lets tests
+// assert that the server doesn't leak goroutines once its clients and
+// sources have gone.
+func (s *Server) WaitIdle(ctx context.Context) error {
+	return s.goroutines.wait(ctx)
+}
+
+// halt tells the goroutines that would otherwise run as long as the
+// server, those of event sources and dashboards, to stop
+func (s *Server) halt() {
+	s.haltOnce.Do(func() { close(s.halted) })
+}
+
+// haltContext returns a context cancelled once the server halts, for
+// work done on its behalf that shouldn't hold up Shutdown
+func (s *Server) haltContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.spawn(func() {
+		select {
+		case <-s.halted:
+			cancel()
+		case <-ctx.Done():
+		}
+	})
+	return ctx, cancel
+}
+
+// Shutdown gracefully shuts down the server, like http.Server.Shutdown.
+// It stops listening, then closes each connection with a close frame once
+// the messages already queued for it have been sent, and waits for every
+// connection to finish. If ctx is done first the remaining connections
+// are closed immediately and ctx's error is returned. Once Shutdown has
+// been called, ListenAndServe returns http.ErrServerClosed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&s.shuttingDown, 1)
+
+	// Stop connections, dashboards and event sources before the listener,
+	// whose Shutdown would wait on the open requests of SSE clients
+	s.conns.each(func(c *conn) {
+		c.closeWhenSent(websocket.CloseGoingAway)
+	})
+	s.halt()
+	s.SetBroker(nil)
+	err := s.server.Shutdown(ctx)
+	connErr := s.WaitIdle(ctx)
+	s.cancel()
+	if connErr != nil {
+		s.closeConns(websocket.CloseGoingAway)
+	}
+	s.events.close()
+	if err == nil {
+		err = connErr
+	}
+	return err
+}
+
+// Close immediately closes the listener and every connection, like
+// http.Server.Close, dropping any messages not yet sent. Use Shutdown to
+// let them drain first.
+func (s *Server) Close() error {
+	atomic.StoreInt32(&s.shuttingDown, 1)
+	s.SetBroker(nil)
+	err := s.server.Close()
+	s.halt()
+	s.cancel()
+	s.closeConns(websocket.CloseGoingAway)
+	s.events.close()
+	return err
+}
@@ -0,0 +1,88 @@
+package lrserver
+
+import (
+	"net/http"
+	"time"
+)
+
+// HTTPLimits bounds what the server's own listener accepts from clients,
+// so a development port exposed on a LAN can't be tied up by clients
+// that connect and then trickle or flood their requests
+type HTTPLimits struct {
+	// ReadHeaderTimeout is how long a client may take to send the
+	// request headers
+	ReadHeaderTimeout time.Duration
+
+	// IdleTimeout is how long a kept-alive connection may sit idle
+	// between requests
+	IdleTimeout time.Duration
+
+	// MaxHeaderBytes caps the size of the request headers
+	MaxHeaderBytes int
+
+	// MaxBodyBytes caps the size of request bodies
+	MaxBodyBytes int64
+}
+
+// DefaultHTTPLimits are the limits servers start with
+var DefaultHTTPLimits = HTTPLimits{
+	ReadHeaderTimeout: 10 * time.Second,
+	IdleTimeout:       2 * time.Minute,
+	MaxHeaderBytes:    64 << 10,
+	MaxBodyBytes:      1 << 20,
+}
+
+// HTTPLimits gets the limits on requests to the server's own listener
+func (s *Server) HTTPLimits() HTTPLimits {
+	return s.settings().httpLimits
+}
+
+// SetHTTPLimits sets the limits on requests to the server's own listener,
+// DefaultHTTPLimits by default. Zero values remove a limit. The timeouts
+// and header size are only picked up by ListenAndServe if set before it's
+// called; the body size applies straight away.
+func (s *Server) SetHTTPLimits(l HTTPLimits) {
+	s.update(func(cfg *settings) { cfg.httpLimits = l })
+	s.server.ReadHeaderTimeout = l.ReadHeaderTimeout
+	s.server.IdleTimeout = l.IdleTimeout
+	s.server.MaxHeaderBytes = l.MaxHeaderBytes
+}
+
+// DefaultWriteTimeout is how long a message may take to be written to a
+// client by default
+const DefaultWriteTimeout = 10 * time.Second
+
+// WriteTimeout gets how long each message may take to be written to a
+// client
+func (s *Server) WriteTimeout() time.Duration {
+	return s.settings().writeTimeout
+}
+
+// SetWriteTimeout sets how long each message may take to be written to a
+// websocket or event stream, DefaultWriteTimeout by default, so a client
+// that stops reading can't hang its connection's goroutine. Clients that
+// time out are disconnected. Zero or less removes the deadline. Unlike
+// http.Server's WriteTimeout, it bounds single writes rather than whole
+// responses, which are long-lived here.
+func (s *Server) SetWriteTimeout(d time.Duration) {
+	s.update(func(cfg *settings) { cfg.writeTimeout = d })
+}
+
+// writeDeadline gets the deadline for a write started now, or the zero
+// time for none
+func (s *Server) writeDeadline() time.Time {
+	if d := s.WriteTimeout(); d > 0 {
+		return time.Now().Add(d)
+	}
+	return time.Time{}
+}
+
+// limitBodies caps the size of request bodies passed on to h
+func limitBodies(s *Server, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if max := s.HTTPLimits().MaxBodyBytes; max > 0 && req.Body != nil {
+			req.Body = http.MaxBytesReader(rw, req.Body, max)
+		}
+		h.ServeHTTP(rw, req)
+	})
+}
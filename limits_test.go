@@ -0,0 +1,86 @@
+package lrserver_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHTTPLimits(t *testing.T) {
+	Convey("Given a server with tight HTTP limits", t, func() {
+		srv, err := lrserver.New(lrserver.DefaultName, lrserver.DefaultHost, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.SetStatusLog(nil)
+		srv.SetErrorLog(nil)
+		So(srv.HTTPLimits(), ShouldResemble, lrserver.DefaultHTTPLimits)
+
+		srv.SetHTTPLimits(lrserver.HTTPLimits{
+			ReadHeaderTimeout: 50 * time.Millisecond,
+			MaxHeaderBytes:    1 << 10,
+		})
+		go srv.ListenAndServe()
+		time.Sleep(10 * time.Millisecond)
+		addr := fmt.Sprintf("127.0.0.1:%d", srv.Port())
+
+		Convey("clients trickling headers should be disconnected", func() {
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			fmt.Fprint(conn, "GET /livereload.js HTTP/1.1\r\nHost: localhost\r\n")
+
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			_, err = bufio.NewReader(conn).ReadByte()
+			So(err, ShouldEqual, io.EOF)
+		})
+
+		Convey("oversized headers should be rejected", func() {
+			req, err := http.NewRequest("GET", "http://"+addr+"/livereload.js", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Padding", strings.Repeat("x", 8<<10))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusRequestHeaderFieldsTooLarge)
+		})
+	})
+}
+
+func TestWriteTimeout(t *testing.T) {
+	Convey("Given a running server and a connected websocket", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		So(srv.WriteTimeout(), ShouldEqual, lrserver.DefaultWriteTimeout)
+		conn := connect(t, srv)
+		defer conn.Close()
+
+		Convey("writes missing their deadline should disconnect the client", func() {
+			srv.SetWriteTimeout(time.Nanosecond)
+			srv.Reload("index.html")
+			So(waitFor(func() bool { return srv.Status().Clients == 0 }), ShouldBeTrue)
+		})
+
+		Convey("writes within it should be sent", func() {
+			srv.SetWriteTimeout(time.Second)
+			srv.Reload("index.html")
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "index.html")
+		})
+	})
+}
@@ -0,0 +1,111 @@
+package lrserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// SetLogger sends the server's logs to l, e.g. a JSON logger for CI,
+// instead of the status and error logs. Handshake details are logged at
+// debug level, status messages such as reloads at info level, and errors
+// such as failed upgrades at error level. Setting either log, or calling
+// SetOutput, switches back to them, as does setting l to nil.
+func (s *Server) SetLogger(l *slog.Logger) {
+	s.update(func(cfg *settings) { cfg.logger = l })
+}
+
+// Logger gets the structured logger the server logs to. Unless set
+// with SetLogger, it writes info messages to the status log and
+// warnings and errors to the error log, and discards debug messages.
+func (s *Server) Logger() *slog.Logger {
+	cfg := s.settings()
+	if cfg.logger != nil {
+		return cfg.logger
+	}
+	return slog.New(&logHandler{status: cfg.statusLog, errors: cfg.errorLog})
+}
+
+func (s *Server) logDebug(msg string, args ...interface{}) {
+	s.Logger().Debug(msg, args...)
+}
+
+func (s *Server) logStatus(msg ...interface{}) {
+	s.Logger().Info(sprintln(msg...))
+}
+
+func (s *Server) logError(msg ...interface{}) {
+	s.emitError(msg...)
+	s.Logger().Error(sprintln(msg...))
+}
+
+// sprintln formats msg like log.Println
+func sprintln(msg ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(msg...), "\n")
+}
+
+// logHandler is a slog.Handler writing to the status and error logs,
+// the way the server logged before it had a structured logger
+type logHandler struct {
+	status *log.Logger
+	errors *log.Logger
+	attrs  string
+	group  string
+}
+
+func (h *logHandler) Enabled(_ context.Context, level slog.Level) bool {
+	if level >= slog.LevelWarn {
+		return h.errors != nil
+	}
+	return level >= slog.LevelInfo && h.status != nil
+}
+
+func (h *logHandler) Handle(_ context.Context, r slog.Record) error {
+	l := h.status
+	if r.Level >= slog.LevelWarn {
+		l = h.errors
+	}
+	if l == nil {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		writeAttr(&b, h.group, a)
+		return true
+	})
+	l.Println(b.String())
+	return nil
+}
+
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	b.WriteString(h.attrs)
+	for _, a := range attrs {
+		writeAttr(&b, h.group, a)
+	}
+	h2 := *h
+	h2.attrs = b.String()
+	return &h2
+}
+
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.group += name + "."
+	return &h2
+}
+
+// writeAttr appends a as " key=value"
+func writeAttr(b *strings.Builder, group string, a slog.Attr) {
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	fmt.Fprintf(b, " %s%s=%v", group, a.Key, a.Value)
+}
@@ -0,0 +1,70 @@
+package lrserver_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// syncBuffer is a bytes.Buffer safe for the server's goroutines to log to
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLogger(t *testing.T) {
+	Convey("Given a running server with a structured logger", t, func() {
+		srv := startServer(t)
+		buf := new(syncBuffer)
+		srv.SetLogger(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+		Convey("messages should be logged with levels", func() {
+			conn := connect(t, srv)
+			defer conn.Close()
+			srv.Reload("css/main.css")
+			readReload(conn)
+
+			levels := map[string]string{}
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				var rec map[string]interface{}
+				So(json.Unmarshal([]byte(line), &rec), ShouldBeNil)
+				levels[rec["msg"].(string)] = rec["level"].(string)
+				if rec["msg"] == "handshake" {
+					So(rec["protocol"], ShouldEqual, "http://livereload.com/protocols/official-8")
+				}
+			}
+			So(levels["handshake"], ShouldEqual, "DEBUG")
+			So(levels["connected: 127.0.0.1"], ShouldEqual, "INFO")
+			So(levels["requesting reload: css/main.css"], ShouldEqual, "INFO")
+		})
+
+		Convey("setting a status log should switch back to it", func() {
+			status := new(syncBuffer)
+			srv.SetStatusLog(log.New(status, "", 0))
+			srv.Reload("css/main.css")
+			time.Sleep(time.Millisecond)
+
+			So(status.String(), ShouldEqual, "requesting reload: css/main.css\n")
+			So(buf.String(), ShouldBeEmpty)
+		})
+	})
+}
@@ -7,7 +7,11 @@ Using the recommended default port 35729:
 
   http://localhost:35729/livereload.js
 
-serves the LiveReload client JavaScript, and:
+serves the LiveReload client JavaScript,
+
+  http://localhost:35729/livereload.mjs
+
+serves the same client as an ES module exporting an init function, and:
 
   ws://localhost:35729/livereload
 
@@ -1,11 +1,21 @@
 package lrserver_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -53,7 +63,7 @@ type serverAlert struct {
 
 func Test(t *testing.T) {
 	Convey("Given a new server", t, func() {
-		srv, err := lrserver.New(lrserver.DefaultName, 0)
+		srv, err := lrserver.New(lrserver.DefaultName, lrserver.DefaultHost, 0)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -64,6 +74,15 @@ func Test(t *testing.T) {
 			So(srv.ErrorLog(), ShouldHaveSameTypeAs, logger)
 		})
 
+		Convey("SetOutput() should redirect both logs", func() {
+			buf := new(bytes.Buffer)
+			srv.SetOutput(buf)
+			srv.Reload("file")
+			srv.ErrorLog().Println("oops")
+
+			So(buf.String(), ShouldEqual, "[LiveReload] requesting reload: file\n[LiveReload] oops\n")
+		})
+
 		srv.SetStatusLog(nil)
 		srv.SetErrorLog(nil)
 
@@ -71,7 +90,7 @@ func Test(t *testing.T) {
 		Convey("that is running", func() {
 			go srv.ListenAndServe()
 
-			time.Sleep(time.Nanosecond)
+			time.Sleep(10 * time.Millisecond)
 
 			Convey("a dynamically assigned port should be updated", func() {
 				So(srv.Port(), ShouldNotEqual, 0)
@@ -95,9 +114,237 @@ func Test(t *testing.T) {
 
 				bodyString := string(body)
 				So(bodyString, ShouldStartWith, "(function e(t,n,r)")
+				So(bodyString, ShouldContainSubstring, "this.https = false ||")
+				So(bodyString, ShouldContainSubstring, "window.location.protocol === 'https:'")
+				So(bodyString, ShouldContainSubstring, `(this.options.secure() ? "wss" : "ws")`)
 				So(bodyString, ShouldEndWith, "},{}]},{},[8]);")
 			})
 
+			// Test JS module
+			Convey("JS module should be served successfully", func() {
+				client := new(http.Client)
+				resp, err := client.Get(
+					fmt.Sprintf("http%s:%d/livereload.mjs", localhost, srv.Port()),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer resp.Body.Close()
+
+				body, err := ioutil.ReadAll(resp.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				bodyString := string(body)
+				So(resp.Header.Get("Content-Type"), ShouldEqual, "application/javascript")
+				So(bodyString, ShouldStartWith, "var lrRequire = (function e(t,n,r)")
+				So(bodyString, ShouldContainSubstring, "},{}]},{},[]);")
+				So(bodyString, ShouldContainSubstring, "export function init(options)")
+				So(bodyString, ShouldContainSubstring, fmt.Sprintf("this.port = %d;", srv.Port()))
+			})
+
+			// Test strict CSP mode
+			Convey("in strict CSP mode", func() {
+				srv.SetStrictCSP(true)
+
+				Convey("served scripts should contain no CSP-hostile constructs", func() {
+					for _, path := range []string{"/livereload.js", "/livereload.mjs"} {
+						resp, err := http.Get(
+							fmt.Sprintf("http%s:%d%s", localhost, srv.Port(), path),
+						)
+						if err != nil {
+							t.Fatal(err)
+						}
+						body, err := ioutil.ReadAll(resp.Body)
+						resp.Body.Close()
+						if err != nil {
+							t.Fatal(err)
+						}
+
+						So(resp.StatusCode, ShouldEqual, http.StatusOK)
+						So(resp.Header.Get("X-Content-Type-Options"), ShouldEqual, "nosniff")
+						So(lrserver.CSPViolations(string(body)), ShouldBeEmpty)
+					}
+				})
+
+				Convey("ScriptTag should only reference an external script", func() {
+					tag := srv.ScriptTag()
+					So(tag, ShouldEqual, fmt.Sprintf(
+						`<script src="http://localhost:%d/livereload.js"></script>`,
+						srv.Port(),
+					))
+					So(lrserver.CSPViolations(tag), ShouldBeEmpty)
+				})
+			})
+
+			// Test disabled JS
+			Convey("with JS disabled", func() {
+				srv.DisableJS()
+
+				Convey("JS endpoints should respond with 404", func() {
+					for _, path := range []string{"/livereload.js", "/livereload.mjs"} {
+						resp, err := http.Get(
+							fmt.Sprintf("http%s:%d%s", localhost, srv.Port(), path),
+						)
+						if err != nil {
+							t.Fatal(err)
+						}
+						resp.Body.Close()
+
+						So(srv.JSDisabled(), ShouldBeTrue)
+						So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+					}
+				})
+
+				Convey("the configured explanation should be sent", func() {
+					msg := "use the browser extension"
+					srv.SetJSDisabledMessage(msg)
+
+					resp, err := http.Get(
+						fmt.Sprintf("http%s:%d/livereload.js", localhost, srv.Port()),
+					)
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer resp.Body.Close()
+
+					body, err := ioutil.ReadAll(resp.Body)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+					So(string(body), ShouldEqual, msg+"\n")
+				})
+			})
+
+			// Test disabled alerts
+			Convey("with alerts disabled and a connected websocket", func() {
+				srv.DisableAlerts()
+				conn, hello := dial(t, srv, nil)
+				defer conn.Close()
+
+				Convey("remote control should not be advertised", func() {
+					So(srv.AlertsDisabled(), ShouldBeTrue)
+					So(hello.Protocols, ShouldNotContain, "http://livereload.com/protocols/2.x-remote-control")
+				})
+
+				Convey("alert should not be sent", func() {
+					err := conn.WriteJSON(clientHello)
+					if err != nil {
+						t.Fatal(err)
+					}
+					time.Sleep(time.Millisecond)
+
+					srv.Alert("alert")
+
+					conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+					_, _, err = conn.NextReader()
+					So(err, ShouldNotBeNil)
+					So(err, ShouldNotHaveSameTypeAs, &websocket.CloseError{})
+				})
+			})
+
+			// Test read-only mode
+			Convey("in read-only mode with a connected websocket", func() {
+				srv.SetReadOnly(true)
+				conn, _ := dial(t, srv, nil)
+				defer conn.Close()
+
+				err := conn.WriteJSON(clientHello)
+				if err != nil {
+					t.Fatal(err)
+				}
+				time.Sleep(time.Millisecond)
+
+				Convey("client messages after the handshake should be ignored", func() {
+					err := conn.WriteMessage(websocket.TextMessage, []byte("not json"))
+					if err != nil {
+						t.Fatal(err)
+					}
+					time.Sleep(time.Millisecond)
+
+					file := "file"
+					srv.Reload(file)
+
+					sr := new(serverReload)
+					err = conn.ReadJSON(sr)
+					So(srv.ReadOnly(), ShouldBeTrue)
+					So(err, ShouldBeNil)
+					So(sr.Path, ShouldEqual, file)
+				})
+			})
+
+			// Test proxy-aware addressing
+			Convey("behind a trusted proxy", func() {
+				err := srv.TrustProxy("127.0.0.0/8", "::1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				buf := new(syncBuffer)
+				srv.SetStatusLog(log.New(buf, "", 0))
+
+				Convey("the forwarded client address should be logged", func() {
+					conn, _ := dial(t, srv, http.Header{
+						"X-Forwarded-For": {"203.0.113.7, 127.0.0.2"},
+					})
+					defer conn.Close()
+
+					err := conn.WriteJSON(clientHello)
+					if err != nil {
+						t.Fatal(err)
+					}
+					time.Sleep(10 * time.Millisecond)
+
+					So(buf.String(), ShouldContainSubstring, "connected: 203.0.113.7")
+				})
+
+				Convey("X-Real-IP should be honored", func() {
+					conn, _ := dial(t, srv, http.Header{
+						"X-Real-Ip": {"203.0.113.8"},
+					})
+					defer conn.Close()
+
+					err := conn.WriteJSON(clientHello)
+					if err != nil {
+						t.Fatal(err)
+					}
+					time.Sleep(10 * time.Millisecond)
+
+					So(buf.String(), ShouldContainSubstring, "connected: 203.0.113.8")
+				})
+
+				Convey("X-Forwarded-Proto should switch the client to wss://", func() {
+					req, err := http.NewRequest("GET",
+						fmt.Sprintf("http%s:%d/livereload.js", localhost, srv.Port()),
+						nil,
+					)
+					if err != nil {
+						t.Fatal(err)
+					}
+					req.Header.Set("X-Forwarded-Proto", "https")
+
+					resp, err := http.DefaultClient.Do(req)
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer resp.Body.Close()
+
+					body, err := ioutil.ReadAll(resp.Body)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					So(string(body), ShouldContainSubstring, "this.https = true ||")
+				})
+
+				Convey("invalid proxies should be rejected", func() {
+					So(srv.TrustProxy("not an ip"), ShouldNotBeNil)
+					So(srv.TrustProxy("10.0.0.0/33"), ShouldNotBeNil)
+				})
+			})
+
 			// Connect WebSocket
 			Convey("and a connected websocket", func() {
 				dialer := new(websocket.Dialer)
@@ -121,6 +368,7 @@ func Test(t *testing.T) {
 				So(*hello, ShouldResemble, serverHello{
 					"hello",
 					[]string{
+						"http://livereload.com/protocols/official-6",
 						"http://livereload.com/protocols/official-7",
 						"http://livereload.com/protocols/official-8",
 						"http://livereload.com/protocols/official-9",
@@ -138,7 +386,7 @@ func Test(t *testing.T) {
 					}
 
 					_, _, err := conn.NextReader()
-					So(reflect.TypeOf(err).String(), ShouldEqual, "*websocket.closeError")
+					So(reflect.TypeOf(err).String(), ShouldEqual, "*websocket.CloseError")
 				})
 
 				// Send valid handshake
@@ -150,6 +398,16 @@ func Test(t *testing.T) {
 
 					time.Sleep(time.Millisecond)
 
+					Convey("its goroutines should exit when it disconnects", func() {
+						So(srv.ActiveGoroutines(), ShouldBeGreaterThan, 0)
+						conn.Close()
+
+						ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+						defer cancel()
+						So(srv.WaitIdle(ctx), ShouldBeNil)
+						So(srv.ActiveGoroutines(), ShouldEqual, 0)
+					})
+
 					Convey("a valid client message should be tolerated", func() {
 						err = conn.WriteJSON(randomMessage)
 						if err != nil {
@@ -190,6 +448,223 @@ func Test(t *testing.T) {
 						})
 					})
 
+					Convey("reload options should be sent", func() {
+						srv.ReloadWithOptions("img/logo.png", lrserver.ReloadOptions{
+							NoLiveCSS:    true,
+							NoLiveImg:    true,
+							OriginalPath: "src/logo.svg",
+							OverrideURL:  "/override",
+						})
+
+						var msg map[string]interface{}
+						err = conn.ReadJSON(&msg)
+						if err != nil {
+							t.Fatal(err)
+						}
+						So(msg, ShouldResemble, map[string]interface{}{
+							"command":      "reload",
+							"path":         "img/logo.png",
+							"liveCSS":      false,
+							"liveImg":      false,
+							"originalPath": "src/logo.svg",
+							"overrideURL":  "/override",
+						})
+					})
+
+					Convey("typed reloads should set the live flags", func() {
+						read := func() map[string]interface{} {
+							var msg map[string]interface{}
+							err := conn.ReadJSON(&msg)
+							if err != nil {
+								t.Fatal(err)
+							}
+							return msg
+						}
+
+						srv.SetLiveCSS(false)
+						srv.ReloadCSS("css/main.css")
+						msg := read()
+						So(msg["liveCSS"], ShouldEqual, true)
+						So(msg, ShouldNotContainKey, "liveImg")
+
+						srv.ReloadImage("img/logo.png")
+						msg = read()
+						So(msg["liveImg"], ShouldEqual, true)
+
+						srv.SetLiveCSS(true)
+						srv.ReloadPage("css/main.css")
+						msg = read()
+						So(msg["path"], ShouldEqual, "css/main.css")
+						So(msg["liveCSS"], ShouldEqual, false)
+						So(msg["liveImg"], ShouldEqual, false)
+					})
+
+					Convey("Status() should describe the server", func() {
+						srv.Reload("file")
+						st := srv.Status()
+
+						So(st.Name, ShouldEqual, srv.Name())
+						So(st.Addr, ShouldEqual, srv.Addr())
+						So(st.Listening, ShouldBeTrue)
+						So(st.TLS, ShouldBeFalse)
+						So(st.Uptime, ShouldBeGreaterThan, 0)
+						So(st.Clients, ShouldEqual, 1)
+						So(st.LastBroadcast, ShouldHappenWithin, time.Second, time.Now())
+						So(st.Config.LiveCSS, ShouldEqual, srv.LiveCSS())
+					})
+
+					Convey("counters should be published through expvar", func() {
+						prefix := fmt.Sprintf("lrserver_test_%d", srv.Port())
+						So(srv.PublishExpvar(prefix), ShouldBeNil)
+						So(srv.PublishExpvar(prefix), ShouldNotBeNil)
+
+						srv.Reload("file")
+						_, err := readReload(conn)
+						So(err, ShouldBeNil)
+						time.Sleep(time.Millisecond)
+
+						vars := make(map[string]int64)
+						err = json.Unmarshal([]byte(expvar.Get(prefix).String()), &vars)
+						So(err, ShouldBeNil)
+						So(vars["clients"], ShouldEqual, 1)
+						So(vars["connections"], ShouldEqual, 1)
+						So(vars["reloads"], ShouldEqual, 1)
+						So(vars["delivered"], ShouldEqual, 1)
+					})
+
+					Convey("settings should be changeable while reloading", func() {
+						done := make(chan struct{})
+						go func() {
+							defer close(done)
+							for i := 0; i < 100; i++ {
+								srv.SetLiveCSS(i%2 == 0)
+								srv.SetPathPolicy(lrserver.PathPolicy{FoldCase: i%2 == 0})
+							}
+						}()
+						for i := 0; i < 10; i++ {
+							srv.Reload("file")
+							_, err := readReload(conn)
+							So(err, ShouldBeNil)
+						}
+						<-done
+					})
+
+					// Test Windows paths
+					Convey("Windows paths should be normalized", func() {
+						srv.SetWindowsPaths(true)
+
+						for _, file := range []string{
+							`C:\site\css\main.css`,
+							`\\fileserver\share\site\css\main.css`,
+							`\\?\C:\site\css\main.css`,
+							`\\?\UNC\fileserver\share\site\css\main.css`,
+						} {
+							srv.Reload(file)
+
+							sr := new(serverReload)
+							err = conn.ReadJSON(sr)
+							if err != nil {
+								t.Fatal(err)
+							}
+							So(sr.Path, ShouldEqual, "/site/css/main.css")
+						}
+					})
+
+					// Test path policy
+					Convey("paths should be normalized according to the path policy", func() {
+						for _, c := range []struct {
+							policy lrserver.PathPolicy
+							in     string
+							out    string
+						}{
+							{lrserver.PathPolicy{}, "css//Main File.css", "css//Main File.css"},
+							{lrserver.PathPolicy{Style: lrserver.PathAbsolute}, "css//main.css", "/css/main.css"},
+							{lrserver.PathPolicy{Style: lrserver.PathRelative}, "/css/./main.css", "css/main.css"},
+							{lrserver.PathPolicy{FoldCase: true}, "CSS/Main.css", "css/main.css"},
+							{lrserver.PathPolicy{Encoding: lrserver.EncodingPercent}, "css/my file ü.css", "css/my%20file%20%C3%BC.css"},
+							{lrserver.PathPolicy{Encoding: lrserver.EncodingDecoded}, "css/my%20file.css", "css/my file.css"},
+						} {
+							srv.SetPathPolicy(c.policy)
+							srv.Reload(c.in)
+
+							sr := new(serverReload)
+							err = conn.ReadJSON(sr)
+							if err != nil {
+								t.Fatal(err)
+							}
+							So(sr.Path, ShouldEqual, c.out)
+						}
+					})
+
+					// Test web root
+					Convey("filesystem paths should be made relative to the web root", func() {
+						root := filepath.Join(os.TempDir(), "www")
+						err := srv.SetWebRoot(root)
+						if err != nil {
+							t.Fatal(err)
+						}
+						So(srv.WebRoot(), ShouldEqual, root)
+
+						for _, c := range []struct {
+							in  string
+							out string
+						}{
+							{filepath.Join(root, "css", "main.css"), "/css/main.css"},
+							{filepath.Join(root+"2", "main.css"), filepath.Join(root+"2", "main.css")},
+							{"js/app.js", "js/app.js"},
+						} {
+							srv.Reload(c.in)
+
+							sr := new(serverReload)
+							err = conn.ReadJSON(sr)
+							if err != nil {
+								t.Fatal(err)
+							}
+							So(sr.Path, ShouldEqual, c.out)
+						}
+
+						Convey("unless overridden by the web path hook", func() {
+							srv.SetWebPathFunc(func(p string) (string, bool) {
+								if filepath.Ext(p) == ".scss" {
+									return "/css/main.css", true
+								}
+								return "", false
+							})
+							srv.Reload(filepath.Join(root, "scss", "_buttons.scss"))
+
+							sr := new(serverReload)
+							err = conn.ReadJSON(sr)
+							if err != nil {
+								t.Fatal(err)
+							}
+							So(sr.Path, ShouldEqual, "/css/main.css")
+						})
+					})
+
+					// Test path mappers
+					Convey("path mappers should rewrite paths in order", func() {
+						srv.AddPathMapper(lrserver.MapPrefix("dist/assets/css", "/static"))
+						srv.AddPathMapper(func(p string) string { return strings.TrimSuffix(p, ".gz") })
+
+						for _, c := range []struct {
+							in  string
+							out string
+						}{
+							{"./dist/assets/css/app.css", "/static/app.css"},
+							{"dist/assets/css/vendor/base.css.gz", "/static/vendor/base.css"},
+							{"dist/assets/cssx/app.css", "dist/assets/cssx/app.css"},
+						} {
+							srv.Reload(c.in)
+
+							sr := new(serverReload)
+							err = conn.ReadJSON(sr)
+							if err != nil {
+								t.Fatal(err)
+							}
+							So(sr.Path, ShouldEqual, c.out)
+						}
+					})
+
 					// Test alert
 					Convey("alert should work", func() {
 						msg := "alert"
@@ -206,8 +681,580 @@ func Test(t *testing.T) {
 							msg,
 						})
 					})
+
+					Convey("alert options should be sent along", func() {
+						srv.AlertWithOptions("build failed", lrserver.AlertOptions{
+							Level:    lrserver.AlertError,
+							Duration: 2 * time.Second,
+							Sticky:   true,
+						})
+
+						var sa map[string]interface{}
+						err = conn.ReadJSON(&sa)
+						if err != nil {
+							t.Fatal(err)
+						}
+						So(sa, ShouldResemble, map[string]interface{}{
+							"command":  "alert",
+							"message":  "build failed",
+							"level":    "error",
+							"duration": float64(2000),
+							"sticky":   true,
+						})
+					})
 				})
 			})
 		})
 	})
 }
+
+func TestAttach(t *testing.T) {
+	Convey("Given a server attached to an existing mux", t, func() {
+		srv, err := lrserver.New(lrserver.DefaultName, lrserver.DefaultHost, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.SetStatusLog(nil)
+		srv.SetErrorLog(nil)
+
+		mux := http.NewServeMux()
+		lrserver.Attach(mux, srv)
+		app := httptest.NewServer(mux)
+		defer app.Close()
+
+		appURL, err := url.Parse(app.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		Convey("JS should target the page's own host and port", func() {
+			resp, err := http.Get(app.URL + "/livereload.js")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			bodyString := string(body)
+			So(bodyString, ShouldContainSubstring, fmt.Sprintf(`this.host = "%s";`, appURL.Hostname()))
+			So(bodyString, ShouldContainSubstring, fmt.Sprintf(`this.port = %s;`, appURL.Port()))
+		})
+
+		Convey("ScriptTag should reference the script relative to the page", func() {
+			So(srv.ScriptTag(), ShouldEqual, `<script src="/livereload.js"></script>`)
+		})
+
+		Convey("reload should reach websockets connected through the mux", func() {
+			conn, _, err := websocket.DefaultDialer.Dial(
+				"ws://"+appURL.Host+"/livereload",
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			hello := new(serverHello)
+			err = conn.ReadJSON(hello)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = conn.WriteJSON(clientHello)
+			if err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(time.Millisecond)
+
+			file := "file"
+			srv.Reload(file)
+
+			sr := new(serverReload)
+			err = conn.ReadJSON(sr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			So(sr.Path, ShouldEqual, file)
+		})
+	})
+}
+
+func TestHandler(t *testing.T) {
+	Convey("Given a server mounted as a handler under another router", t, func() {
+		srv, err := lrserver.New(lrserver.DefaultName, lrserver.DefaultHost, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.SetStatusLog(nil)
+		srv.SetErrorLog(nil)
+
+		lr := srv.Handler()
+		app := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if strings.HasPrefix(req.URL.Path, "/livereload") {
+				lr.ServeHTTP(rw, req)
+				return
+			}
+			fmt.Fprint(rw, "app")
+		}))
+		defer app.Close()
+
+		appURL, err := url.Parse(app.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		Convey("JS should target the page's own host and port", func() {
+			resp, err := http.Get(app.URL + "/livereload.js")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			So(string(body), ShouldContainSubstring, fmt.Sprintf(`this.port = %s;`, appURL.Port()))
+		})
+
+		Convey("websockets should connect through it", func() {
+			conn, _, err := websocket.DefaultDialer.Dial("ws://"+appURL.Host+"/livereload", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			So(conn.ReadJSON(new(serverHello)), ShouldBeNil)
+		})
+
+		Convey("unknown paths should not be found", func() {
+			resp, err := http.Get(app.URL + "/livereload/nothing")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
+
+func TestEpoch(t *testing.T) {
+	Convey("Given a running server with an epoch", t, func() {
+		srv := startServer(t)
+		srv.SetEpoch("build-2")
+
+		hello := func(epoch string) interface{} {
+			return map[string]interface{}{
+				"command":   clientHello.Command,
+				"protocols": clientHello.Protocols,
+				"epoch":     epoch,
+			}
+		}
+
+		Convey("clients from an earlier run should reload the page", func() {
+			conn, _ := dial(t, srv, nil)
+			defer conn.Close()
+			err := conn.WriteJSON(hello("build-1"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(*sr, ShouldResemble, serverReload{"reload", "", false})
+		})
+
+		Convey("clients from the current run should be left alone", func() {
+			conn, _ := dial(t, srv, nil)
+			defer conn.Close()
+			err := conn.WriteJSON(hello("build-2"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(10 * time.Millisecond)
+
+			srv.Reload("file")
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "file")
+		})
+	})
+}
+
+func TestVersionSkew(t *testing.T) {
+	Convey("Given a running server alerting stale scripts", t, func() {
+		srv := startServer(t)
+		srv.SetAlertStaleScripts(true)
+		buf := new(bytes.Buffer)
+		srv.SetErrorLog(log.New(buf, "", 0))
+
+		hello := func(ver, ext string) interface{} {
+			return map[string]interface{}{
+				"command":   clientHello.Command,
+				"protocols": clientHello.Protocols,
+				"ver":       ver,
+				"ext":       ext,
+			}
+		}
+
+		Convey("pages running an older script should be warned", func() {
+			conn, _ := dial(t, srv, nil)
+			defer conn.Close()
+			err := conn.WriteJSON(hello("2.0.0", ""))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sa := new(serverAlert)
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			So(conn.ReadJSON(sa), ShouldBeNil)
+			So(sa.Message, ShouldContainSubstring, "stale livereload.js 2.0.0")
+			So(buf.String(), ShouldContainSubstring, "stale livereload.js 2.0.0")
+		})
+
+		Convey("browser extensions should be left alone", func() {
+			conn, _ := dial(t, srv, nil)
+			defer conn.Close()
+			err := conn.WriteJSON(hello("2.0.0", "Chrome"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(10 * time.Millisecond)
+
+			So(buf.String(), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestProtocolNegotiation(t *testing.T) {
+	Convey("Given a running server", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		conn, _ := dial(t, srv, nil)
+		defer conn.Close()
+
+		Convey("the newest protocol both sides speak should be chosen and exposed", func() {
+			So(conn.WriteJSON(map[string]interface{}{
+				"command": "hello",
+				"protocols": []string{
+					"http://livereload.com/protocols/official-7",
+					"http://livereload.com/protocols/official-8",
+					"http://livereload.com/protocols/official-10",
+				},
+			}), ShouldBeNil)
+			So(waitFor(func() bool { return len(srv.Connections()) == 1 }), ShouldBeTrue)
+			c := srv.Connections()[0]
+			So(c.Protocol, ShouldEqual, "http://livereload.com/protocols/official-8")
+			So(c.ProtocolVersion, ShouldEqual, 8)
+		})
+
+		Convey("with a protocol 6 client", func() {
+			So(conn.WriteJSON(map[string]interface{}{
+				"command":   "hello",
+				"protocols": []string{"http://livereload.com/protocols/official-6"},
+			}), ShouldBeNil)
+			So(waitFor(func() bool { return len(srv.Connections()) == 1 }), ShouldBeTrue)
+
+			Convey("the negotiated version should be exposed", func() {
+				c := srv.Connections()[0]
+				So(c.Protocol, ShouldEqual, "http://livereload.com/protocols/official-6")
+				So(c.ProtocolVersion, ShouldEqual, 6)
+			})
+
+			Convey("reloads should be sent in the array format, skipping alerts", func() {
+				srv.Alert("not for protocol 6")
+				srv.Reload("css/main.css")
+
+				var msg []interface{}
+				conn.SetReadDeadline(time.Now().Add(time.Second))
+				So(conn.ReadJSON(&msg), ShouldBeNil)
+				So(msg, ShouldResemble, []interface{}{"refresh", map[string]interface{}{
+					"path":           "css/main.css",
+					"apply_js_live":  false,
+					"apply_css_live": true,
+				}})
+			})
+		})
+	})
+}
+
+func TestAliases(t *testing.T) {
+	Convey("Given a running server with endpoint aliases", t, func() {
+		srv := startServer(t)
+		So(srv.AliasWebSocket("/ws/livereload"), ShouldBeNil)
+		So(srv.AliasScript("/js/livereload.js"), ShouldBeNil)
+
+		Convey("websockets connected through an alias should get reloads", func() {
+			conn, _, err := new(websocket.Dialer).Dial(
+				fmt.Sprintf("ws%s:%d/ws/livereload", localhost, srv.Port()),
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			So(conn.ReadJSON(new(serverHello)), ShouldBeNil)
+			So(conn.WriteJSON(clientHello), ShouldBeNil)
+			time.Sleep(10 * time.Millisecond)
+
+			srv.Reload("file")
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "file")
+		})
+
+		Convey("the script should be served at its alias", func() {
+			resp, err := http.Get(fmt.Sprintf("http%s:%d/js/livereload.js", localhost, srv.Port()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("aliases should also be mounted on attached muxes", func() {
+			mux := http.NewServeMux()
+			lrserver.Attach(mux, srv)
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest("GET", "/js/livereload.js", nil))
+			So(rec.Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("invalid or duplicate aliases should be rejected", func() {
+			So(srv.AliasWebSocket("ws"), ShouldNotBeNil)
+			So(srv.AliasWebSocket("/livereload"), ShouldNotBeNil)
+			So(srv.AliasScript("/js/livereload.js"), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestEndpointInfo(t *testing.T) {
+	Convey("Given a running server", t, func() {
+		srv := startServer(t)
+		url := fmt.Sprintf("http%s:%d/livereload", localhost, srv.Port())
+
+		Convey("browsers navigating to the websocket should get an explanation", func() {
+			req, err := http.NewRequest("GET", url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept", "text/html,application/xhtml+xml")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+
+			So(resp.StatusCode, ShouldEqual, http.StatusUpgradeRequired)
+			So(resp.Header.Get("Content-Type"), ShouldStartWith, "text/html")
+			So(string(body), ShouldContainSubstring, "websocket endpoint")
+			So(string(body), ShouldContainSubstring, "&lt;script src=&#34;"+srv.ScriptURL())
+		})
+
+		Convey("failed upgrades should name the cause", func() {
+			buf := new(bytes.Buffer)
+			srv.SetErrorLog(log.New(buf, "", 0))
+
+			upgrade := func(header http.Header) (*http.Response, string) {
+				req, err := http.NewRequest("GET", url, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header = header
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer resp.Body.Close()
+				body, _ := ioutil.ReadAll(resp.Body)
+				return resp, string(body)
+			}
+
+			resp, body := upgrade(http.Header{
+				"Upgrade":               {"websocket"},
+				"Sec-Websocket-Version": {"13"},
+				"Sec-Websocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+			})
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(body, ShouldContainSubstring, "Connection: upgrade header is missing")
+			So(buf.String(), ShouldContainSubstring, "Connection: upgrade header is missing")
+
+			resp, body = upgrade(http.Header{
+				"Upgrade":               {"websocket"},
+				"Connection":            {"keep-alive, Upgrade"},
+				"Sec-Websocket-Version": {"8"},
+				"Sec-Websocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+			})
+			So(resp.StatusCode, ShouldEqual, http.StatusUpgradeRequired)
+			So(resp.Header.Get("Sec-Websocket-Version"), ShouldEqual, "13")
+			So(body, ShouldContainSubstring, `websocket version "8"`)
+		})
+
+		Convey("other clients should get JSON", func() {
+			resp, err := http.Get(url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			info := map[string]interface{}{}
+			So(json.NewDecoder(resp.Body).Decode(&info), ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusUpgradeRequired)
+			So(info["scriptURL"], ShouldEqual, srv.ScriptURL())
+		})
+	})
+}
+
+func TestShutdown(t *testing.T) {
+	Convey("Given a running server with a connected websocket", t, func() {
+		srv, err := lrserver.New(lrserver.DefaultName, lrserver.DefaultHost, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.SetStatusLog(nil)
+		srv.SetErrorLog(nil)
+		served := make(chan error, 1)
+		go func() {
+			served <- srv.ListenAndServe()
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		conn := connect(t, srv)
+		defer conn.Close()
+
+		Convey("Shutdown should send queued messages before closing", func() {
+			srv.Reload("css/main.css")
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			So(srv.Shutdown(ctx), ShouldBeNil)
+			So(srv.ActiveGoroutines(), ShouldEqual, 0)
+			So(<-served, ShouldEqual, http.ErrServerClosed)
+
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "css/main.css")
+			_, _, err = conn.NextReader()
+			So(websocket.IsCloseError(err, websocket.CloseGoingAway), ShouldBeTrue)
+		})
+
+		Convey("Close should close connections immediately", func() {
+			So(srv.Close(), ShouldBeNil)
+			So(<-served, ShouldEqual, http.ErrServerClosed)
+
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			_, _, err := conn.NextReader()
+			So(websocket.IsCloseError(err, websocket.CloseGoingAway), ShouldBeTrue)
+		})
+	})
+}
+
+func TestServe(t *testing.T) {
+	Convey("Given a server", t, func() {
+		srv, err := lrserver.New(lrserver.DefaultName, lrserver.DefaultHost, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.SetStatusLog(nil)
+		srv.SetErrorLog(nil)
+		defer srv.Close()
+
+		Convey("serving on a TCP listener should take its port", func() {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			go srv.Serve(l)
+			time.Sleep(10 * time.Millisecond)
+
+			So(srv.Port(), ShouldEqual, l.Addr().(*net.TCPAddr).Port)
+		})
+
+		Convey("serving on a Unix socket should target the page's host", func() {
+			dir, err := ioutil.TempDir("", "lrserver")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			sock := filepath.Join(dir, "lr.sock")
+			l, err := net.Listen("unix", sock)
+			if err != nil {
+				t.Fatal(err)
+			}
+			go srv.Serve(l)
+			time.Sleep(10 * time.Millisecond)
+
+			client := &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return new(net.Dialer).DialContext(ctx, "unix", sock)
+				},
+			}}
+			resp, err := client.Get("http://app.test:8080/livereload.js")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			So(string(body), ShouldContainSubstring, `this.host = "app.test";`)
+			So(string(body), ShouldContainSubstring, `this.port = 8080;`)
+		})
+	})
+}
+
+// dial connects a websocket to srv and reads the server hello
+func dial(t *testing.T, srv *lrserver.Server, header http.Header) (*websocket.Conn, *serverHello) {
+	dialer := new(websocket.Dialer)
+	conn, _, err := dialer.Dial(
+		fmt.Sprintf("ws%s:%d/livereload", localhost, srv.Port()),
+		header,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hello := new(serverHello)
+	err = conn.ReadJSON(hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, hello
+}
+
+// startServer creates a quiet server on a dynamic port and starts it
+func startServer(t *testing.T) *lrserver.Server {
+	srv, err := lrserver.New(lrserver.DefaultName, lrserver.DefaultHost, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.SetStatusLog(nil)
+	srv.SetErrorLog(nil)
+	go srv.ListenAndServe()
+	time.Sleep(10 * time.Millisecond)
+	return srv
+}
+
+// connect dials srv and completes the handshake
+func connect(t *testing.T, srv *lrserver.Server) *websocket.Conn {
+	conn, _ := dial(t, srv, nil)
+	err := conn.WriteJSON(clientHello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	return conn
+}
+
+// readReload reads the next reload message from conn, waiting up to a second
+func readReload(conn *websocket.Conn) (*serverReload, error) {
+	sr := new(serverReload)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	return sr, conn.ReadJSON(sr)
+}
@@ -0,0 +1,59 @@
+package lrserver
+
+import (
+	"net/url"
+)
+
+// AlertMatching sends an alert only to the clients whose page URL path
+// matches urlPattern, a glob like those of commands: /graphql/** matches
+// every page under /graphql. Page URLs are reported by clients after the
+// handshake, so nothing reaches clients that haven't, nor any in
+// read-only mode.
+func (s *Server) AlertMatching(urlPattern, msg string) error {
+	glob, err := newGlobMatcher(urlPattern)
+	if err != nil {
+		return err
+	}
+	if s.AlertsDisabled() {
+		s.logStatus("ignoring alert (alerts disabled): " + msg)
+		return nil
+	}
+
+	s.logStatus("requesting alert for " + urlPattern + ": " + msg)
+	s.broadcastTo(pageMatcher(glob), makeServerAlert(msg), Event{Command: "alert", Message: msg})
+	s.emit(Event{Type: EventAlert, Message: msg})
+	s.notify("alert", "", msg)
+	return nil
+}
+
+// ReloadMatching reloads file only for the clients whose page URL path
+// matches urlPattern, as for AlertMatching, e.g. the pages rendered from
+// a changed template. Like Reload, it's held back while paused, and runs
+// the build hook and matching commands first.
+func (s *Server) ReloadMatching(urlPattern, file string) error {
+	glob, err := newGlobMatcher(urlPattern)
+	if err != nil {
+		return err
+	}
+	s.reloadTo(pageMatcher(glob), file, ReloadOptions{})
+	return nil
+}
+
+// pageMatcher selects the connections whose page URL path matches glob
+func pageMatcher(glob *globMatcher) func(*conn) bool {
+	return func(c *conn) bool {
+		raw := c.pageURL()
+		if raw == "" {
+			return false
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			return false
+		}
+		p := u.Path
+		if p == "" {
+			p = "/"
+		}
+		return glob.match(p)
+	}
+}
@@ -0,0 +1,96 @@
+package lrserver_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// visit connects a client reporting url as its page
+func visit(t *testing.T, srv *lrserver.Server, url string) *websocket.Conn {
+	conn := connect(t, srv)
+	err := conn.WriteJSON(map[string]interface{}{
+		"command": "info",
+		"url":     url,
+		"plugins": map[string]interface{}{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	return conn
+}
+
+func TestMatching(t *testing.T) {
+	Convey("Given clients on different pages", t, func() {
+		srv := startServer(t)
+		console := visit(t, srv, "http://localhost:8080/graphql/console")
+		defer console.Close()
+		home := visit(t, srv, "http://localhost:8080/index.html")
+		defer home.Close()
+
+		Convey("matching alerts should only reach the matching pages", func() {
+			So(srv.AlertMatching("/graphql/**", "API schema changed"), ShouldBeNil)
+			srv.Reload("file")
+
+			msg := map[string]interface{}{}
+			console.SetReadDeadline(time.Now().Add(time.Second))
+			So(console.ReadJSON(&msg), ShouldBeNil)
+			So(msg["command"], ShouldEqual, "alert")
+			So(msg["message"], ShouldEqual, "API schema changed")
+
+			sr, err := readReload(home)
+			So(err, ShouldBeNil)
+			So(sr.Command, ShouldEqual, "reload")
+		})
+
+		Convey("matching reloads should only reach the matching pages", func() {
+			So(srv.ReloadMatching("/graphql/**", "templates/console.html"), ShouldBeNil)
+			srv.Reload("file")
+
+			sr, err := readReload(console)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "templates/console.html")
+
+			sr, err = readReload(home)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "file")
+		})
+
+		Convey("matching reloads should be held while paused", func() {
+			srv.Pause()
+			So(srv.ReloadMatching("/graphql/**", "templates/console.html"), ShouldBeNil)
+			So(srv.AlertMatching("/graphql/**", "building"), ShouldBeNil)
+
+			msg := map[string]interface{}{}
+			console.SetReadDeadline(time.Now().Add(time.Second))
+			So(console.ReadJSON(&msg), ShouldBeNil)
+			So(msg["command"], ShouldEqual, "alert")
+
+			srv.Resume()
+			sr, err := readReload(console)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "templates/console.html")
+		})
+
+		Convey("matching reloads should run the build hook", func() {
+			var built []string
+			srv.SetBuildHook(func(changed []string) error {
+				built = append(built, changed...)
+				return nil
+			})
+			So(srv.ReloadMatching("/graphql/**", "templates/console.html"), ShouldBeNil)
+			_, err := readReload(console)
+			So(err, ShouldBeNil)
+			So(built, ShouldResemble, []string{"templates/console.html"})
+		})
+
+		Convey("invalid patterns should be rejected", func() {
+			So(srv.AlertMatching("[", "oops"), ShouldNotBeNil)
+			So(srv.ReloadMatching("[", "file"), ShouldNotBeNil)
+		})
+	})
+}
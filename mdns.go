@@ -0,0 +1,373 @@
+package lrserver
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MDNSService is the DNS-SD service type servers are announced as
+const MDNSService = "_livereload._tcp"
+
+// mdnsAddr is the IPv4 multicast group mDNS runs on
+var mdnsAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// DNS record types and classes used by the responder
+const (
+	dnsTypeA    = 1
+	dnsTypePTR  = 12
+	dnsTypeTXT  = 16
+	dnsTypeAAAA = 28
+	dnsTypeSRV  = 33
+	dnsTypeANY  = 255
+
+	dnsClassIN         = 1
+	dnsClassCacheFlush = 0x8000
+
+	// mdnsTTL is how long announced records may be cached, in seconds
+	mdnsTTL = 120
+)
+
+// announcer tracks a server's mDNS announcement
+type announcer struct {
+	mu        sync.Mutex
+	enabled   bool
+	responder *mdnsResponder
+}
+
+// Announce advertises the server over mDNS as a _livereload._tcp service
+// while it's serving, so devices on the LAN, like phones used for
+// testing, can discover its host and port. The service is registered
+// once ListenAndServe or Serve starts, or straight away if it already
+// has, and deregistered when the server stops. The TXT record gives the
+// websocket path and the script URL path. Servers listening on loopback
+// can't be reached from other devices, so announcing them is of little
+// use.
+func (s *Server) Announce() error {
+	s.announcer.mu.Lock()
+	defer s.announcer.mu.Unlock()
+	s.announcer.enabled = true
+	if l, ok := s.listener.Load().(*listenerInfo); !ok || l == nil || s.announcer.responder != nil {
+		return nil
+	}
+	return s.startAnnouncing()
+}
+
+// announceServing registers the service if Announce was called, once the
+// server starts serving
+func (s *Server) announceServing() {
+	s.announcer.mu.Lock()
+	defer s.announcer.mu.Unlock()
+	if !s.announcer.enabled || s.announcer.responder != nil {
+		return
+	}
+	if err := s.startAnnouncing(); err != nil {
+		s.logError("announcing over mDNS:", err)
+	}
+}
+
+// startAnnouncing starts the responder. The announcer must be locked.
+func (s *Server) startAnnouncing() error {
+	zone, err := s.mdnsZone()
+	if err != nil {
+		return err
+	}
+	r, err := startMDNSResponder(zone, s.logError)
+	if err != nil {
+		return err
+	}
+	s.announcer.responder = r
+	s.logStatus("announcing " + zone.instance + " over mDNS")
+	return nil
+}
+
+// stopAnnouncing deregisters the service, if it's registered
+func (s *Server) stopAnnouncing() {
+	s.announcer.mu.Lock()
+	r := s.announcer.responder
+	s.announcer.responder = nil
+	s.announcer.mu.Unlock()
+	if r != nil {
+		r.stop()
+	}
+}
+
+// mdnsZone describes the records announcing the server
+type mdnsZone struct {
+	instance string
+	service  []string
+	name     []string
+	host     []string
+	port     uint16
+	txt      []string
+	ips      []net.IP
+}
+
+// mdnsZone gets the records announcing the server as it's configured
+func (s *Server) mdnsZone() (*mdnsZone, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	if i := strings.IndexByte(hostname, '.'); i > 0 {
+		hostname = hostname[:i]
+	}
+	ips, err := announcedIPs(s.Host())
+	if err != nil {
+		return nil, err
+	}
+
+	instance := s.Name() + " on " + hostname
+	if len(instance) > 63 {
+		instance = instance[:63]
+	}
+	service := []string{"_livereload", "_tcp", "local"}
+	return &mdnsZone{
+		instance: instance,
+		service:  service,
+		name:     append([]string{instance}, service...),
+		host:     []string{hostname, "local"},
+		port:     s.Port(),
+		txt:      []string{"path=" + s.prefix + "/livereload", "script=" + s.scriptPath},
+		ips:      ips,
+	}, nil
+}
+
+// announcedIPs gets the addresses to announce for a server listening on
+// host: host itself if it's an address, or else those of every interface
+// that's up, leaving out loopback ones if there are others
+func announcedIPs(host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() {
+		return []net.IP{ip}, nil
+	}
+	if host != "" && net.ParseIP(host) == nil {
+		return net.LookupIP(host)
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	var ips, loopback []net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		switch {
+		case !ok || ipNet.IP.IsLinkLocalUnicast():
+		case ipNet.IP.IsLoopback():
+			loopback = append(loopback, ipNet.IP)
+		default:
+			ips = append(ips, ipNet.IP)
+		}
+	}
+	if len(ips) == 0 {
+		ips = loopback
+	}
+	if len(ips) == 0 {
+		return nil, errors.New("lrserver: no addresses to announce")
+	}
+	return ips, nil
+}
+
+// records encodes the zone's records as an mDNS response, with ttl in
+// seconds; zero says goodbye
+func (z *mdnsZone) records(ttl uint32) []byte {
+	var answers, additional [][]byte
+	answers = append(answers, dnsRecord(z.service, dnsTypePTR, dnsClassIN, ttl, appendDNSName(nil, z.name)))
+
+	srv := make([]byte, 6)
+	binary.BigEndian.PutUint16(srv[4:], z.port)
+	additional = append(additional, dnsRecord(z.name, dnsTypeSRV, dnsClassIN|dnsClassCacheFlush, ttl, appendDNSName(srv, z.host)))
+
+	var txt []byte
+	for _, t := range z.txt {
+		txt = append(append(txt, byte(len(t))), t...)
+	}
+	additional = append(additional, dnsRecord(z.name, dnsTypeTXT, dnsClassIN|dnsClassCacheFlush, ttl, txt))
+
+	for _, ip := range z.ips {
+		if v4 := ip.To4(); v4 != nil {
+			additional = append(additional, dnsRecord(z.host, dnsTypeA, dnsClassIN|dnsClassCacheFlush, ttl, v4))
+		} else {
+			additional = append(additional, dnsRecord(z.host, dnsTypeAAAA, dnsClassIN|dnsClassCacheFlush, ttl, ip.To16()))
+		}
+	}
+
+	// Authoritative response header
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg[2:], 0x8400)
+	binary.BigEndian.PutUint16(msg[6:], uint16(len(answers)))
+	binary.BigEndian.PutUint16(msg[10:], uint16(len(additional)))
+	for _, rr := range append(answers, additional...) {
+		msg = append(msg, rr...)
+	}
+	return msg
+}
+
+// answers reports whether a query asks for any of the zone's records,
+// including through DNS-SD service enumeration
+func (z *mdnsZone) answers(query []byte) bool {
+	if len(query) < 12 || query[2]&0x80 != 0 {
+		return false
+	}
+	questions := int(binary.BigEndian.Uint16(query[4:]))
+	off := 12
+	for i := 0; i < questions; i++ {
+		name, next, ok := readDNSName(query, off)
+		if !ok || next+4 > len(query) {
+			return false
+		}
+		qtype := binary.BigEndian.Uint16(query[next:])
+		off = next + 4
+
+		switch {
+		case labelsEqual(name, z.service), labelsEqual(name, []string{"_services", "_dns-sd", "_udp", "local"}):
+			if qtype == dnsTypePTR || qtype == dnsTypeANY {
+				return true
+			}
+		case labelsEqual(name, z.name):
+			if qtype == dnsTypeSRV || qtype == dnsTypeTXT || qtype == dnsTypeANY {
+				return true
+			}
+		case labelsEqual(name, z.host):
+			if qtype == dnsTypeA || qtype == dnsTypeAAAA || qtype == dnsTypeANY {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dnsRecord encodes a resource record
+func dnsRecord(name []string, rtype, class uint16, ttl uint32, data []byte) []byte {
+	rr := appendDNSName(nil, name)
+	fixed := make([]byte, 10)
+	binary.BigEndian.PutUint16(fixed, rtype)
+	binary.BigEndian.PutUint16(fixed[2:], class)
+	binary.BigEndian.PutUint32(fixed[4:], ttl)
+	binary.BigEndian.PutUint16(fixed[8:], uint16(len(data)))
+	return append(append(rr, fixed...), data...)
+}
+
+// appendDNSName appends a name encoded as uncompressed labels to b
+func appendDNSName(b []byte, labels []string) []byte {
+	for _, l := range labels {
+		b = append(append(b, byte(len(l))), l...)
+	}
+	return append(b, 0)
+}
+
+// readDNSName decodes the possibly compressed name at off in msg,
+// returning its labels and the offset just past it
+func readDNSName(msg []byte, off int) ([]string, int, bool) {
+	var labels []string
+	end := -1
+	for jumps := 0; jumps < 16; {
+		if off >= len(msg) {
+			return nil, 0, false
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return labels, end, true
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(msg) {
+				return nil, 0, false
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+			jumps++
+		default:
+			if off+1+n > len(msg) {
+				return nil, 0, false
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+	return nil, 0, false
+}
+
+// labelsEqual compares names case-insensitively, as DNS does
+func labelsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// mdnsResponder answers mDNS queries for a zone until stopped
+type mdnsResponder struct {
+	zone *mdnsZone
+	conn *net.UDPConn
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// startMDNSResponder joins the mDNS group and announces zone
+func startMDNSResponder(zone *mdnsZone, logError func(...interface{})) (*mdnsResponder, error) {
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsAddr)
+	if err != nil {
+		return nil, err
+	}
+	r := &mdnsResponder{zone: zone, conn: conn, done: make(chan struct{})}
+	r.wg.Add(2)
+	go r.respond(logError)
+	go r.announce()
+	return r, nil
+}
+
+// announce sends the records unsolicited, twice as RFC 6762 asks, so
+// browsing devices pick the service up without waiting to query again
+func (r *mdnsResponder) announce() {
+	defer r.wg.Done()
+	for i := 0; i < 2; i++ {
+		r.conn.WriteToUDP(r.zone.records(mdnsTTL), mdnsAddr)
+		select {
+		case <-r.done:
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// respond answers queries for the zone's records
+func (r *mdnsResponder) respond(logError func(...interface{})) {
+	defer r.wg.Done()
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-r.done:
+			default:
+				logError("mDNS:", err)
+			}
+			return
+		}
+		if r.zone.answers(buf[:n]) {
+			r.conn.WriteToUDP(r.zone.records(mdnsTTL), mdnsAddr)
+		}
+	}
+}
+
+// stop says goodbye, so devices drop the service, and leaves the group
+func (r *mdnsResponder) stop() {
+	close(r.done)
+	r.conn.WriteToUDP(r.zone.records(0), mdnsAddr)
+	r.conn.Close()
+	r.wg.Wait()
+}
@@ -0,0 +1,85 @@
+package lrserver_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// mdnsQuery encodes a query for name with qtype
+func mdnsQuery(name string, qtype uint16) []byte {
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg[4:], 1)
+	for _, label := range strings.Split(name, ".") {
+		msg = append(append(msg, byte(len(label))), label...)
+	}
+	msg = append(msg, 0, byte(qtype>>8), byte(qtype), 0, 1)
+	return msg
+}
+
+func TestAnnounce(t *testing.T) {
+	Convey("Given a server listening on an address", t, func() {
+		srv, err := lrserver.NewServer(lrserver.WithName("site"), lrserver.WithHost("192.0.2.7"), lrserver.WithPort(35729))
+		So(err, ShouldBeNil)
+		defer srv.Close()
+
+		Convey("its records should describe the service", func() {
+			msg, err := lrserver.MDNSRecords(srv)
+			So(err, ShouldBeNil)
+			So(binary.BigEndian.Uint16(msg[6:]), ShouldEqual, 1)
+			So(binary.BigEndian.Uint16(msg[10:]), ShouldEqual, 3)
+			So(bytes.Contains(msg, []byte("\x0b_livereload\x04_tcp\x05local\x00")), ShouldBeTrue)
+			So(bytes.Contains(msg, []byte("site on ")), ShouldBeTrue)
+			So(bytes.Contains(msg, []byte{0, 0, 0, 0, 0x8b, 0x91}), ShouldBeTrue)
+			So(bytes.Contains(msg, []byte("\x10path=/livereload")), ShouldBeTrue)
+			So(bytes.Contains(msg, []byte{192, 0, 2, 7}), ShouldBeTrue)
+		})
+
+		Convey("it should answer browsing for the service", func() {
+			for _, c := range []struct {
+				name  string
+				qtype uint16
+				ok    bool
+			}{
+				{"_livereload._tcp.local", 12, true},
+				{"_LiveReload._tcp.local", 255, true},
+				{"_services._dns-sd._udp.local", 12, true},
+				{"_livereload._tcp.local", 1, false},
+				{"_http._tcp.local", 12, false},
+			} {
+				ok, err := lrserver.MDNSAnswers(srv, mdnsQuery(c.name, c.qtype))
+				So(err, ShouldBeNil)
+				So(ok, ShouldEqual, c.ok)
+			}
+		})
+
+		Convey("it should ignore malformed queries and responses", func() {
+			query := mdnsQuery("_livereload._tcp.local", 12)
+			ok, _ := lrserver.MDNSAnswers(srv, query[:20])
+			So(ok, ShouldBeFalse)
+			query[2] |= 0x80
+			ok, _ = lrserver.MDNSAnswers(srv, query)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("announcing before it serves should wait for it", func() {
+			So(srv.Announce(), ShouldBeNil)
+		})
+	})
+
+	Convey("Given a running server", t, func() {
+		srv := startServer(t)
+
+		Convey("announcing should register it straight away", func() {
+			if err := srv.Announce(); err != nil {
+				t.Skip("can't join the mDNS group here:", err)
+			}
+			So(srv.Announce(), ShouldBeNil)
+			So(srv.Close(), ShouldBeNil)
+		})
+	})
+}
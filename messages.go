@@ -1,19 +1,75 @@
 package lrserver
 
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+const officialProtocolPrefix = "http://livereload.com/protocols/official-"
+
+const remoteControlProtocol = "http://livereload.com/protocols/2.x-remote-control"
+
+// legacyProtocol is the protocol of LiveReload 1.x clients, such as older
+// command line tools and editor plugins, whose messages are arrays
+const legacyProtocol = officialProtocolPrefix + "6"
+
 var protocols = []string{
+	legacyProtocol,
 	"http://livereload.com/protocols/official-7",
 	"http://livereload.com/protocols/official-8",
 	"http://livereload.com/protocols/official-9",
 	"http://livereload.com/protocols/2.x-origin-version-negotiation",
-	"http://livereload.com/protocols/2.x-remote-control",
+	remoteControlProtocol,
 }
 
-type clientHello struct {
-	Command   string   `json:"command"`
-	Protocols []string `json:"protocols"`
+// maxMessageSize limits the size of client messages
+const maxMessageSize = 64 * 1024
+
+var (
+	errBinaryMessage  = errors.New("lrserver: binary messages are not supported")
+	errMissingCommand = errors.New("lrserver: message has no command")
+)
+
+type clientMessage struct {
+	Command   string          `json:"command"`
+	Protocols []string        `json:"protocols"`
+	Epoch     string          `json:"epoch"`
+	Ver       string          `json:"ver"`
+	Ext       string          `json:"ext"`
+	ExtVer    interface{}     `json:"extver"`
+	SnipVer   interface{}     `json:"snipver"`
+	URL       string          `json:"url"`
+	Plugins   json.RawMessage `json:"plugins"`
 }
 
-func validateHello(hello *clientHello) bool {
+// decodeClientMessage decodes a websocket message from the client. If it
+// isn't valid, it returns the close code to end the connection with.
+func decodeClientMessage(msgType int, r io.Reader) (*clientMessage, int, error) {
+	// Binary instead of text
+	if msgType != websocket.TextMessage {
+		return nil, websocket.CloseUnsupportedData, errBinaryMessage
+	}
+
+	// Not JSON
+	msg := new(clientMessage)
+	err := json.NewDecoder(r).Decode(msg)
+	if err != nil {
+		return nil, websocket.ClosePolicyViolation, err
+	}
+
+	// Missing a command field
+	if msg.Command == "" {
+		return nil, websocket.ClosePolicyViolation, errMissingCommand
+	}
+	return msg, 0, nil
+}
+
+func validateHello(hello *clientMessage) bool {
 	if hello.Command != "hello" {
 		return false
 	}
@@ -27,24 +83,78 @@ func validateHello(hello *clientHello) bool {
 	return false
 }
 
+// negotiateProtocol gets the newest official protocol supported by both
+// the server and the client saying hello
+func negotiateProtocol(hello *clientMessage) string {
+	var proto string
+	for _, s := range protocols {
+		if !strings.HasPrefix(s, officialProtocolPrefix) {
+			continue
+		}
+		for _, c := range hello.Protocols {
+			if c == s {
+				proto = s
+			}
+		}
+	}
+	return proto
+}
+
+// protocolVersion gets the version number of an official protocol,
+// or zero for any other
+func protocolVersion(proto string) int {
+	if !strings.HasPrefix(proto, officialProtocolPrefix) {
+		return 0
+	}
+	v, err := strconv.Atoi(proto[len(officialProtocolPrefix):])
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// legacyMessage translates an encoded message into the protocol 6 array
+// format, returning nil for messages that protocol can't express, which
+// is anything but a reload
+func legacyMessage(data []byte) []byte {
+	var msg serverReload
+	if json.Unmarshal(data, &msg) != nil || msg.Command != "reload" {
+		return nil
+	}
+	legacy, err := json.Marshal([]interface{}{"refresh", map[string]interface{}{
+		"path":           msg.Path,
+		"apply_js_live":  false,
+		"apply_css_live": msg.LiveCSS,
+	}})
+	if err != nil {
+		return nil
+	}
+	return legacy
+}
+
 type serverHello struct {
 	Command    string   `json:"command"`
 	Protocols  []string `json:"protocols"`
 	ServerName string   `json:"serverName"`
+	Epoch      string   `json:"epoch,omitempty"`
 }
 
-func makeServerHello(name string) *serverHello {
+func makeServerHello(name string, protocols []string, epoch string) *serverHello {
 	return &serverHello{
 		"hello",
 		protocols,
 		name,
+		epoch,
 	}
 }
 
 type serverReload struct {
-	Command string `json:"command"`
-	Path    string `json:"path"`
-	LiveCSS bool   `json:"liveCSS"`
+	Command      string `json:"command"`
+	Path         string `json:"path"`
+	LiveCSS      bool   `json:"liveCSS"`
+	LiveImg      *bool  `json:"liveImg,omitempty"`
+	OriginalPath string `json:"originalPath,omitempty"`
+	OverrideURL  string `json:"overrideURL,omitempty"`
 }
 
 func makeServerReload(file string, liveCSS bool) *serverReload {
@@ -56,8 +166,11 @@ func makeServerReload(file string, liveCSS bool) *serverReload {
 }
 
 type serverAlert struct {
-	Command string `json:"command"`
-	Message string `json:"message"`
+	Command  string `json:"command"`
+	Message  string `json:"message"`
+	Level    string `json:"level,omitempty"`
+	Duration int64  `json:"duration,omitempty"`
+	Sticky   bool   `json:"sticky,omitempty"`
 }
 
 func makeServerAlert(msg string) *serverAlert {
@@ -0,0 +1,129 @@
+package lrserver
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MetricsSink receives the server's telemetry as it happens. Metric names
+// match the counters published through expvar: connections,
+// disconnections, reloads, alerts, delivered, reloadsSent, alertsSent,
+// errors, evictions, dropped, upgradeFailures and rejected are
+// incremented, clients is a gauge, and broadcast, build and command are
+// timings.
+// Calls are made synchronously, so implementations should be quick.
+type MetricsSink interface {
+	Increment(name string)
+	Gauge(name string, value float64)
+	Timing(name string, d time.Duration)
+}
+
+// eventMetrics names the counter incremented for each event type
+var eventMetrics = map[EventType]string{
+	EventConnected:    "connections",
+	EventDisconnected: "disconnections",
+	EventReload:       "reloads",
+	EventAlert:        "alerts",
+	EventDelivered:    "delivered",
+	EventError:        "errors",
+}
+
+// AddMetricsSink adds a sink receiving the server's telemetry
+func (s *Server) AddMetricsSink(m MetricsSink) {
+	s.update(func(cfg *settings) {
+		cfg.metricsSinks = append(cfg.metricsSinks[:len(cfg.metricsSinks):len(cfg.metricsSinks)], m)
+	})
+}
+
+// increment increments a counter in every sink
+func (s *Server) increment(name string) {
+	for _, m := range s.settings().metricsSinks {
+		m.Increment(name)
+	}
+}
+
+// gauge sets a gauge in every sink
+func (s *Server) gauge(name string, value float64) {
+	for _, m := range s.settings().metricsSinks {
+		m.Gauge(name, value)
+	}
+}
+
+// timing records how long something took since start in every sink
+func (s *Server) timing(name string, start time.Time) {
+	sinks := s.settings().metricsSinks
+	if len(sinks) == 0 {
+		return
+	}
+	d := s.now().Sub(start)
+	for _, m := range sinks {
+		m.Timing(name, d)
+	}
+}
+
+// record passes an event on to the metrics sinks
+func (s *Server) record(e Event) {
+	if len(s.settings().metricsSinks) == 0 {
+		return
+	}
+	if name, ok := eventMetrics[e.Type]; ok {
+		s.increment(name)
+	}
+	if e.Type == EventDelivered && (e.Command == "reload" || e.Command == "alert") {
+		s.increment(e.Command + "sSent")
+	}
+	if e.Type == EventConnected || e.Type == EventDisconnected {
+		s.gauge("clients", float64(s.conns.len()))
+	}
+}
+
+// StatsdSink sends metrics to a statsd server, such as the Datadog agent,
+// over UDP. Writes are best effort: packets that can't be sent are dropped.
+type StatsdSink struct {
+	prefix string
+	mu     sync.Mutex
+	conn   net.Conn
+}
+
+// NewStatsdSink creates a sink sending to the statsd server at addr, with
+// every metric name prefixed by prefix and a dot unless prefix is empty
+func NewStatsdSink(addr, prefix string) (*StatsdSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" {
+		prefix += "."
+	}
+	return &StatsdSink{prefix: prefix, conn: conn}, nil
+}
+
+// Increment sends a counter increment
+func (s *StatsdSink) Increment(name string) {
+	s.send(name, "1", "c")
+}
+
+// Gauge sends a gauge value
+func (s *StatsdSink) Gauge(name string, value float64) {
+	s.send(name, strconv.FormatFloat(value, 'f', -1, 64), "g")
+}
+
+// Timing sends a timing in milliseconds
+func (s *StatsdSink) Timing(name string, d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	s.send(name, strconv.FormatFloat(ms, 'f', -1, 64), "ms")
+}
+
+// Close closes the connection to the statsd server
+func (s *StatsdSink) Close() error {
+	return s.conn.Close()
+}
+
+func (s *StatsdSink) send(name, value, kind string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.conn, "%s%s:%s|%s", s.prefix, name, value, kind)
+}
@@ -0,0 +1,148 @@
+package lrserver_test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// recordingSink keeps the metrics it receives
+type recordingSink struct {
+	mu      sync.Mutex
+	counts  map[string]int
+	gauges  map[string]float64
+	timings map[string]int
+}
+
+func newRecordingSink() *recordingSink {
+	return &recordingSink{
+		counts:  map[string]int{},
+		gauges:  map[string]float64{},
+		timings: map[string]int{},
+	}
+}
+
+func (r *recordingSink) Increment(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counts[name]++
+}
+
+func (r *recordingSink) Gauge(name string, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gauges[name] = value
+}
+
+func (r *recordingSink) Timing(name string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timings[name]++
+}
+
+func TestMetrics(t *testing.T) {
+	Convey("Given a running server with a metrics sink", t, func() {
+		srv := startServer(t)
+		sink := newRecordingSink()
+		srv.AddMetricsSink(sink)
+
+		Convey("connections and broadcasts should be measured", func() {
+			conn := connect(t, srv)
+			defer conn.Close()
+
+			srv.Reload("css/main.css")
+			_, err := readReload(conn)
+			So(err, ShouldBeNil)
+			time.Sleep(time.Millisecond)
+
+			sink.mu.Lock()
+			defer sink.mu.Unlock()
+			So(sink.counts["connections"], ShouldEqual, 1)
+			So(sink.counts["reloads"], ShouldEqual, 1)
+			So(sink.counts["delivered"], ShouldEqual, 1)
+			So(sink.counts["reloadsSent"], ShouldEqual, 1)
+			So(sink.gauges["clients"], ShouldEqual, 1)
+			So(sink.timings["broadcast"], ShouldEqual, 1)
+		})
+	})
+
+	Convey("Given a running server scraped by Prometheus", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		conn := connect(t, srv)
+		defer conn.Close()
+		srv.Reload("css/main.css")
+		_, err := readReload(conn)
+		So(err, ShouldBeNil)
+		time.Sleep(time.Millisecond)
+
+		// An upgrade without a websocket version fails
+		req, err := http.NewRequest("GET", fmt.Sprintf("http%s:%d/livereload", localhost, srv.Port()), nil)
+		So(err, ShouldBeNil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		resp, err := http.DefaultClient.Do(req)
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusUpgradeRequired)
+
+		rec := httptest.NewRecorder()
+		srv.PrometheusHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		body := rec.Body.String()
+
+		Convey("the counters should be exposed in the text format", func() {
+			So(rec.Header().Get("Content-Type"), ShouldStartWith, "text/plain; version=0.0.4")
+			So(body, ShouldContainSubstring, "# TYPE lrserver_clients gauge\nlrserver_clients 1\n")
+			So(body, ShouldContainSubstring, "# TYPE lrserver_connections_total counter\nlrserver_connections_total 1\n")
+			So(body, ShouldContainSubstring, "lrserver_reloads_sent_total 1\n")
+			So(body, ShouldContainSubstring, "lrserver_alerts_sent_total 0\n")
+		})
+
+		Convey("failed upgrades should be counted", func() {
+			So(body, ShouldContainSubstring, "lrserver_upgrade_failures_total 1\n")
+		})
+	})
+
+	Convey("Given a statsd sink", t, func() {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer pc.Close()
+
+		sink, err := lrserver.NewStatsdSink(pc.LocalAddr().String(), "dev.lrserver")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sink.Close()
+
+		Convey("metrics should be sent in the statsd format", func() {
+			sink.Increment("reloads")
+			sink.Gauge("clients", 3)
+			sink.Timing("broadcast", 1500*time.Microsecond)
+
+			buf := make([]byte, 512)
+			var got []string
+			for i := 0; i < 3; i++ {
+				pc.SetReadDeadline(time.Now().Add(time.Second))
+				n, _, err := pc.ReadFrom(buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, string(buf[:n]))
+			}
+			So(got, ShouldResemble, []string{
+				"dev.lrserver.reloads:1|c",
+				"dev.lrserver.clients:3|g",
+				"dev.lrserver.broadcast:1.5|ms",
+			})
+		})
+	})
+}
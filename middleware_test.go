@@ -0,0 +1,35 @@
+package lrserver_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMiddleware(t *testing.T) {
+	Convey("Given an application wrapped in the middleware", t, func() {
+		srv, err := lrserver.New(lrserver.DefaultName, "", 35730)
+		So(err, ShouldBeNil)
+		app := httptest.NewServer(srv.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/" {
+				fmt.Fprint(rw, "<html><body><h1>Hi</h1></body></html>")
+				return
+			}
+			rw.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(rw, `{"ok":true}`)
+		})))
+		defer app.Close()
+
+		Convey("pages should load the script from the server on the page's host", func() {
+			So(getBody(t, app.URL+"/"), ShouldEqual, `<html><body><h1>Hi</h1><script src="http://127.0.0.1:35730/livereload.js"></script></body></html>`)
+		})
+
+		Convey("everything else should pass through", func() {
+			So(getBody(t, app.URL+"/api"), ShouldEqual, `{"ok":true}`)
+		})
+	})
+}
@@ -0,0 +1,205 @@
+package lrserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// StdinCommand is a command read by ServeCommands, one JSON object per
+// line:
+//
+//	{"cmd": "reload", "path": "css/main.css"}
+//	{"cmd": "reload", "paths": ["index.html", "js/app.js"]}
+//	{"cmd": "reload", "path": "img/logo.png", "originalPath": "src/logo.svg", "noLiveImg": true}
+//	{"cmd": "reload", "path": "templates/console.html", "url": "/graphql/**"}
+//	{"cmd": "reload-css", "path": "css/main.css"}
+//	{"cmd": "reload-image", "path": "img/logo.png"}
+//	{"cmd": "reload-page", "path": "css/main.css"}
+//	{"cmd": "alert", "message": "Build failed", "level": "error", "sticky": true}
+//	{"cmd": "alert", "message": "API schema changed", "url": "/graphql/**"}
+//	{"cmd": "send", "command": "inject-state", "payload": {"count": 1}}
+//	{"cmd": "close", "id": 3, "reason": "bye"}
+//	{"cmd": "pause"}
+//	{"cmd": "resume"}
+//
+// A reload of several paths is sent with as few messages as ReloadAll,
+// unless it has options. URL sends a reload, alert or custom command
+// only to the pages matching it, as for ReloadMatching, AlertMatching
+// and SendMatching.
+type StdinCommand struct {
+	Cmd     string   `json:"cmd"`
+	Path    string   `json:"path,omitempty"`
+	Paths   []string `json:"paths,omitempty"`
+	Message string   `json:"message,omitempty"`
+	URL     string   `json:"url,omitempty"`
+
+	// Reload options, as for ReloadOptions, with Delay in milliseconds
+	NoLiveCSS    bool   `json:"noLiveCSS,omitempty"`
+	NoLiveImg    bool   `json:"noLiveImg,omitempty"`
+	OriginalPath string `json:"originalPath,omitempty"`
+	OverrideURL  string `json:"overrideURL,omitempty"`
+	Delay        int64  `json:"delay,omitempty"`
+
+	// Alert options, as for AlertOptions, with Duration in milliseconds
+	Level    AlertLevel `json:"level,omitempty"`
+	Duration int64      `json:"duration,omitempty"`
+	Sticky   bool       `json:"sticky,omitempty"`
+
+	// Command and Payload are the custom command sent by send
+	Command string                 `json:"command,omitempty"`
+	Payload map[string]interface{} `json:"payload,omitempty"`
+
+	// ID and Reason pick the connection closed by close, as for
+	// CloseConnection, and what it's told
+	ID     uint64 `json:"id,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ServeCommands reads newline-delimited JSON commands from r until it is
+// exhausted, so that editor plugins and watchers written in other
+// languages can drive the server through a pipe. Malformed and unknown
+// commands are logged and skipped.
+func (s *Server) ServeCommands(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		cmd := new(StdinCommand)
+		err := json.Unmarshal(line, cmd)
+		if err != nil {
+			s.logError("command:", err)
+			continue
+		}
+		err = s.runStdinCommand(cmd)
+		if err != nil {
+			s.logError("command:", err)
+		}
+	}
+	return scanner.Err()
+}
+
+// ServeStdin reads newline-delimited JSON commands from os.Stdin
+func (s *Server) ServeStdin() error {
+	return s.ServeCommands(os.Stdin)
+}
+
+func (s *Server) runStdinCommand(cmd *StdinCommand) error {
+	switch cmd.Cmd {
+	case "reload":
+		return s.runReloadCommand(cmd)
+	case "reload-css":
+		return cmd.each(s.ReloadCSS)
+	case "reload-image":
+		return cmd.each(s.ReloadImage)
+	case "reload-page":
+		return cmd.each(s.ReloadPage)
+	case "alert":
+		return s.runAlertCommand(cmd)
+	case "send":
+		if cmd.URL != "" {
+			return s.SendMatching(cmd.URL, cmd.Command, cmd.Payload)
+		}
+		return s.Send(cmd.Command, cmd.Payload)
+	case "close":
+		if cmd.ID == 0 {
+			return fmt.Errorf("close: missing id")
+		}
+		return s.CloseConnection(cmd.ID, cmd.Reason)
+	case "pause":
+		s.Pause()
+	case "resume":
+		s.Resume()
+	default:
+		return fmt.Errorf("unknown command %q", cmd.Cmd)
+	}
+	return nil
+}
+
+// runReloadCommand reloads the command's paths, as a batch unless
+// it has options or a URL pattern
+func (s *Server) runReloadCommand(cmd *StdinCommand) error {
+	paths, err := cmd.paths()
+	if err != nil {
+		return err
+	}
+	opts := ReloadOptions{
+		NoLiveCSS:    cmd.NoLiveCSS,
+		NoLiveImg:    cmd.NoLiveImg,
+		OriginalPath: cmd.OriginalPath,
+		OverrideURL:  cmd.OverrideURL,
+		Delay:        time.Duration(cmd.Delay) * time.Millisecond,
+	}
+	var match func(*conn) bool
+	if cmd.URL != "" {
+		glob, err := newGlobMatcher(cmd.URL)
+		if err != nil {
+			return err
+		}
+		match = pageMatcher(glob)
+	}
+	switch {
+	case match != nil || opts != (ReloadOptions{}):
+		for _, p := range paths {
+			s.reloadTo(match, p, opts)
+		}
+	case len(paths) == 1:
+		s.Reload(paths[0])
+	default:
+		s.ReloadAll(paths...)
+	}
+	return nil
+}
+
+// runAlertCommand sends the command's alert, to the matching pages only
+// if it has a URL pattern
+func (s *Server) runAlertCommand(cmd *StdinCommand) error {
+	if cmd.Message == "" {
+		return fmt.Errorf("alert: missing message")
+	}
+	opts := AlertOptions{
+		Level:    cmd.Level,
+		Duration: time.Duration(cmd.Duration) * time.Millisecond,
+		Sticky:   cmd.Sticky,
+	}
+	if cmd.URL == "" {
+		s.AlertWithOptions(cmd.Message, opts)
+		return nil
+	}
+	if opts != (AlertOptions{}) {
+		return fmt.Errorf("alert: options can't be combined with url")
+	}
+	return s.AlertMatching(cmd.URL, cmd.Message)
+}
+
+// paths gets the command's path and paths together
+func (cmd *StdinCommand) paths() ([]string, error) {
+	var paths []string
+	if cmd.Path != "" {
+		paths = append(paths, cmd.Path)
+	}
+	paths = append(paths, cmd.Paths...)
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%s: missing path", cmd.Cmd)
+	}
+	return paths, nil
+}
+
+// each calls reload with each of the command's paths
+func (cmd *StdinCommand) each(reload func(file string)) error {
+	paths, err := cmd.paths()
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		reload(p)
+	}
+	return nil
+}
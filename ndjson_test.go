@@ -0,0 +1,111 @@
+package lrserver_test
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestServeCommands(t *testing.T) {
+	Convey("Given a running server with a connected websocket", t, func() {
+		srv := startServer(t)
+		conn := connect(t, srv)
+		defer conn.Close()
+
+		Convey("NDJSON commands should be run", func() {
+			errBuf := new(syncBuffer)
+			srv.SetErrorLog(log.New(errBuf, "", 0))
+
+			input := strings.Join([]string{
+				`{"cmd":"reload","path":"css/main.css"}`,
+				`not json`,
+				`{"cmd":"bogus"}`,
+				``,
+				`{"cmd":"reload","paths":["index.html","js/app.js"]}`,
+				`{"cmd":"reload-page"}`,
+				`{"cmd":"close"}`,
+				`{"cmd":"alert","message":"x","url":"/**","sticky":true}`,
+				`{"cmd":"reload-css","path":"css/theme.css"}`,
+				`{"cmd":"reload-page","path":"css/print.css"}`,
+				`{"cmd":"pause"}`,
+				`{"cmd":"reload","paths":["css/a.css","css/b.css"]}`,
+				`{"cmd":"resume"}`,
+				`{"cmd":"alert","message":"done"}`,
+			}, "\n")
+			go srv.ServeCommands(strings.NewReader(input))
+
+			for _, want := range []serverReload{
+				{Path: "css/main.css", LiveCSS: true},
+				{Path: "index.html", LiveCSS: true},
+				{Path: "css/theme.css", LiveCSS: true},
+				{Path: "css/print.css", LiveCSS: false},
+				{Path: "css/a.css", LiveCSS: true},
+				{Path: "css/b.css", LiveCSS: true},
+			} {
+				sr, err := readReload(conn)
+				So(err, ShouldBeNil)
+				So(sr.Path, ShouldEqual, want.Path)
+				So(sr.LiveCSS, ShouldEqual, want.LiveCSS)
+			}
+
+			sa := new(serverAlert)
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			err := conn.ReadJSON(sa)
+			So(err, ShouldBeNil)
+			So(sa.Message, ShouldEqual, "done")
+
+			So(errBuf.String(), ShouldContainSubstring, "invalid character")
+			So(errBuf.String(), ShouldContainSubstring, `unknown command "bogus"`)
+			So(errBuf.String(), ShouldContainSubstring, "reload-page: missing path")
+			So(errBuf.String(), ShouldContainSubstring, "close: missing id")
+			So(errBuf.String(), ShouldContainSubstring, "alert: options can't be combined with url")
+		})
+
+		Convey("each command should drive the matching feature", func() {
+			page := visit(t, srv, "http://localhost:8080/graphql/console")
+			defer page.Close()
+
+			for _, c := range []struct {
+				line string
+				want map[string]interface{}
+			}{
+				{`{"cmd":"reload","path":"img/logo.png","originalPath":"src/logo.svg","noLiveImg":true}`,
+					map[string]interface{}{"command": "reload", "path": "img/logo.png", "liveCSS": true, "liveImg": false, "originalPath": "src/logo.svg"}},
+				{`{"cmd":"reload","path":"templates/console.html","url":"/graphql/**"}`,
+					map[string]interface{}{"command": "reload", "path": "templates/console.html", "liveCSS": true}},
+				{`{"cmd":"alert","message":"failed","level":"error","duration":3000,"sticky":true}`,
+					map[string]interface{}{"command": "alert", "message": "failed", "level": "error", "duration": float64(3000), "sticky": true}},
+				{`{"cmd":"alert","message":"schema changed","url":"/graphql/**"}`,
+					map[string]interface{}{"command": "alert", "message": "schema changed"}},
+				{`{"cmd":"send","command":"inject-state","payload":{"count":1}}`,
+					map[string]interface{}{"command": "inject-state", "count": float64(1)}},
+				{`{"cmd":"send","command":"inject-state","payload":{"count":2},"url":"/graphql/**"}`,
+					map[string]interface{}{"command": "inject-state", "count": float64(2)}},
+			} {
+				So(srv.ServeCommands(strings.NewReader(c.line)), ShouldBeNil)
+				var msg map[string]interface{}
+				page.SetReadDeadline(time.Now().Add(time.Second))
+				So(page.ReadJSON(&msg), ShouldBeNil)
+				So(msg, ShouldResemble, c.want)
+			}
+
+			Convey("and close connections", func() {
+				var id uint64
+				for _, c := range srv.Connections() {
+					if c.URL == "http://localhost:8080/graphql/console" {
+						id = c.ID
+					}
+				}
+				So(srv.ServeCommands(strings.NewReader(fmt.Sprintf(`{"cmd":"close","id":%d,"reason":"bye"}`, id))), ShouldBeNil)
+				page.SetReadDeadline(time.Now().Add(time.Second))
+				_, _, err := page.ReadMessage()
+				So(websocket.IsCloseError(err, websocket.CloseNormalClosure), ShouldBeTrue)
+			})
+		})
+	})
+}
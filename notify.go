@@ -0,0 +1,198 @@
+package lrserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Notification describes a message broadcast to the browsers
+type Notification struct {
+	Server  string    `json:"server"`
+	Command string    `json:"command"`
+	Path    string    `json:"path,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// String describes the notification in a sentence
+func (n Notification) String() string {
+	if n.Command == "alert" {
+		return n.Server + " alert: " + n.Message
+	}
+	return n.Server + " reloaded " + n.Path
+}
+
+// Notifier is told about every broadcast, to drive other parts of a dev
+// feedback loop. Notifiers are called asynchronously, in goroutines Shutdown waits
+// for; errors are logged.
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// ContextNotifier is a Notifier that can be cancelled. The server calls
+// NotifyContext instead of Notify, with a context that's done once it
+// shuts down.
+type ContextNotifier interface {
+	Notifier
+	NotifyContext(ctx context.Context, n Notification) error
+}
+
+// NotifierFunc adapts a function to the Notifier interface
+type NotifierFunc func(n Notification) error
+
+// Notify calls f(n)
+func (f NotifierFunc) Notify(n Notification) error {
+	return f(n)
+}
+
+// AddNotifier adds a notifier told about every reload and alert
+func (s *Server) AddNotifier(n Notifier) {
+	s.update(func(cfg *settings) {
+		cfg.notifiers = append(cfg.notifiers[:len(cfg.notifiers):len(cfg.notifiers)], n)
+	})
+}
+
+// notify tells every notifier about a broadcast
+func (s *Server) notify(command, path, msg string) {
+	notifiers := s.settings().notifiers
+	if len(notifiers) == 0 {
+		return
+	}
+	n := Notification{
+		Server:  s.name,
+		Command: command,
+		Path:    path,
+		Message: msg,
+		Time:    s.now(),
+	}
+	for _, notifier := range notifiers {
+		notifier := notifier
+		s.spawn(func() {
+			var err error
+			if cn, ok := notifier.(ContextNotifier); ok {
+				ctx, cancel := s.haltContext()
+				defer cancel()
+				err = cn.NotifyContext(ctx, n)
+			} else {
+				err = notifier.Notify(n)
+			}
+			if err != nil {
+				s.logError("notifier:", err)
+			}
+		})
+	}
+}
+
+// WebhookNotifier POSTs each notification as JSON to URL
+type WebhookNotifier struct {
+	URL    string
+	Header http.Header
+
+	// Client sends the requests, http.DefaultClient if nil
+	Client *http.Client
+}
+
+// Notify implements Notifier
+func (w *WebhookNotifier) Notify(n Notification) error {
+	return w.NotifyContext(context.Background(), n)
+}
+
+// NotifyContext implements ContextNotifier
+func (w *WebhookNotifier) NotifyContext(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	return w.post(ctx, body)
+}
+
+func (w *WebhookNotifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range w.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s: %s", w.URL, resp.Status)
+	}
+	return nil
+}
+
+// SlackNotifier posts each notification to a Slack incoming webhook
+type SlackNotifier struct {
+	WebhookURL string
+
+	// Client sends the requests, http.DefaultClient if nil
+	Client *http.Client
+}
+
+// Notify implements Notifier
+func (sl *SlackNotifier) Notify(n Notification) error {
+	return sl.NotifyContext(context.Background(), n)
+}
+
+// NotifyContext implements ContextNotifier
+func (sl *SlackNotifier) NotifyContext(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(map[string]string{"text": n.String()})
+	if err != nil {
+		return err
+	}
+	w := &WebhookNotifier{URL: sl.WebhookURL, Client: sl.Client}
+	return w.post(ctx, body)
+}
+
+// DesktopNotifier shows each notification as a desktop notification,
+// using notify-send on Linux, osascript on macOS and PowerShell on Windows
+type DesktopNotifier struct{}
+
+// Notify implements Notifier
+func (d DesktopNotifier) Notify(n Notification) error {
+	return d.NotifyContext(context.Background(), n)
+}
+
+// NotifyContext implements ContextNotifier
+func (DesktopNotifier) NotifyContext(ctx context.Context, n Notification) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", n.String(), n.Server)
+		cmd = exec.CommandContext(ctx, "osascript", "-e", script)
+	case "windows":
+		script := fmt.Sprintf(
+			"[reflection.assembly]::loadwithpartialname('System.Windows.Forms') | Out-Null; "+
+				"$n = New-Object System.Windows.Forms.NotifyIcon; "+
+				"$n.Icon = [System.Drawing.SystemIcons]::Information; $n.Visible = $true; "+
+				"$n.ShowBalloonTip(3000, '%s', '%s', 'Info')",
+			psQuote(n.Server), psQuote(n.String()),
+		)
+		cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command", script)
+	default:
+		cmd = exec.CommandContext(ctx, "notify-send", n.Server, n.String())
+	}
+	return cmd.Run()
+}
+
+// psQuote escapes v for a single-quoted PowerShell string
+func psQuote(v string) string {
+	return strings.Replace(v, "'", "''", -1)
+}
@@ -0,0 +1,105 @@
+package lrserver_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNotifiers(t *testing.T) {
+	Convey("Given a running server", t, func() {
+		srv := startServer(t)
+
+		Convey("notifiers should be told about reloads and alerts", func() {
+			notes := make(chan lrserver.Notification, 2)
+			srv.AddNotifier(lrserver.NotifierFunc(func(n lrserver.Notification) error {
+				notes <- n
+				return nil
+			}))
+
+			srv.Reload("css/main.css")
+			srv.Alert("build failed")
+
+			got := map[string]lrserver.Notification{}
+			for i := 0; i < 2; i++ {
+				select {
+				case n := <-notes:
+					got[n.Command] = n
+				case <-time.After(time.Second):
+					t.Fatal("no notification received")
+				}
+			}
+			So(got["reload"].Path, ShouldEqual, "css/main.css")
+			So(got["reload"].Server, ShouldEqual, lrserver.DefaultName)
+			So(got["alert"].Message, ShouldEqual, "build failed")
+		})
+
+		Convey("webhook and Slack notifiers should POST JSON", func() {
+			bodies := make(chan map[string]interface{}, 2)
+			hook := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				body := map[string]interface{}{}
+				json.NewDecoder(req.Body).Decode(&body)
+				body["token"] = req.Header.Get("X-Token")
+				bodies <- body
+			}))
+			defer hook.Close()
+
+			srv.AddNotifier(&lrserver.WebhookNotifier{
+				URL:    hook.URL,
+				Header: http.Header{"X-Token": {"secret"}},
+			})
+			srv.AddNotifier(&lrserver.SlackNotifier{WebhookURL: hook.URL})
+			srv.Reload("index.html")
+
+			got := map[string]map[string]interface{}{}
+			for i := 0; i < 2; i++ {
+				select {
+				case b := <-bodies:
+					if _, ok := b["text"]; ok {
+						got["slack"] = b
+					} else {
+						got["webhook"] = b
+					}
+				case <-time.After(time.Second):
+					t.Fatal("no webhook received")
+				}
+			}
+			So(got["webhook"]["command"], ShouldEqual, "reload")
+			So(got["webhook"]["path"], ShouldEqual, "index.html")
+			So(got["webhook"]["token"], ShouldEqual, "secret")
+			So(got["slack"]["text"], ShouldEqual, lrserver.DefaultName+" reloaded index.html")
+		})
+
+		Convey("Shutdown should cancel webhooks still in flight", func() {
+			received := make(chan struct{})
+			hook := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				// The server only notices the client hanging up once the
+				// body has been read
+				ioutil.ReadAll(req.Body)
+				close(received)
+				<-req.Context().Done()
+			}))
+			defer hook.Close()
+
+			srv.AddNotifier(&lrserver.WebhookNotifier{URL: hook.URL})
+			srv.Reload("index.html")
+			select {
+			case <-received:
+			case <-time.After(time.Second):
+				t.Fatal("no webhook received")
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			So(srv.Shutdown(ctx), ShouldBeNil)
+			So(srv.ActiveGoroutines(), ShouldEqual, 0)
+		})
+	})
+}
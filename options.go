@@ -0,0 +1,237 @@
+package lrserver
+
+import (
+	"io"
+	"log"
+	"log/slog"
+	"time"
+)
+
+// Option configures a server created by NewServer
+type Option func(*options)
+
+// options collects the settings the server is created with, and the
+// rest of the configuration to apply once it exists
+type options struct {
+	name       string
+	host       string
+	port       uint16
+	prefix     string
+	scriptPath string
+	setup      []func(*Server) error
+}
+
+// then adds a step configuring the created server
+func (o *options) then(f func(*Server) error) {
+	o.setup = append(o.setup, f)
+}
+
+// WithName names the server, which prefixes its default logs and is sent
+// to clients in the handshake
+func WithName(name string) Option {
+	return func(o *options) { o.name = name }
+}
+
+// WithHost sets the host the server listens on. IPv6 literals may be
+// bracketed, as in "[::1]".
+func WithHost(host string) Option {
+	return func(o *options) { o.host = host }
+}
+
+// WithPort sets the port the server listens on. Zero picks a free port
+// once started.
+func WithPort(port uint16) Option {
+	return func(o *options) { o.port = port }
+}
+
+// WithPathPrefix serves every endpoint under prefix, e.g. "/__dev" for
+// /__dev/livereload.js and /__dev/livereload, so the server can be mounted
+// under a path behind a reverse proxy. The served client connects to the
+// prefixed paths.
+func WithPathPrefix(prefix string) Option {
+	return func(o *options) { o.prefix = prefix }
+}
+
+// WithScriptPath serves the client JavaScript at path instead of
+// /livereload.js under the path prefix
+func WithScriptPath(path string) Option {
+	return func(o *options) { o.scriptPath = path }
+}
+
+// WithPortRange sets the port the server listens on, falling back to the
+// next one up to last while they're in use, as SetPortRange
+func WithPortRange(first, last uint16) Option {
+	return func(o *options) {
+		o.port = first
+		o.then(func(s *Server) error {
+			return s.SetPortRange(last)
+		})
+	}
+}
+
+// WithTLS makes ListenAndServe and Serve serve HTTPS using the
+// certificate and key in certFile and keyFile
+func WithTLS(certFile, keyFile string) Option {
+	return func(o *options) {
+		o.then(func(s *Server) error {
+			s.certFile, s.keyFile = certFile, keyFile
+			return nil
+		})
+	}
+}
+
+// WithUpgraderConfig tunes how websocket connections are set up,
+// as SetUpgraderConfig
+func WithUpgraderConfig(c UpgraderConfig) Option {
+	return func(o *options) {
+		o.then(func(s *Server) error {
+			s.SetUpgraderConfig(c)
+			return nil
+		})
+	}
+}
+
+// WithClientJSFile serves the script in the file at path in place of the
+// bundled livereload.js, as SetClientJSFile
+func WithClientJSFile(path string) Option {
+	return func(o *options) {
+		o.then(func(s *Server) error {
+			return s.SetClientJSFile(path)
+		})
+	}
+}
+
+// WithClientVersion serves the embedded livereload.js build of version,
+// as SetClientVersion
+func WithClientVersion(version string) Option {
+	return func(o *options) {
+		o.then(func(s *Server) error {
+			return s.SetClientVersion(version)
+		})
+	}
+}
+
+// WithPolling polls watched directories every interval, or every
+// DefaultPollInterval if it's zero or less, as SetPollInterval and
+// SetPolling
+func WithPolling(interval time.Duration) Option {
+	return func(o *options) {
+		o.then(func(s *Server) error {
+			s.SetPollInterval(interval)
+			s.SetPolling(true)
+			return nil
+		})
+	}
+}
+
+// WithLiveCSS sets whether stylesheets are reloaded without reloading
+// the page, which they are by default
+func WithLiveCSS(n bool) Option {
+	return func(o *options) {
+		o.then(func(s *Server) error {
+			s.SetLiveCSS(n)
+			return nil
+		})
+	}
+}
+
+// WithStatusLog sets the logger for status messages, as SetStatusLog
+func WithStatusLog(l *log.Logger) Option {
+	return func(o *options) {
+		o.then(func(s *Server) error {
+			s.SetStatusLog(l)
+			return nil
+		})
+	}
+}
+
+// WithErrorLog sets the logger for errors, as SetErrorLog
+func WithErrorLog(l *log.Logger) Option {
+	return func(o *options) {
+		o.then(func(s *Server) error {
+			s.SetErrorLog(l)
+			return nil
+		})
+	}
+}
+
+// WithLogger sends both status messages and errors to l.
+// It can be nil to silence the server.
+func WithLogger(l *log.Logger) Option {
+	return func(o *options) {
+		o.then(func(s *Server) error {
+			s.SetStatusLog(l)
+			s.SetErrorLog(l)
+			return nil
+		})
+	}
+}
+
+// WithStructuredLogger sends the server's logs to l, as SetLogger
+func WithStructuredLogger(l *slog.Logger) Option {
+	return func(o *options) {
+		o.then(func(s *Server) error {
+			s.SetLogger(l)
+			return nil
+		})
+	}
+}
+
+// WithOutput redirects both logs to w, as SetOutput
+func WithOutput(w io.Writer) Option {
+	return func(o *options) {
+		o.then(func(s *Server) error {
+			s.SetOutput(w)
+			return nil
+		})
+	}
+}
+
+// WithAdvertisedHost sets the host clients are told to connect to,
+// as SetAdvertisedHost
+func WithAdvertisedHost(host string) Option {
+	return func(o *options) {
+		o.then(func(s *Server) error {
+			return s.SetAdvertisedHost(host)
+		})
+	}
+}
+
+// WithAuthToken requires clients to present token, as SetAuthToken
+func WithAuthToken(token string) Option {
+	return func(o *options) {
+		o.then(func(s *Server) error {
+			return s.SetAuthToken(token)
+		})
+	}
+}
+
+// WithOpenBrowser opens the URL in the default browser once the server
+// starts serving, as SetOpenBrowser
+func WithOpenBrowser(rawURL string) Option {
+	return func(o *options) {
+		o.then(func(s *Server) error {
+			return s.SetOpenBrowser(rawURL)
+		})
+	}
+}
+
+// WithIdleShutdown stops the server once it has been serving without
+// clients for d, as SetIdleShutdown
+func WithIdleShutdown(d time.Duration) Option {
+	return func(o *options) {
+		o.then(func(s *Server) error {
+			s.SetIdleShutdown(d, nil)
+			return nil
+		})
+	}
+}
+
+// WithPublicURL sets the URL clients reach the server at, as SetPublicURL
+func WithPublicURL(rawURL string) Option {
+	return func(o *options) {
+		o.then(func(s *Server) error {
+			return s.SetPublicURL(rawURL)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package lrserver_test
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewServer(t *testing.T) {
+	Convey("NewServer without options should use the defaults", t, func() {
+		srv, err := lrserver.NewServer()
+		So(err, ShouldBeNil)
+		So(srv.Name(), ShouldEqual, lrserver.DefaultName)
+		So(srv.Host(), ShouldEqual, lrserver.DefaultHost)
+		So(srv.Port(), ShouldEqual, lrserver.DefaultPort)
+		So(srv.LiveCSS(), ShouldBeTrue)
+	})
+
+	Convey("NewServer should apply its options", t, func() {
+		buf := new(bytes.Buffer)
+		logger := log.New(buf, "", 0)
+		srv, err := lrserver.NewServer(
+			lrserver.WithLogger(logger),
+			lrserver.WithName("dev"),
+			lrserver.WithHost("127.0.0.1"),
+			lrserver.WithPort(0),
+			lrserver.WithLiveCSS(false),
+			lrserver.WithPublicURL("https://dev.example.test"),
+			lrserver.WithPolling(250*time.Millisecond),
+		)
+		So(err, ShouldBeNil)
+		So(srv.Name(), ShouldEqual, "dev")
+		So(srv.Addr(), ShouldEqual, "127.0.0.1:0")
+		So(srv.LiveCSS(), ShouldBeFalse)
+		So(srv.StatusLog(), ShouldEqual, logger)
+		So(srv.ErrorLog(), ShouldEqual, logger)
+		So(srv.PublicURL(), ShouldEqual, "https://dev.example.test")
+		So(srv.Polling(), ShouldBeTrue)
+		So(srv.PollInterval(), ShouldEqual, 250*time.Millisecond)
+	})
+
+	Convey("NewServer should fail on an invalid option", t, func() {
+		_, err := lrserver.NewServer(lrserver.WithPublicURL("://"))
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestPortRange(t *testing.T) {
+	Convey("Given a port already in use", t, func() {
+		taken, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer taken.Close()
+		port := uint16(taken.Addr().(*net.TCPAddr).Port)
+
+		Convey("a server with a port range should fall back to the next free port", func() {
+			srv, err := lrserver.NewServer(
+				lrserver.WithHost("127.0.0.1"),
+				lrserver.WithPortRange(port, port+10),
+				lrserver.WithLogger(nil),
+			)
+			So(err, ShouldBeNil)
+			go srv.ListenAndServe()
+			time.Sleep(10 * time.Millisecond)
+			defer srv.Close()
+
+			So(srv.Port(), ShouldBeGreaterThan, port)
+			So(srv.Port(), ShouldBeLessThanOrEqualTo, port+10)
+			So(srv.Status().Listening, ShouldBeTrue)
+		})
+
+		Convey("a server without one should fail to listen", func() {
+			srv, err := lrserver.NewServer(
+				lrserver.WithHost("127.0.0.1"),
+				lrserver.WithPort(port),
+				lrserver.WithLogger(nil),
+			)
+			So(err, ShouldBeNil)
+			So(srv.ListenAndServe(), ShouldNotBeNil)
+		})
+
+		Convey("a range ending below the port should be rejected", func() {
+			_, err := lrserver.NewServer(lrserver.WithPortRange(port, port-1))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestPathPrefix(t *testing.T) {
+	Convey("Given a server with a path prefix", t, func() {
+		srv, err := lrserver.NewServer(lrserver.WithPort(0), lrserver.WithPathPrefix("/__dev/"))
+		So(err, ShouldBeNil)
+		srv.SetStatusLog(nil)
+		srv.SetErrorLog(nil)
+		go srv.ListenAndServe()
+		time.Sleep(10 * time.Millisecond)
+		defer srv.Close()
+		base := fmt.Sprintf("http://127.0.0.1:%d", srv.Port())
+
+		Convey("the script should be served under the prefix", func() {
+			So(srv.ScriptURL(), ShouldEqual, fmt.Sprintf("http://localhost:%d/__dev/livereload.js", srv.Port()))
+			So(getBody(t, base+"/__dev/livereload.js"), ShouldContainSubstring, `this.path = "/__dev";`)
+			resp, err := http.Get(base + "/livereload.js")
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("the websocket should be served under the prefix", func() {
+			conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://127.0.0.1:%d/__dev/livereload", srv.Port()), nil)
+			So(err, ShouldBeNil)
+			conn.Close()
+		})
+	})
+
+	Convey("A custom script path should be served", t, func() {
+		srv, err := lrserver.NewServer(lrserver.WithPort(0), lrserver.WithScriptPath("/assets/lr.js"))
+		So(err, ShouldBeNil)
+		So(srv.ScriptTag(), ShouldEqual, `<script src="http://localhost:0/assets/lr.js"></script>`)
+	})
+
+	Convey("Relative paths should be rejected", t, func() {
+		_, err := lrserver.NewServer(lrserver.WithPathPrefix("__dev"))
+		So(err, ShouldNotBeNil)
+		_, err = lrserver.NewServer(lrserver.WithScriptPath("lr.js"))
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,76 @@
+package lrserver
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// extensionSchemes are the origin schemes of browser extensions, which
+// the user installed and so are trusted like local pages
+var extensionSchemes = []string{"chrome-extension", "moz-extension", "safari-web-extension"}
+
+// SetAllowedOrigins lets pages on the given hosts open the websocket, as
+// well as those on the websocket's own host, on localhost, on any host
+// allowed by AllowHosts, and browser extensions, which always may. Hosts
+// are matched without ports, a leading *. matches any subdomain, and *
+// lets any page connect. Requests without an Origin, which don't come
+// from browser pages, are always let through.
+func (s *Server) SetAllowedOrigins(hosts []string) {
+	allowed := make([]string, len(hosts))
+	for i, h := range hosts {
+		allowed[i] = strings.ToLower(h)
+	}
+	s.update(func(cfg *settings) { cfg.allowedOrigins = allowed })
+}
+
+// AllowedOrigins gets the extra hosts whose pages may open the websocket
+func (s *Server) AllowedOrigins() []string {
+	return append([]string(nil), s.settings().allowedOrigins...)
+}
+
+// SetCheckOrigin replaces the origin check for websocket upgrades with
+// f, which reports whether req may connect. It can be set to nil to go
+// back to the allowed origins.
+func (s *Server) SetCheckOrigin(f func(req *http.Request) bool) {
+	s.update(func(cfg *settings) { cfg.checkOrigin = f })
+}
+
+// originAllowed reports whether the page behind req may open the
+// websocket, so other sites open in the browser can't listen in
+func (s *Server) originAllowed(req *http.Request) bool {
+	cfg := s.settings()
+	if cfg.checkOrigin != nil {
+		return cfg.checkOrigin(req)
+	}
+
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	for _, scheme := range extensionSchemes {
+		if u.Scheme == scheme {
+			return true
+		}
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || host == "127.0.0.1" || host == "::1" {
+		return true
+	}
+	reqHost := req.Host
+	if h, _, err := net.SplitHostPort(reqHost); err == nil {
+		reqHost = h
+	}
+	if host == strings.ToLower(strings.Trim(reqHost, "[]")) {
+		return true
+	}
+	return containsString(cfg.allowedOrigins, "*") ||
+		hostAllowed(cfg.allowedOrigins, u.Host) ||
+		hostAllowed(cfg.allowedHosts, u.Host)
+}
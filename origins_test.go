@@ -0,0 +1,59 @@
+package lrserver_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOrigins(t *testing.T) {
+	Convey("Given a running server", t, func() {
+		srv := startServer(t)
+
+		// upgrade reports the status of a websocket upgrade from a page at origin
+		upgrade := func(origin string) int {
+			conn, resp, err := new(websocket.Dialer).Dial(
+				fmt.Sprintf("ws%s:%d/livereload", localhost, srv.Port()),
+				http.Header{"Origin": {origin}},
+			)
+			if err == nil {
+				conn.Close()
+			}
+			if resp == nil {
+				t.Fatal(err)
+			}
+			return resp.StatusCode
+		}
+
+		Convey("local pages and extensions should connect", func() {
+			So(upgrade("http://localhost:3000"), ShouldEqual, http.StatusSwitchingProtocols)
+			So(upgrade("http://127.0.0.1:8080"), ShouldEqual, http.StatusSwitchingProtocols)
+			So(upgrade("chrome-extension://jnihajbhpnppcggbcgedagnkighmdlei"), ShouldEqual, http.StatusSwitchingProtocols)
+		})
+
+		Convey("other sites should be refused", func() {
+			So(upgrade("https://evil.example.org"), ShouldEqual, http.StatusForbidden)
+			So(upgrade("null"), ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("allowed origins should connect", func() {
+			srv.SetAllowedOrigins([]string{"*.app.test"})
+			So(upgrade("https://www.app.test"), ShouldEqual, http.StatusSwitchingProtocols)
+			So(upgrade("https://evil.example.org"), ShouldEqual, http.StatusForbidden)
+
+			srv.SetAllowedOrigins([]string{"*"})
+			So(upgrade("https://evil.example.org"), ShouldEqual, http.StatusSwitchingProtocols)
+		})
+
+		Convey("a custom check should replace the allowed origins", func() {
+			srv.SetCheckOrigin(func(req *http.Request) bool {
+				return req.Header.Get("Origin") == "https://evil.example.org"
+			})
+			So(upgrade("https://evil.example.org"), ShouldEqual, http.StatusSwitchingProtocols)
+			So(upgrade("http://localhost:3000"), ShouldEqual, http.StatusForbidden)
+		})
+	})
+}
@@ -0,0 +1,198 @@
+package lrserver
+
+import (
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// PathStyle controls whether broadcast paths are made absolute or relative
+type PathStyle uint8
+
+const (
+	// PathAsIs leaves paths as they are given
+	PathAsIs PathStyle = iota
+
+	// PathAbsolute cleans paths and makes them start with a slash,
+	// like css/main.css becoming /css/main.css
+	PathAbsolute
+
+	// PathRelative cleans paths and strips leading slashes,
+	// like /css/main.css becoming css/main.css
+	PathRelative
+)
+
+// PathEncoding controls the URL-encoding of broadcast paths
+type PathEncoding uint8
+
+const (
+	// EncodingAsIs leaves paths as they are given
+	EncodingAsIs PathEncoding = iota
+
+	// EncodingPercent percent-encodes spaces, non-ASCII characters and
+	// other characters that aren't allowed in URL paths
+	EncodingPercent
+
+	// EncodingDecoded decodes percent-encoded paths. The stock
+	// livereload.js compares paths against decoded stylesheet URLs.
+	EncodingDecoded
+)
+
+// PathPolicy controls how paths are normalized before they are broadcast,
+// so they match what the browser compares them against
+type PathPolicy struct {
+	Style    PathStyle
+	Encoding PathEncoding
+
+	// FoldCase lower-cases paths, which suits case-insensitive
+	// filesystems whose paths may not match the case used in URLs
+	FoldCase bool
+}
+
+// apply normalizes p according to the policy
+func (pp PathPolicy) apply(p string) string {
+	if pp.Encoding == EncodingDecoded {
+		if decoded, err := url.PathUnescape(p); err == nil {
+			p = decoded
+		}
+	}
+
+	switch pp.Style {
+	case PathAbsolute:
+		p = path.Clean("/" + p)
+	case PathRelative:
+		p = strings.TrimLeft(path.Clean("/"+p), "/")
+	}
+
+	if pp.FoldCase {
+		p = strings.ToLower(p)
+	}
+
+	if pp.Encoding == EncodingPercent {
+		p = (&url.URL{Path: p}).EscapedPath()
+	}
+	return p
+}
+
+// windowsRoot matches the root of a Windows path: a drive letter,
+// a UNC share, or either behind a \\?\ long path prefix
+var windowsRoot = regexp.MustCompile(`^(?:[/\\]{2}[?.][/\\](?:UNC[/\\][^/\\]+[/\\][^/\\]+|[A-Za-z]:)|[A-Za-z]:|[/\\]{2}[^/\\]+[/\\][^/\\]+)`)
+
+// normalizeWindowsPath converts a Windows path into the slash-separated
+// form livereload.js matches against URLs
+func normalizeWindowsPath(p string) string {
+	p = windowsRoot.ReplaceAllString(p, "")
+	return strings.Replace(p, `\`, "/", -1)
+}
+
+// WebPathFunc derives the URL path for a filesystem path passed to
+// Reload. It returns false to fall back to the default derivation.
+type WebPathFunc func(fsPath string) (urlPath string, ok bool)
+
+// webPath derives the URL path for p, if it's a filesystem path
+// under the web root or handled by the web path hook
+func (s *Server) webPath(p string) string {
+	cfg := s.settings()
+	if cfg.webPathFunc != nil {
+		if urlPath, ok := cfg.webPathFunc(p); ok {
+			return urlPath
+		}
+	}
+	if cfg.webRoot == "" || !filepath.IsAbs(p) {
+		return p
+	}
+
+	rel, err := filepath.Rel(cfg.webRoot, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return p
+	}
+	return "/" + filepath.ToSlash(rel)
+}
+
+// PathMapper rewrites a path before it's broadcast, typically from where
+// a file is built to the URL the page loads it from
+type PathMapper func(path string) string
+
+// MapPrefix gets a PathMapper replacing the leading directory from by to,
+// so MapPrefix("dist/assets/css", "/static") broadcasts
+// ./dist/assets/css/app.css as /static/app.css. Paths are compared after
+// cleaning, as whole segments; others are left alone.
+func MapPrefix(from, to string) PathMapper {
+	from = path.Clean(filepath.ToSlash(from))
+	return func(p string) string {
+		clean := path.Clean(filepath.ToSlash(p))
+		switch {
+		case clean == from:
+			return to
+		case from == "/" && strings.HasPrefix(clean, "/"):
+			return strings.TrimSuffix(to, "/") + clean
+		case strings.HasPrefix(clean, from+"/"):
+			return strings.TrimSuffix(to, "/") + clean[len(from):]
+		}
+		return p
+	}
+}
+
+// AddPathMapper adds a mapper applied to every reloaded path before it's
+// broadcast, after the web root and Windows path handling and before the
+// path policy. Mappers are applied in the order they were added, each to
+// the result of the last.
+func (s *Server) AddPathMapper(m PathMapper) {
+	s.update(func(cfg *settings) {
+		cfg.pathMappers = append(cfg.pathMappers[:len(cfg.pathMappers):len(cfg.pathMappers)], m)
+	})
+}
+
+// broadcastPath normalizes a path passed to Reload before it is sent
+func (s *Server) broadcastPath(p string) string {
+	cfg := s.settings()
+	p = s.webPath(p)
+	if cfg.windowsPaths {
+		p = normalizeWindowsPath(p)
+	}
+	for _, m := range cfg.pathMappers {
+		p = m(p)
+	}
+	return cfg.pathPolicy.apply(p)
+}
+
+// WebRoot gets the directory reloaded filesystem paths are relative to
+func (s *Server) WebRoot() string {
+	return s.settings().webRoot
+}
+
+// SetWebRoot sets the directory served as the root of the site, so that
+// absolute filesystem paths under it can be passed to Reload: for a web
+// root of /srv/www, /srv/www/css/main.css is broadcast as /css/main.css.
+// Paths outside the web root are broadcast unchanged.
+func (s *Server) SetWebRoot(dir string) error {
+	if dir == "" {
+		s.update(func(cfg *settings) { cfg.webRoot = "" })
+		return nil
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	s.update(func(cfg *settings) { cfg.webRoot = abs })
+	return nil
+}
+
+// SetWebPathFunc sets a hook that overrides the URL path derived for
+// filesystem paths passed to Reload
+func (s *Server) SetWebPathFunc(f WebPathFunc) {
+	s.update(func(cfg *settings) { cfg.webPathFunc = f })
+}
+
+// PathPolicy gets the policy for normalizing reloaded paths
+func (s *Server) PathPolicy() PathPolicy {
+	return s.settings().pathPolicy
+}
+
+// SetPathPolicy sets the policy for normalizing reloaded paths.
+// It applies after Windows path normalization.
+func (s *Server) SetPathPolicy(p PathPolicy) {
+	s.update(func(cfg *settings) { cfg.pathPolicy = p })
+}
@@ -0,0 +1,88 @@
+package lrserver
+
+import (
+	"strconv"
+	"sync"
+)
+
+// pauseGate holds back reloads while the server is paused
+type pauseGate struct {
+	mu      sync.Mutex
+	paused  bool
+	paths   []string
+	matched []matchedReload
+}
+
+// matchedReload is a reload of some pages only, held back by Pause
+type matchedReload struct {
+	match func(*conn) bool
+	file  string
+	opts  ReloadOptions
+}
+
+// Pause holds back reloads, e.g. while a build writes its output, so
+// browsers don't reload into half-written files. Reloads requested in the
+// meantime are collected and sent on Resume, including those of
+// matching pages only. Alerts are sent as usual.
+func (s *Server) Pause() {
+	s.paused.mu.Lock()
+	defer s.paused.mu.Unlock()
+	if !s.paused.paused {
+		s.paused.paused = true
+		s.logStatus("paused reloads")
+	}
+}
+
+// Resume sends the reloads held back since Pause as one batch, with as
+// few messages as ReloadAll
+func (s *Server) Resume() {
+	s.paused.mu.Lock()
+	if !s.paused.paused {
+		s.paused.mu.Unlock()
+		return
+	}
+	paths, matched := s.paused.paths, s.paused.matched
+	s.paused.paused, s.paused.paths, s.paused.matched = false, nil, nil
+	s.paused.mu.Unlock()
+
+	s.logStatus("resumed reloads, " + strconv.Itoa(len(paths)+len(matched)) + " held")
+	s.reloadBatch(paths)
+	for _, r := range matched {
+		s.reloadTo(r.match, r.file, r.opts)
+	}
+}
+
+// Paused reports whether reloads are being held back
+func (s *Server) Paused() bool {
+	s.paused.mu.Lock()
+	defer s.paused.mu.Unlock()
+	return s.paused.paused
+}
+
+// holdPaused holds back files if the server is paused,
+// reporting whether it did
+func (s *Server) holdPaused(files ...string) bool {
+	s.paused.mu.Lock()
+	defer s.paused.mu.Unlock()
+	if !s.paused.paused {
+		return false
+	}
+	for _, file := range files {
+		if !containsString(s.paused.paths, file) {
+			s.paused.paths = append(s.paused.paths, file)
+		}
+	}
+	return true
+}
+
+// holdMatched holds back a reload of file for the connections selected
+// by match if the server is paused, reporting whether it did
+func (s *Server) holdMatched(match func(*conn) bool, file string, opts ReloadOptions) bool {
+	s.paused.mu.Lock()
+	defer s.paused.mu.Unlock()
+	if !s.paused.paused {
+		return false
+	}
+	s.paused.matched = append(s.paused.matched, matchedReload{match, file, opts})
+	return true
+}
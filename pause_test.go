@@ -0,0 +1,54 @@
+package lrserver_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPause(t *testing.T) {
+	Convey("Given a paused server and a connected websocket", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		conn := connect(t, srv)
+		defer conn.Close()
+		srv.Pause()
+		So(srv.Paused(), ShouldBeTrue)
+
+		Convey("reloads should be held back", func() {
+			srv.Reload("css/a.css")
+			srv.ReloadAll("index.html", "js/app.js")
+			conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+			_, _, err := conn.ReadMessage()
+			So(err, ShouldNotBeNil)
+
+			Convey("and sent as one batch on resume", func() {
+				// The timed out read left the websocket unusable
+				conn, _ = dial(t, srv, nil)
+				defer conn.Close()
+				So(conn.WriteJSON(clientHello), ShouldBeNil)
+				time.Sleep(10 * time.Millisecond)
+
+				srv.Resume()
+				So(srv.Paused(), ShouldBeFalse)
+				sr, err := readReload(conn)
+				So(err, ShouldBeNil)
+				So(sr.Path, ShouldEqual, "index.html")
+
+				srv.Reload("after.html")
+				sr, err = readReload(conn)
+				So(err, ShouldBeNil)
+				So(sr.Path, ShouldEqual, "after.html")
+			})
+		})
+
+		Convey("alerts should still be sent", func() {
+			srv.Alert("building")
+			sa := new(serverAlert)
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			So(conn.ReadJSON(sa), ShouldBeNil)
+			So(sa.Message, ShouldEqual, "building")
+		})
+	})
+}
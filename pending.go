@@ -0,0 +1,82 @@
+package lrserver
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// pendingReloads holds the reloads requested while no client was
+// connected, until one completes the handshake
+type pendingReloads struct {
+	mu    sync.Mutex
+	items []outbound
+	full  bool
+}
+
+// SetPendingReloads keeps up to n reloads requested while no client is
+// connected, and sends them to the first client to complete the
+// handshake, so a build finishing before the browser reconnects isn't
+// lost. Beyond n, they are coalesced into a single full page reload.
+// Zero, the default, drops reloads nobody is connected for.
+func (s *Server) SetPendingReloads(n int) {
+	s.update(func(cfg *settings) { cfg.pendingReloads = n })
+}
+
+// PendingReloads gets the number of reloads kept while no client is
+// connected
+func (s *Server) PendingReloads() int {
+	return s.settings().pendingReloads
+}
+
+// holdReload keeps resp for the next client if reloads are kept and no
+// client is connected, reporting whether it did
+func (s *Server) holdReload(resp *serverReload, delivered Event) bool {
+	limit := s.PendingReloads()
+	if limit <= 0 {
+		return false
+	}
+
+	s.pending.mu.Lock()
+	defer s.pending.mu.Unlock()
+	if s.ConnectionCount() > 0 {
+		return false
+	}
+	if s.pending.full {
+		return true
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		s.logError(err)
+		return true
+	}
+
+	if len(s.pending.items) == limit {
+		data, err = json.Marshal(makeServerReload("", false))
+		if err != nil {
+			s.logError(err)
+			return true
+		}
+		s.pending.items = []outbound{{data: data, delivered: Event{Command: "reload"}}}
+		s.pending.full = true
+		return true
+	}
+	s.pending.items = append(s.pending.items, outbound{data: data, delivered: delivered})
+	return true
+}
+
+// sendPending sends c the reloads kept while no client was connected.
+// It must be called once c has completed the handshake.
+func (c *conn) sendPending() {
+	p := &c.server.pending
+	p.mu.Lock()
+	items := p.items
+	p.items, p.full = nil, false
+	p.mu.Unlock()
+
+	if len(items) > 0 {
+		c.server.logStatus("sending pending reloads: " + c.remoteAddr)
+	}
+	for _, out := range items {
+		c.send(out.data, out.delivered)
+	}
+}
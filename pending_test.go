@@ -0,0 +1,40 @@
+package lrserver_test
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPendingReloads(t *testing.T) {
+	Convey("Given a running server keeping pending reloads", t, func() {
+		srv := startServer(t)
+		srv.SetPendingReloads(2)
+
+		Convey("reloads requested before a client connects should be sent on connecting", func() {
+			srv.Reload("css/main.css")
+			srv.Reload("index.html")
+			conn := connect(t, srv)
+			defer conn.Close()
+
+			for _, path := range []string{"css/main.css", "index.html"} {
+				sr, err := readReload(conn)
+				So(err, ShouldBeNil)
+				So(sr.Path, ShouldEqual, path)
+			}
+		})
+
+		Convey("too many pending reloads should be coalesced into a page reload", func() {
+			for _, path := range []string{"a.css", "b.css", "c.css"} {
+				srv.Reload(path)
+			}
+			conn := connect(t, srv)
+			defer conn.Close()
+
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "")
+			So(sr.LiveCSS, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,48 @@
+package lrserver
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+)
+
+// SetPortRange makes ListenAndServe fall back to the next port up to
+// last when the server's port is already in use, e.g. by another
+// LiveReload server. The port actually listened on is then reported by
+// Port and targeted by the served client. It fails if last is below the
+// server's port.
+func (s *Server) SetPortRange(last uint16) error {
+	if last < s.Port() {
+		return fmt.Errorf("lrserver: port range ends at %d, below port %d", last, s.Port())
+	}
+	s.update(func(cfg *settings) { cfg.lastPort = last })
+	return nil
+}
+
+// listen listens on the server's address, trying each port in its port
+// range in turn while they're in use
+func (s *Server) listen() (net.Listener, error) {
+	cfg := s.settings()
+	if cfg.port == 0 || cfg.lastPort <= cfg.port {
+		return net.Listen("tcp", s.Addr())
+	}
+
+	var err error
+	for port := int(cfg.port); port <= int(cfg.lastPort); port++ {
+		var l net.Listener
+		l, err = net.Listen("tcp", net.JoinHostPort(s.host, strconv.Itoa(port)))
+		if err == nil {
+			if port != int(cfg.port) {
+				s.logStatus(fmt.Sprintf("port %d in use, falling back to %d", cfg.port, port))
+				s.update(func(cfg *settings) { cfg.port = uint16(port) })
+			}
+			return l, nil
+		}
+		if !errors.Is(err, syscall.EADDRINUSE) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,62 @@
+package lrserver
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+)
+
+// promMetrics describes the counters in vars for Prometheus, in the
+// order they're exposed
+var promMetrics = []struct {
+	name, kind, help string
+}{
+	{"clients", "gauge", "Clients currently connected."},
+	{"connections", "counter", "Clients that completed the handshake."},
+	{"disconnections", "counter", "Clients that disconnected."},
+	{"reloads", "counter", "Reloads requested."},
+	{"alerts", "counter", "Alerts requested."},
+	{"delivered", "counter", "Messages sent to clients."},
+	{"reloadsSent", "counter", "Reload messages sent to clients."},
+	{"alertsSent", "counter", "Alert messages sent to clients."},
+	{"errors", "counter", "Errors logged."},
+	{"evictions", "counter", "Slow clients disconnected."},
+	{"dropped", "counter", "Messages dropped for slow clients."},
+	{"upgradeFailures", "counter", "Websocket upgrades that failed."},
+}
+
+// PrometheusHandler gets an http.Handler exposing the server's counters
+// in the Prometheus text format, for scraping as promhttp would. Metric
+// names are the expvar names in snake case, prefixed by "lrserver_", with
+// counters ending in "_total".
+func (s *Server) PrometheusHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		vars := s.vars()
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w := bufio.NewWriter(rw)
+		for _, m := range promMetrics {
+			name := "lrserver_" + snakeCase(m.name)
+			if m.kind == "counter" {
+				name += "_total"
+			}
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, m.help, name, m.kind, name, vars[m.name])
+		}
+		if err := w.Flush(); err != nil {
+			s.logError(err)
+		}
+	})
+}
+
+// snakeCase converts a camelCase name to snake_case
+func snakeCase(name string) string {
+	b := make([]byte, 0, len(name)+4)
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c >= 'A' && c <= 'Z' {
+			b = append(b, '_')
+			c += 'a' - 'A'
+		}
+		b = append(b, c)
+	}
+	return string(b)
+}
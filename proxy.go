@@ -0,0 +1,157 @@
+package lrserver
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// TrustProxy adds proxies, given as IP addresses or CIDR ranges, whose
+// X-Forwarded-For and X-Real-IP headers are trusted to report the
+// client's remote address
+func (s *Server) TrustProxy(proxies ...string) error {
+	var nets []*net.IPNet
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return &net.ParseError{Type: "IP address", Text: p}
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{
+				IP:   ip,
+				Mask: net.CIDRMask(bits, bits),
+			})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(p)
+		if err != nil {
+			return err
+		}
+		nets = append(nets, ipNet)
+	}
+
+	s.update(func(cfg *settings) {
+		cfg.trustedProxies = append(cfg.trustedProxies[:len(cfg.trustedProxies):len(cfg.trustedProxies)], nets...)
+	})
+	return nil
+}
+
+// trustsProxy reports whether addr is a trusted proxy
+func (s *Server) trustsProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range s.settings().trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAddr gets the remote address of the client making req,
+// honoring forwarding headers set by trusted proxies
+func (s *Server) clientAddr(req *http.Request) string {
+	addr := remoteHost(req)
+	if !s.trustsProxy(addr) {
+		return addr
+	}
+
+	// Walk X-Forwarded-For back to the first untrusted hop
+	if xff := req.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop == "" {
+				continue
+			}
+			addr = hop
+			if !s.trustsProxy(hop) {
+				return hop
+			}
+		}
+		return addr
+	}
+
+	if xri := strings.TrimSpace(req.Header.Get("X-Real-IP")); xri != "" {
+		return xri
+	}
+	return addr
+}
+
+// requestIsSecure reports whether req reached the server over TLS,
+// either directly or through a trusted TLS-terminating proxy
+func (s *Server) requestIsSecure(req *http.Request) bool {
+	if req.TLS != nil {
+		return true
+	}
+
+	addr := remoteHost(req)
+	if !s.trustsProxy(addr) {
+		return false
+	}
+
+	proto := strings.Split(req.Header.Get("X-Forwarded-Proto"), ",")[0]
+	return strings.EqualFold(strings.TrimSpace(proto), "https")
+}
+
+// requestHostPort gets the host and port that req was sent to, which
+// behind a trusted proxy is the one in its X-Forwarded-Host header
+func (s *Server) requestHostPort(req *http.Request, secure bool) (string, uint16) {
+	hostPort := req.Host
+	if s.trustsProxy(remoteHost(req)) {
+		if fwd := strings.TrimSpace(strings.Split(req.Header.Get("X-Forwarded-Host"), ",")[0]); validHostPort(fwd) {
+			hostPort = fwd
+		}
+	}
+	if port, err := makePort(hostPort); err == nil {
+		host, _, _ := net.SplitHostPort(hostPort)
+		return host, port
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(hostPort, "["), "]")
+	if secure {
+		return host, 443
+	}
+	return host, 80
+}
+
+// validHostPort reports whether hostPort is a host name or IP address,
+// optionally with a port, as a Host header holds
+func validHostPort(hostPort string) bool {
+	host := hostPort
+	if h, port, err := net.SplitHostPort(hostPort); err == nil {
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return false
+		}
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	if host == "" {
+		return false
+	}
+	for _, r := range host {
+		if !strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-._", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// remoteHost gets the host part of req's remote address
+func remoteHost(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,149 @@
+package lrserver
+
+import (
+	"errors"
+	"sync"
+)
+
+// Default per-connection send queue limits
+const (
+	DefaultMaxQueuedMessages = 256
+	DefaultMaxQueuedBytes    = 1 << 20
+)
+
+// SlowClientPolicy decides what happens to a message for a client whose
+// send queue is full
+type SlowClientPolicy int
+
+const (
+	// EvictSlowClients disconnects the client, which reconnects once it
+	// catches up. This is the default.
+	EvictSlowClients SlowClientPolicy = iota
+
+	// DropMessages discards the message and keeps the client
+	DropMessages
+
+	// CoalesceMessages replaces everything queued for the client with
+	// a single full page reload, which supersedes whatever it missed
+	CoalesceMessages
+)
+
+var errQueueFull = errors.New("lrserver: client is not keeping up, send queue full")
+
+type outbound struct {
+	data      []byte
+	delivered Event
+
+	// closeCode, if set, closes the connection in place of a message
+	closeCode int
+}
+
+// sendQueue buffers a connection's outbound messages until its transmit
+// goroutine writes them, so broadcasts never wait on a slow client
+type sendQueue struct {
+	mu    sync.Mutex
+	items []outbound
+	bytes int
+	ready chan struct{}
+}
+
+func newSendQueue() *sendQueue {
+	return &sendQueue{ready: make(chan struct{}, 1)}
+}
+
+// push queues out unless that would exceed either limit,
+// in which case it reports false and queues nothing
+func (q *sendQueue) push(out outbound, maxMessages, maxBytes int) bool {
+	q.mu.Lock()
+	if maxMessages > 0 && len(q.items)+1 > maxMessages ||
+		maxBytes > 0 && q.bytes+len(out.data) > maxBytes {
+		q.mu.Unlock()
+		return false
+	}
+	q.items = append(q.items, out)
+	q.bytes += len(out.data)
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// replace discards every message queued in favor of out, returning the
+// number discarded. Close markers are kept after it, so a connection
+// closing once its messages are sent still does.
+func (q *sendQueue) replace(out outbound) int {
+	q.mu.Lock()
+	items := []outbound{out}
+	for _, item := range q.items {
+		if item.closeCode != 0 {
+			items = append(items, item)
+		}
+	}
+	n := len(q.items) + 1 - len(items)
+	q.items, q.bytes = items, len(out.data)
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return n
+}
+
+// drain takes everything queued so far
+func (q *sendQueue) drain() []outbound {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := q.items
+	q.items, q.bytes = nil, 0
+	return items
+}
+
+// len reports the number of queued messages and their size in bytes
+func (q *sendQueue) len() (int, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items), q.bytes
+}
+
+// MaxQueuedMessages gets the number of messages a connection may have
+// waiting to be sent before it's evicted
+func (s *Server) MaxQueuedMessages() int {
+	return s.settings().maxQueuedMessages
+}
+
+// MaxQueuedBytes gets the number of bytes a connection may have waiting
+// to be sent before it's evicted
+func (s *Server) MaxQueuedBytes() int {
+	return s.settings().maxQueuedBytes
+}
+
+// SetMaxQueuedMessages sets the number of messages a connection may have
+// waiting to be sent, DefaultMaxQueuedMessages by default. A client that
+// falls further behind is disconnected. Zero or less removes the limit.
+func (s *Server) SetMaxQueuedMessages(n int) {
+	s.update(func(cfg *settings) { cfg.maxQueuedMessages = n })
+}
+
+// SetMaxQueuedBytes sets the number of bytes a connection may have
+// waiting to be sent, DefaultMaxQueuedBytes by default. A client that
+// falls further behind is disconnected. Zero or less removes the limit.
+func (s *Server) SetMaxQueuedBytes(n int) {
+	s.update(func(cfg *settings) { cfg.maxQueuedBytes = n })
+}
+
+// SlowClientPolicy gets what happens to messages for clients whose send
+// queue is full
+func (s *Server) SlowClientPolicy() SlowClientPolicy {
+	return s.settings().slowClientPolicy
+}
+
+// SetSlowClientPolicy sets what happens to messages for clients whose
+// send queue is full, EvictSlowClients by default. Either way, Reload and
+// Alert never wait on a slow client.
+func (s *Server) SetSlowClientPolicy(p SlowClientPolicy) {
+	s.update(func(cfg *settings) { cfg.slowClientPolicy = p })
+}
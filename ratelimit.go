@@ -0,0 +1,127 @@
+package lrserver
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimit bounds how fast messages are sent to each connection, so a
+// watcher firing hundreds of times a second can't keep browsers busy
+// reloading
+type RateLimit struct {
+	// PerSecond is the sustained number of messages per second. Zero or
+	// less lifts the limit.
+	PerSecond float64
+
+	// Burst is how many messages may be sent at once after a quiet
+	// spell, at least one
+	Burst int
+}
+
+// RateLimit gets the limit on messages sent to each connection
+func (s *Server) RateLimit() RateLimit {
+	return s.settings().rateLimit
+}
+
+// SetRateLimit limits the messages sent to each connection with a token
+// bucket, e.g. RateLimit{PerSecond: 5, Burst: 10}. There's no limit by
+// default. Messages over the limit are held until it allows another: a
+// single one is then sent as it is, and several are coalesced into one
+// full page reload, which supersedes them.
+func (s *Server) SetRateLimit(l RateLimit) {
+	if l.Burst < 1 {
+		l.Burst = 1
+	}
+	s.update(func(cfg *settings) { cfg.rateLimit = l })
+}
+
+// rateLimiter is a connection's token bucket, and the messages held
+// back by it
+type rateLimiter struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	held   []outbound
+	timer  Timer
+}
+
+// refill adds the tokens earned since the last refill
+func (l *rateLimiter) refill(limit RateLimit, now time.Time) {
+	burst := float64(limit.Burst)
+	if l.last.IsZero() {
+		l.tokens = burst
+	} else if l.tokens += now.Sub(l.last).Seconds() * limit.PerSecond; l.tokens > burst {
+		l.tokens = burst
+	}
+	l.last = now
+}
+
+// rateLimited spends a token on out, or holds it until one is earned
+// and reports true
+func (c *conn) rateLimited(out outbound) bool {
+	limit := c.server.settings().rateLimit
+	if limit.PerSecond <= 0 {
+		return false
+	}
+
+	l := &c.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(limit, c.server.now())
+	if len(l.held) == 0 && l.tokens >= 1 {
+		l.tokens--
+		return false
+	}
+	l.held = append(l.held, out)
+	if l.timer == nil {
+		wait := time.Duration((1 - l.tokens) / limit.PerSecond * float64(time.Second))
+		l.timer = c.server.Clock().AfterFunc(wait, c.releaseHeld)
+	}
+	return true
+}
+
+// releaseHeld sends the messages held back by the rate limit, coalescing
+// several into a full page reload
+func (c *conn) releaseHeld() {
+	l := &c.limiter
+	l.mu.Lock()
+	held := l.held
+	l.held, l.timer = nil, nil
+	l.refill(c.server.settings().rateLimit, c.server.now())
+	if l.tokens--; l.tokens < 0 {
+		l.tokens = 0
+	}
+	l.mu.Unlock()
+
+	if atomic.LoadInt32(&c.closed) == 1 || len(held) == 0 {
+		return
+	}
+	if len(held) == 1 {
+		c.enqueue(held[0])
+		return
+	}
+	reload, ok := c.pageReload()
+	if !ok {
+		return
+	}
+	c.server.dropped(c.remoteAddr, len(held))
+	c.enqueue(reload)
+}
+
+// pageReload gets a full page reload message for the connection
+func (c *conn) pageReload() (outbound, bool) {
+	data, err := json.Marshal(makeServerReload("", false))
+	if err != nil {
+		c.server.logError(err)
+		return outbound{}, false
+	}
+	if c.legacy() {
+		data = legacyMessage(data)
+	}
+	return outbound{
+		data:      data,
+		delivered: Event{Type: EventDelivered, Remote: c.remoteAddr, Command: "reload"},
+	}, true
+}
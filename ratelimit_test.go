@@ -0,0 +1,55 @@
+package lrserver_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRateLimit(t *testing.T) {
+	Convey("Given a running server with a rate limit and a connected websocket", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		clock := lrserver.NewManualClock(time.Now())
+		srv.SetClock(clock)
+		srv.SetKeepalive(0, 0)
+		srv.SetRateLimit(lrserver.RateLimit{PerSecond: 1, Burst: 2})
+		So(srv.RateLimit(), ShouldResemble, lrserver.RateLimit{PerSecond: 1, Burst: 2})
+		conn := connect(t, srv)
+		defer conn.Close()
+
+		Convey("a burst should be sent straight away", func() {
+			srv.Reload("css/a.css")
+			srv.Reload("css/b.css")
+			for _, path := range []string{"css/a.css", "css/b.css"} {
+				sr, err := readReload(conn)
+				So(err, ShouldBeNil)
+				So(sr.Path, ShouldEqual, path)
+			}
+			So(clock.Pending(), ShouldEqual, 0)
+
+			Convey("and messages over it coalesced into a page reload", func() {
+				srv.Reload("css/c.css")
+				srv.Reload("css/d.css")
+				So(clock.Pending(), ShouldEqual, 1)
+				clock.Advance(time.Second)
+
+				sr, err := readReload(conn)
+				So(err, ShouldBeNil)
+				So(sr.Path, ShouldEqual, "")
+
+				Convey("while a single one is sent as it is", func() {
+					srv.Reload("css/e.css")
+					So(clock.Pending(), ShouldEqual, 1)
+					clock.Advance(time.Second)
+
+					sr, err := readReload(conn)
+					So(err, ShouldBeNil)
+					So(sr.Path, ShouldEqual, "css/e.css")
+				})
+			})
+		})
+	})
+}
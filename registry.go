@@ -0,0 +1,162 @@
+package lrserver
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// connShards is the number of shards in a server's connection registry
+const connShards = 32
+
+var lastConnID uint64
+
+// connRegistry holds a server's connections, split into shards so
+// broadcasts can fan out in parallel without contending on one lock
+type connRegistry struct {
+	shards  []*connShard
+	workers int
+	count   int64
+}
+
+type connShard struct {
+	mu    sync.RWMutex
+	conns map[*conn]struct{}
+}
+
+// newConnRegistry creates a registry with the given number of shards,
+// broadcasting to at most workers shards at a time
+func newConnRegistry(shards, workers int) *connRegistry {
+	if shards < 1 {
+		shards = 1
+	}
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	r := &connRegistry{
+		shards:  make([]*connShard, shards),
+		workers: workers,
+	}
+	for i := range r.shards {
+		r.shards[i] = &connShard{conns: make(map[*conn]struct{})}
+	}
+	return r
+}
+
+func nextConnID() uint64 {
+	return atomic.AddUint64(&lastConnID, 1)
+}
+
+func (r *connRegistry) shard(c *conn) *connShard {
+	return r.shards[c.id%uint64(len(r.shards))]
+}
+
+func (r *connRegistry) add(c *conn) {
+	r.addLimited(c, 0)
+}
+
+// addLimited adds c unless the registry already holds max connections,
+// reporting whether it did. Zero or less means no limit.
+func (r *connRegistry) addLimited(c *conn, max int) bool {
+	for {
+		n := atomic.LoadInt64(&r.count)
+		if max > 0 && n >= int64(max) {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&r.count, n, n+1) {
+			break
+		}
+	}
+	sh := r.shard(c)
+	sh.mu.Lock()
+	sh.conns[c] = struct{}{}
+	sh.mu.Unlock()
+	return true
+}
+
+// remove deletes c, reporting whether it was registered
+func (r *connRegistry) remove(c *conn) bool {
+	sh := r.shard(c)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if _, ok := sh.conns[c]; !ok {
+		return false
+	}
+	delete(sh.conns, c)
+	atomic.AddInt64(&r.count, -1)
+	return true
+}
+
+// get finds the connection with id, or returns nil
+func (r *connRegistry) get(id uint64) *conn {
+	sh := r.shards[id%uint64(len(r.shards))]
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	for c := range sh.conns {
+		if c.id == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func (r *connRegistry) len() int {
+	n := 0
+	for _, sh := range r.shards {
+		sh.mu.RLock()
+		n += len(sh.conns)
+		sh.mu.RUnlock()
+	}
+	return n
+}
+
+// each calls f for every connection, one at a time
+func (r *connRegistry) each(f func(*conn)) {
+	for _, sh := range r.shards {
+		for _, c := range sh.snapshot() {
+			f(c)
+		}
+	}
+}
+
+// broadcast calls f for every connection, working through the shards in
+// parallel, and returns once f has returned for all of them
+func (r *connRegistry) broadcast(f func(*conn)) {
+	if len(r.shards) == 1 || r.workers == 1 {
+		r.each(f)
+		return
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, r.workers)
+	for _, sh := range r.shards {
+		conns := sh.snapshot()
+		if len(conns) == 0 {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(conns []*conn) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			for _, c := range conns {
+				f(c)
+			}
+		}(conns)
+	}
+	wg.Wait()
+}
+
+// snapshot copies the shard's connections so callbacks can run without
+// holding its lock, since closing a connection removes it from the shard
+func (sh *connShard) snapshot() []*conn {
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	conns := make([]*conn, 0, len(sh.conns))
+	for c := range sh.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
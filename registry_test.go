@@ -0,0 +1,194 @@
+package lrserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeConns registers n connections whose transmit side just discards
+// each message, standing in for the write to the socket
+func fakeConns(r *connRegistry, n int) (stop func()) {
+	done := make(chan struct{})
+	for i := 0; i < n; i++ {
+		c := &conn{id: nextConnID(), queue: newSendQueue()}
+		r.add(c)
+		go func() {
+			for {
+				select {
+				case <-c.queue.ready:
+					for _, out := range c.queue.drain() {
+						ioutil.Discard.Write(out.data)
+					}
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	return func() { close(done) }
+}
+
+func TestConnRegistryBroadcast(t *testing.T) {
+	r := newConnRegistry(connShards, 4)
+	conns := make([]*conn, 100)
+	for i := range conns {
+		conns[i] = &conn{id: nextConnID()}
+		r.add(conns[i])
+	}
+	if n := r.len(); n != len(conns) {
+		t.Fatalf("registered %d connections, want %d", n, len(conns))
+	}
+
+	var calls int32
+	r.broadcast(func(*conn) { atomic.AddInt32(&calls, 1) })
+	if calls != int32(len(conns)) {
+		t.Fatalf("broadcast reached %d connections, want %d", calls, len(conns))
+	}
+
+	if !r.remove(conns[0]) || r.remove(conns[0]) {
+		t.Fatal("remove should only report a registered connection once")
+	}
+}
+
+func TestConnRegistryConcurrency(t *testing.T) {
+	r := newConnRegistry(connShards, 4)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c := &conn{id: nextConnID()}
+				r.add(c)
+				r.remove(c)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				r.broadcast(func(*conn) {})
+				r.each(func(*conn) {})
+				r.len()
+			}
+		}()
+	}
+	wg.Wait()
+	if n := r.len(); n != 0 {
+		t.Fatalf("%d connections left registered, want 0", n)
+	}
+}
+
+func TestSendQueueLimits(t *testing.T) {
+	q := newSendQueue()
+	msg := outbound{data: make([]byte, 10)}
+	for i := 0; i < 3; i++ {
+		if !q.push(msg, 3, 100) {
+			t.Fatalf("message %d rejected under the limits", i)
+		}
+	}
+	if q.push(msg, 3, 100) {
+		t.Fatal("queued past the message limit")
+	}
+	if n, size := q.len(); n != 3 || size != 30 {
+		t.Fatalf("queue holds %d messages of %d bytes, want 3 of 30", n, size)
+	}
+
+	q.drain()
+	if q.push(outbound{data: make([]byte, 101)}, 3, 100) {
+		t.Fatal("queued past the byte limit")
+	}
+}
+
+func TestSlowClientPolicies(t *testing.T) {
+	srv, err := New(DefaultName, DefaultHost, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.SetMaxQueuedMessages(2)
+	reload, _ := json.Marshal(makeServerReload("", false))
+
+	srv.SetSlowClientPolicy(DropMessages)
+	c := &conn{id: nextConnID(), server: srv, queue: newSendQueue()}
+	for i := 0; i < 3; i++ {
+		c.send([]byte(fmt.Sprint(i)), Event{})
+	}
+	if n, _ := c.queue.len(); n != 2 {
+		t.Fatalf("dropping kept %d messages queued, want 2", n)
+	}
+	if n := atomic.LoadInt64(&srv.stats.dropped); n != 1 {
+		t.Fatalf("dropping counted %d messages, want 1", n)
+	}
+
+	srv.SetSlowClientPolicy(CoalesceMessages)
+	c.send([]byte("3"), Event{})
+	items := c.queue.drain()
+	if len(items) != 1 || string(items[0].data) != string(reload) {
+		t.Fatalf("coalescing queued %d messages, want a single page reload", len(items))
+	}
+	if n := atomic.LoadInt64(&srv.stats.dropped); n != 4 {
+		t.Fatalf("coalescing counted %d dropped messages in all, want 4", n)
+	}
+
+	// Coalescing must not swallow a pending close
+	c.send([]byte("4"), Event{})
+	c.closeWhenSent(websocket.CloseGoingAway)
+	c.send([]byte("5"), Event{})
+	items = c.queue.drain()
+	if len(items) != 2 || string(items[0].data) != string(reload) || items[1].closeCode != websocket.CloseGoingAway {
+		t.Fatalf("coalescing over a close queued %d messages, want a page reload and the close", len(items))
+	}
+}
+
+func TestAdmitDuringShutdown(t *testing.T) {
+	s, err := New("test", "127.0.0.1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	atomic.StoreInt32(&s.shuttingDown, 1)
+
+	// Registered after Shutdown went through the registry, so admit has
+	// to queue the close itself
+	c := &conn{id: nextConnID(), remoteAddr: "127.0.0.1:1", queue: newSendQueue()}
+	if !s.admit(c) {
+		t.Fatal("admit should register the connection")
+	}
+	queued := c.queue.drain()
+	if len(queued) != 1 || queued[0].closeCode != websocket.CloseGoingAway {
+		t.Fatalf("queued %+v, want a single going away close", queued)
+	}
+}
+
+// BenchmarkBroadcast compares a single map broadcast to serially, as the
+// registry used to be, with the sharded parallel registry
+func BenchmarkBroadcast(b *testing.B) {
+	designs := []struct {
+		name            string
+		shards, workers int
+	}{
+		{"map", 1, 1},
+		{"sharded", connShards, 0},
+	}
+	for _, clients := range []int{10, 100, 1000, 5000} {
+		for _, d := range designs {
+			b.Run(fmt.Sprintf("%s/%d", d.name, clients), func(b *testing.B) {
+				r := newConnRegistry(d.shards, d.workers)
+				stop := fakeConns(r, clients)
+				defer stop()
+
+				data, _ := json.Marshal(makeServerReload("/css/style.css", true))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					r.broadcast(func(c *conn) {
+						c.queue.push(outbound{data: data}, 0, 0)
+					})
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package lrserver
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ReloadKind is how changed files with an extension are reloaded
+type ReloadKind uint8
+
+const (
+	// DefaultReload leaves it to the client, which refreshes stylesheets
+	// in place if LiveCSS is on and reloads the page for anything else
+	DefaultReload ReloadKind = iota
+
+	// FullReload always reloads the page
+	FullReload
+
+	// LiveCSSReload refreshes stylesheets in place, even if LiveCSS is
+	// off. Other files, such as Sass sources, are broadcast with a .css
+	// extension, so the client refreshes the stylesheet of the same name,
+	// or every stylesheet if none matches, rather than the page.
+	LiveCSSReload
+
+	// LiveImageReload refreshes images in place
+	LiveImageReload
+
+	// NoReload ignores the change, as for source maps
+	NoReload
+)
+
+// ReloadPolicy gets how files are reloaded by extension
+func (s *Server) ReloadPolicy() map[string]ReloadKind {
+	policy := make(map[string]ReloadKind, len(s.settings().reloadPolicy))
+	for ext, kind := range s.settings().reloadPolicy {
+		policy[ext] = kind
+	}
+	return policy
+}
+
+// SetReloadPolicy sets how changed files are reloaded by their
+// extension, e.g.
+//
+//	lr.SetReloadPolicy(map[string]lrserver.ReloadKind{
+//		".scss": lrserver.LiveCSSReload,
+//		".map":  lrserver.NoReload,
+//	})
+//
+// Extensions are matched case-insensitively, with or without their dot.
+// The policy applies to the files reloaded once sources are mapped to
+// their outputs, and is overridden by explicit ReloadOptions and by
+// ReloadCSS, ReloadImage and ReloadPage.
+func (s *Server) SetReloadPolicy(policy map[string]ReloadKind) {
+	normalized := make(map[string]ReloadKind, len(policy))
+	for ext, kind := range policy {
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		normalized[ext] = kind
+	}
+	s.update(func(cfg *settings) { cfg.reloadPolicy = normalized })
+}
+
+// reloadKind gets how file is reloaded according to the policy
+func (s *Server) reloadKind(file string) ReloadKind {
+	policy := s.settings().reloadPolicy
+	if len(policy) == 0 {
+		return DefaultReload
+	}
+	return policy[strings.ToLower(path.Ext(filepath.ToSlash(file)))]
+}
+
+// ignoredByPolicy drops the files the policy ignores
+func (s *Server) ignoredByPolicy(files []string) []string {
+	if len(s.settings().reloadPolicy) == 0 {
+		return files
+	}
+	kept := files[:0:0]
+	for _, file := range files {
+		if s.reloadKind(file) != NoReload {
+			kept = append(kept, file)
+		}
+	}
+	return kept
+}
+
+// refreshedInPlace reports whether reloading file leaves the page alone
+func (s *Server) refreshedInPlace(file string) bool {
+	switch s.reloadKind(file) {
+	case LiveCSSReload, LiveImageReload:
+		return true
+	case FullReload:
+		return false
+	}
+	return isStylesheet(file) && s.LiveCSS()
+}
+
+// apply sets how opts reload a file of the kind, unless they already say
+func (k ReloadKind) apply(opts *ReloadOptions) {
+	if opts.NoLiveCSS || opts.NoLiveImg || opts.liveCSS || opts.liveImg {
+		return
+	}
+	switch k {
+	case FullReload:
+		opts.NoLiveCSS, opts.NoLiveImg = true, true
+	case LiveCSSReload:
+		opts.liveCSS, opts.asCSS = true, true
+	case LiveImageReload:
+		opts.liveImg = true
+	}
+}
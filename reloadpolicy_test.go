@@ -0,0 +1,73 @@
+package lrserver_test
+
+import (
+	"testing"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReloadPolicy(t *testing.T) {
+	Convey("Given a running server with a reload policy and a connected websocket", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		srv.SetLiveCSS(false)
+		srv.SetReloadPolicy(map[string]lrserver.ReloadKind{
+			"scss":  lrserver.LiveCSSReload,
+			".CSS":  lrserver.LiveCSSReload,
+			".map":  lrserver.NoReload,
+			".html": lrserver.FullReload,
+		})
+		So(srv.ReloadPolicy(), ShouldContainKey, ".scss")
+		So(srv.ReloadPolicy(), ShouldContainKey, ".css")
+		conn := connect(t, srv)
+		defer conn.Close()
+
+		Convey("ignored files should not be reloaded", func() {
+			srv.Reload("css/main.css.map")
+			srv.Reload("css/main.css")
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "css/main.css")
+			So(sr.LiveCSS, ShouldBeTrue)
+		})
+
+		Convey("sources should be broadcast as the stylesheets built from them", func() {
+			srv.Reload("scss/main.SCSS")
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "scss/main.css")
+			So(sr.LiveCSS, ShouldBeTrue)
+		})
+
+		Convey("explicit options should override the policy", func() {
+			srv.ReloadPage("css/main.css")
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.LiveCSS, ShouldBeFalse)
+		})
+
+		Convey("batches of files refreshed in place should not reload the page", func() {
+			srv.ReloadAll("scss/a.scss", "css/b.css", "css/b.css.map")
+			for _, path := range []string{"scss/a.css", "css/b.css"} {
+				sr, err := readReload(conn)
+				So(err, ShouldBeNil)
+				So(sr.Path, ShouldEqual, path)
+				So(sr.LiveCSS, ShouldBeTrue)
+			}
+		})
+
+		Convey("batches with a full reload should send just that", func() {
+			srv.ReloadAll("css/b.css", "index.html")
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "index.html")
+			So(sr.LiveCSS, ShouldBeFalse)
+
+			srv.Reload("after.html")
+			sr, err = readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "after.html")
+		})
+	})
+}
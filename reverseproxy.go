@@ -0,0 +1,97 @@
+package lrserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ProxyHandler gets an http.Handler reverse-proxying target, inserting
+// the script tag into the HTML pages it serves, so an application's
+// pages reload without edits, like browser-sync. It serves the endpoints
+// of s itself, and like Handler, the served client then targets the
+// page's own host and port.
+func (s *Server) ProxyHandler(target *url.URL) http.Handler {
+	proxy := newInjectingProxy(s, target)
+	h := s.Handler()
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if _, ok := s.endpoints.lookup(req.URL.Path); ok {
+			h.ServeHTTP(rw, req)
+			return
+		}
+		proxy.ServeHTTP(rw, req)
+	})
+}
+
+// newInjectingProxy creates a reverse proxy to target that inserts the
+// script tag into HTML pages, decompressing them if need be
+func newInjectingProxy(s *Server, target *url.URL) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		// Only ask for encodings pages can be decompressed from
+		if acceptsGzip(req) {
+			req.Header.Set("Accept-Encoding", "gzip")
+		} else {
+			req.Header.Del("Accept-Encoding")
+		}
+	}
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		return injectResponse(s, resp)
+	}
+	proxy.ErrorLog = s.server.ErrorLog
+	return proxy
+}
+
+// injectResponse inserts the script tag into resp if it's an HTML page,
+// which it leaves uncompressed
+func injectResponse(s *Server, resp *http.Response) error {
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") ||
+		resp.Request.Method == http.MethodHead ||
+		resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+
+	var body io.Reader = resp.Body
+	switch resp.Header.Get("Content-Encoding") {
+	case "":
+	case "gzip":
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return err
+		}
+		body = zr
+	default:
+		return nil
+	}
+	page, err := ioutil.ReadAll(body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	page = insertTag(page, s.ScriptTag())
+	resp.Body = ioutil.NopCloser(bytes.NewReader(page))
+	resp.ContentLength = int64(len(page))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(page)))
+	resp.Header.Del("Content-Encoding")
+	return nil
+}
+
+// acceptsGzip reports whether req accepts gzip-encoded responses
+func acceptsGzip(req *http.Request) bool {
+	for _, enc := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		enc = strings.TrimSpace(strings.SplitN(enc, ";", 2)[0])
+		if enc == "gzip" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package lrserver_test
+
+import (
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestProxyHandler(t *testing.T) {
+	Convey("Given a proxy to an application", t, func() {
+		app := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/":
+				rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+				rw.Write([]byte("<html><body><h1>Hi</h1></body></html>"))
+			case "/gzip":
+				rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+				rw.Header().Set("Content-Encoding", "gzip")
+				zw := gzip.NewWriter(rw)
+				zw.Write([]byte("<html><body><h1>Zipped</h1></body></html>"))
+				zw.Close()
+			default:
+				rw.Header().Set("Content-Type", "text/css")
+				rw.Write([]byte("body {}"))
+			}
+		}))
+		defer app.Close()
+		target, err := url.Parse(app.URL)
+		So(err, ShouldBeNil)
+
+		srv := startServer(t)
+		defer srv.Close()
+		proxy := httptest.NewServer(srv.ProxyHandler(target))
+		defer proxy.Close()
+
+		Convey("pages should be served with the client injected", func() {
+			So(getBody(t, proxy.URL+"/"), ShouldEqual, `<html><body><h1>Hi</h1><script src="/livereload.js"></script></body></html>`)
+		})
+
+		Convey("gzipped pages should be decompressed and injected", func() {
+			So(getBody(t, proxy.URL+"/gzip"), ShouldEqual, `<html><body><h1>Zipped</h1><script src="/livereload.js"></script></body></html>`)
+		})
+
+		Convey("everything else should pass through", func() {
+			So(getBody(t, proxy.URL+"/css/main.css"), ShouldEqual, "body {}")
+		})
+
+		Convey("the client script should be served too", func() {
+			So(getBody(t, proxy.URL+"/livereload.js"), ShouldContainSubstring, "LiveReload")
+		})
+	})
+}
@@ -0,0 +1,111 @@
+package lrserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// runner tracks a server started in the background by Start
+type runner struct {
+	mu   sync.Mutex
+	errc chan error
+	done chan struct{}
+	err  error
+}
+
+// Start listens like ListenAndServe, then serves in the background,
+// returning once the port is bound so Port is valid. Failures to serve
+// after that are sent on Err, and Wait blocks until serving stops. It
+// serves HTTPS if the server was created with WithTLS.
+func (s *Server) Start() error {
+	s.run.mu.Lock()
+	defer s.run.mu.Unlock()
+	if s.run.done != nil {
+		return errors.New("lrserver: server already started")
+	}
+
+	l, err := s.listen()
+	if err != nil {
+		return err
+	}
+	if err = s.assignPort(l); err != nil {
+		l.Close()
+		return err
+	}
+
+	errc, done := make(chan error, 1), make(chan struct{})
+	s.run.errc, s.run.done = errc, done
+	go func() {
+		err := s.Serve(l)
+		if err == http.ErrServerClosed {
+			err = nil
+		}
+		if err != nil {
+			errc <- err
+		}
+		s.run.mu.Lock()
+		s.run.err = err
+		s.run.mu.Unlock()
+		close(errc)
+		close(done)
+	}()
+	return nil
+}
+
+// Err gets a channel receiving the error that stopped a server started
+// with Start, which is closed once it stops. It's nil before Start.
+func (s *Server) Err() <-chan error {
+	s.run.mu.Lock()
+	defer s.run.mu.Unlock()
+	return s.run.errc
+}
+
+// Wait blocks until a server started with Start stops serving, returning
+// the error that stopped it, or nil once shut down or closed. It returns
+// straight away if the server wasn't started.
+func (s *Server) Wait() error {
+	s.run.mu.Lock()
+	done := s.run.done
+	s.run.mu.Unlock()
+	if done == nil {
+		return nil
+	}
+	<-done
+
+	s.run.mu.Lock()
+	defer s.run.mu.Unlock()
+	return s.run.err
+}
+
+// ListenAndServeContext is like ListenAndServe, but shuts down gracefully
+// once ctx is done, allowing DefaultGracePeriod for clients to receive
+// what was already sent to them. It returns nil after shutting down,
+// so it fits errgroup-style lifecycles:
+//
+//	g.Go(func() error { return lr.ListenAndServeContext(ctx) })
+func (s *Server) ListenAndServeContext(ctx context.Context) error {
+	l, err := s.listen()
+	if err != nil {
+		return err
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.Serve(l)
+	}()
+
+	select {
+	case err = <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), DefaultGracePeriod)
+	defer cancel()
+	err = s.Shutdown(shutdownCtx)
+	if serveErr := <-errc; serveErr != http.ErrServerClosed && err == nil {
+		err = serveErr
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package lrserver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStart(t *testing.T) {
+	Convey("Given a server started in the background", t, func() {
+		srv, err := lrserver.NewServer(lrserver.WithPort(0), lrserver.WithLogger(nil))
+		So(err, ShouldBeNil)
+		So(srv.Start(), ShouldBeNil)
+
+		Convey("the port should be bound once it returns", func() {
+			So(srv.Port(), ShouldNotEqual, 0)
+			conn := connect(t, srv)
+			conn.Close()
+			srv.Close()
+		})
+
+		Convey("starting it again should fail", func() {
+			So(srv.Start(), ShouldNotBeNil)
+			srv.Close()
+		})
+
+		Convey("a second server on the same port should fail to start", func() {
+			other, err := lrserver.NewServer(lrserver.WithPort(srv.Port()), lrserver.WithLogger(nil))
+			So(err, ShouldBeNil)
+			So(other.Start(), ShouldNotBeNil)
+			So(other.Wait(), ShouldBeNil)
+			srv.Close()
+		})
+
+		Convey("Wait should return nil once it's shut down", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			So(srv.Shutdown(ctx), ShouldBeNil)
+			So(srv.Wait(), ShouldBeNil)
+			err, ok := <-srv.Err()
+			So(ok, ShouldBeFalse)
+			So(err, ShouldBeNil)
+		})
+	})
+}
+
+func TestListenAndServeContext(t *testing.T) {
+	Convey("Given a server serving until a context is cancelled", t, func() {
+		srv, err := lrserver.NewServer(lrserver.WithPort(0), lrserver.WithLogger(nil))
+		So(err, ShouldBeNil)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		served := make(chan error, 1)
+		go func() {
+			served <- srv.ListenAndServeContext(ctx)
+		}()
+		time.Sleep(10 * time.Millisecond)
+		conn := connect(t, srv)
+		defer conn.Close()
+
+		Convey("cancelling it should shut the server down cleanly", func() {
+			srv.Reload("css/main.css")
+			cancel()
+			select {
+			case err := <-served:
+				So(err, ShouldBeNil)
+			case <-time.After(time.Second):
+				t.Fatal("server did not shut down")
+			}
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "css/main.css")
+		})
+	})
+}
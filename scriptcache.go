@@ -0,0 +1,76 @@
+package lrserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+// maxCachedScripts bounds the scripts kept compressed. Scripts vary with
+// the host a page reached the server at, when listening on every
+// interface or in same-origin mode, with any forwarded host and scheme,
+// and with whether the request presents the auth token, so a handful
+// covers the combinations a session typically sees.
+const maxCachedScripts = 8
+
+// encodedScript is a rendered client script with its ETag and its gzipped
+// form
+type encodedScript struct {
+	etag string
+	gz   []byte
+}
+
+// scriptCache keeps rendered scripts' ETags and compressed bodies, so
+// pages reloading many times a session don't cost a compression each.
+// Once full, the least recently used script is evicted.
+type scriptCache struct {
+	mu      sync.Mutex
+	scripts map[string]*encodedScript
+	order   []string
+}
+
+// touch marks script as the most recently used
+func (sc *scriptCache) touch(script string) {
+	for i, s := range sc.order {
+		if s == script {
+			copy(sc.order[i:], sc.order[i+1:])
+			sc.order[len(sc.order)-1] = script
+			return
+		}
+	}
+}
+
+// get gets the encoded form of script
+func (sc *scriptCache) get(script string) (*encodedScript, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if e, ok := sc.scripts[script]; ok {
+		sc.touch(script)
+		return e, nil
+	}
+
+	sum := sha256.Sum256([]byte(script))
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	zw.Write([]byte(script))
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	e := &encodedScript{etag: `"` + hex.EncodeToString(sum[:16]) + `"`, gz: buf.Bytes()}
+	if sc.scripts == nil {
+		sc.scripts = make(map[string]*encodedScript)
+	}
+	if len(sc.order) >= maxCachedScripts {
+		delete(sc.scripts, sc.order[0])
+		sc.order = append(sc.order[:0], sc.order[1:]...)
+	}
+	sc.scripts[script] = e
+	sc.order = append(sc.order, script)
+	return e, nil
+}
@@ -0,0 +1,89 @@
+package lrserver_test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestScriptCaching(t *testing.T) {
+	Convey("Given a running server", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		scriptURL := fmt.Sprintf("http%s:%d/livereload.js", localhost, srv.Port())
+
+		get := func(header http.Header) *http.Response {
+			req, err := http.NewRequest("GET", scriptURL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range header {
+				req.Header[k] = v
+			}
+			// Keep the transport from decompressing transparently
+			client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return resp
+		}
+
+		Convey("the script should be served with an ETag and revalidated", func() {
+			resp := get(nil)
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Header.Get("Cache-Control"), ShouldEqual, "no-cache")
+			etag := resp.Header.Get("ETag")
+			So(etag, ShouldNotBeEmpty)
+
+			Convey("a matching If-None-Match should get 304", func() {
+				resp := get(http.Header{"If-None-Match": {etag}})
+				defer resp.Body.Close()
+				So(resp.StatusCode, ShouldEqual, http.StatusNotModified)
+			})
+
+			Convey("a stale one should get the script again", func() {
+				resp := get(http.Header{"If-None-Match": {`"stale"`}})
+				defer resp.Body.Close()
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("clients accepting gzip should get it compressed", func() {
+			resp := get(http.Header{"Accept-Encoding": {"gzip, deflate"}})
+			defer resp.Body.Close()
+			So(resp.Header.Get("Content-Encoding"), ShouldEqual, "gzip")
+			So(resp.Header.Get("Vary"), ShouldEqual, "Accept-Encoding")
+			zr, err := gzip.NewReader(resp.Body)
+			So(err, ShouldBeNil)
+			body, err := ioutil.ReadAll(zr)
+			So(err, ShouldBeNil)
+			So(string(body), ShouldContainSubstring, "LiveReload")
+			So(get(nil).Header.Get("ETag"), ShouldNotEqual, resp.Header.Get("ETag"))
+		})
+
+		Convey("a full cache should evict the least recently used script", func() {
+			for i := 0; i < 8; i++ {
+				lrserver.CacheScript(srv, fmt.Sprint("script ", i))
+			}
+			lrserver.CacheScript(srv, "script 0")
+			lrserver.CacheScript(srv, "script 8")
+			So(lrserver.ScriptCached(srv, "script 0"), ShouldBeTrue)
+			So(lrserver.ScriptCached(srv, "script 1"), ShouldBeFalse)
+			So(lrserver.ScriptCached(srv, "script 2"), ShouldBeTrue)
+			So(lrserver.ScriptCached(srv, "script 8"), ShouldBeTrue)
+		})
+
+		Convey("the ETag should change with the script", func() {
+			etag := get(nil).Header.Get("ETag")
+			srv.SetClientJS([]byte("window.custom = true"))
+			So(get(nil).Header.Get("ETag"), ShouldNotEqual, etag)
+		})
+	})
+}
@@ -0,0 +1,88 @@
+package lrserver
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Send broadcasts a custom command to every client, for client plugins
+// extending the protocol. The message is payload with its "command" key
+// set to cmd:
+//
+//	lr.Send("inject-state", map[string]interface{}{"count": 1})
+//	// {"command":"inject-state","count":1}
+//
+// The bundled client passes unknown commands to the command method of
+// each plugin, and fires a LiveReloadCommand event on the document with
+// the message as its detail. It's safe to call from any goroutine.
+//
+// Reloads and alerts sent this way are subject to the same settings as
+// Reload and Alert: a "reload" is sent as ReloadWithOptions would, its
+// path, liveCSS, liveImg, originalPath and overrideURL taken from
+// payload, and an "alert" is dropped if alerts are disabled.
+func (s *Server) Send(cmd string, payload map[string]interface{}) error {
+	return s.sendTo(nil, cmd, payload)
+}
+
+// SendMatching sends a custom command, as for Send, only to the clients
+// whose page URL path matches urlPattern, as for AlertMatching
+func (s *Server) SendMatching(urlPattern, cmd string, payload map[string]interface{}) error {
+	glob, err := newGlobMatcher(urlPattern)
+	if err != nil {
+		return err
+	}
+	return s.sendTo(pageMatcher(glob), cmd, payload)
+}
+
+// sendTo sends a custom command to the clients selected by match,
+// or all of them if match is nil
+func (s *Server) sendTo(match func(*conn) bool, cmd string, payload map[string]interface{}) error {
+	switch cmd {
+	case "", "hello":
+		return fmt.Errorf("lrserver: invalid command %q", cmd)
+	case "reload":
+		file, opts, err := payloadReload(payload)
+		if err != nil {
+			return err
+		}
+		s.reloadTo(match, file, opts)
+		return nil
+	case "alert":
+		if s.AlertsDisabled() {
+			s.logStatus("ignoring alert (alerts disabled)")
+			return nil
+		}
+	}
+	msg := make(map[string]interface{}, len(payload)+1)
+	for k, v := range payload {
+		msg[k] = v
+	}
+	msg["command"] = cmd
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	s.logStatus("sending command: " + cmd)
+	s.broadcastTo(match, json.RawMessage(data), Event{Command: cmd})
+	return nil
+}
+
+// payloadReload gets the path and options of a reload sent as a custom
+// command, leaving the server's settings to decide what payload doesn't
+func payloadReload(payload map[string]interface{}) (string, ReloadOptions, error) {
+	var opts ReloadOptions
+	file, ok := payload["path"].(string)
+	if _, given := payload["path"]; given && !ok {
+		return "", opts, fmt.Errorf("lrserver: reload path must be a string")
+	}
+	if liveCSS, ok := payload["liveCSS"].(bool); ok {
+		opts.liveCSS, opts.NoLiveCSS = liveCSS, !liveCSS
+	}
+	if liveImg, ok := payload["liveImg"].(bool); ok {
+		opts.liveImg, opts.NoLiveImg = liveImg, !liveImg
+	}
+	opts.OriginalPath, _ = payload["originalPath"].(string)
+	opts.OverrideURL, _ = payload["overrideURL"].(string)
+	return file, opts, nil
+}
@@ -0,0 +1,69 @@
+package lrserver_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSend(t *testing.T) {
+	Convey("Given a running server and a connected websocket", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		conn := connect(t, srv)
+		defer conn.Close()
+
+		Convey("custom commands should be sent with their payload", func() {
+			So(srv.Send("inject-state", map[string]interface{}{"count": 1}), ShouldBeNil)
+
+			var msg map[string]interface{}
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			So(conn.ReadJSON(&msg), ShouldBeNil)
+			So(msg, ShouldResemble, map[string]interface{}{"command": "inject-state", "count": float64(1)})
+		})
+
+		read := func() map[string]interface{} {
+			var msg map[string]interface{}
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			So(conn.ReadJSON(&msg), ShouldBeNil)
+			return msg
+		}
+
+		Convey("alerts should be dropped while alerts are disabled", func() {
+			srv.DisableAlerts()
+			So(srv.Send("alert", map[string]interface{}{"message": "hidden"}), ShouldBeNil)
+			So(srv.SendMatching("/**", "alert", map[string]interface{}{"message": "hidden"}), ShouldBeNil)
+			So(srv.Send("marker", nil), ShouldBeNil)
+			So(read()["command"], ShouldEqual, "marker")
+		})
+
+		Convey("reloads should be sent as ReloadWithOptions would", func() {
+			So(srv.Send("reload", map[string]interface{}{"path": "css/main.css", "liveCSS": false, "originalPath": "src/main.less"}), ShouldBeNil)
+			So(read(), ShouldResemble, map[string]interface{}{
+				"command":      "reload",
+				"path":         "css/main.css",
+				"liveCSS":      false,
+				"originalPath": "src/main.less",
+			})
+			So(srv.Send("reload", map[string]interface{}{"path": 1}), ShouldNotBeNil)
+		})
+
+		Convey("reloads should be held while paused", func() {
+			srv.Pause()
+			So(srv.Send("reload", map[string]interface{}{"path": "index.html"}), ShouldBeNil)
+			So(srv.Send("marker", nil), ShouldBeNil)
+			So(read()["command"], ShouldEqual, "marker")
+
+			srv.Resume()
+			msg := read()
+			So(msg["command"], ShouldEqual, "reload")
+			So(msg["path"], ShouldEqual, "index.html")
+		})
+
+		Convey("the handshake command should be refused", func() {
+			So(srv.Send("hello", nil), ShouldNotBeNil)
+			So(srv.Send("", nil), ShouldNotBeNil)
+		})
+	})
+}
@@ -1,30 +1,96 @@
 package lrserver
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
 type Server struct {
-	name      string
-	host      string
-	port      uint16
-	server    *http.Server
-	conns     connSet
-	js        string
-	statusLog *log.Logger
-	liveCSS   bool
+	// 64-bit atomics come first to keep them aligned on 32-bit platforms
+	lastBroadcast int64
+	stats         stats
+
+	name       string
+	host       string
+	prefix     string
+	scriptPath string
+	certFile   string
+	keyFile    string
+	server     *http.Server
+	conns      *connRegistry
+
+	cfg   atomic.Value
+	cfgMu sync.Mutex
+
+	ready        int32
+	draining     int32
+	shuttingDown int32
+	listener     atomic.Value
+	lastReload   atomic.Value
+
+	ctx        context.Context
+	cancel     context.CancelFunc
+	goroutines goroutineGroup
+	endpoints  endpoints
+	watches    watchSet
+	pending    pendingReloads
+	debounced  debouncer
+	run        runner
+	scripts    scriptCache
+	dash       dashboard
+	paused     pauseGate
+	announcer  announcer
+	idle       idleWatch
+	events     eventSubs
+	halted     chan struct{}
+	haltOnce   sync.Once
 }
 
-// New ...
+// New creates a server with the given name, listening on host and port
+// once started. It's equivalent to
+// NewServer(WithName(name), WithHost(host), WithPort(port)).
 func New(name string, host string, port uint16) (*Server, error) {
+	return NewServer(WithName(name), WithHost(host), WithPort(port))
+}
+
+// NewServer creates a server configured by opts. Unless set, the name
+// is DefaultName, and it listens on DefaultHost and DefaultPort.
+func NewServer(opts ...Option) (*Server, error) {
+	o := options{name: DefaultName, host: DefaultHost, port: DefaultPort}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	name, host, port := o.name, o.host, o.port
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	prefix := strings.TrimSuffix(o.prefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		return nil, fmt.Errorf("lrserver: path prefix %q must start with /", o.prefix)
+	}
+	scriptPath := o.scriptPath
+	if scriptPath == "" {
+		scriptPath = prefix + "/livereload.js"
+	} else if !strings.HasPrefix(scriptPath, "/") {
+		return nil, fmt.Errorf("lrserver: script path %q must start with /", scriptPath)
+	}
+
 	// Create router
 	router := http.NewServeMux()
 
@@ -32,60 +98,311 @@ func New(name string, host string, port uint16) (*Server, error) {
 
 	// Create server
 	s := &Server{
-		name: name,
-		host: host,
-		port: port,
+		name:       name,
+		host:       host,
+		prefix:     prefix,
+		scriptPath: scriptPath,
 		server: &http.Server{
-			Handler:  router,
-			ErrorLog: log.New(os.Stderr, logPrefix, 0),
+			ReadHeaderTimeout: DefaultHTTPLimits.ReadHeaderTimeout,
+			IdleTimeout:       DefaultHTTPLimits.IdleTimeout,
+			MaxHeaderBytes:    DefaultHTTPLimits.MaxHeaderBytes,
 		},
-		conns:     make(connSet),
+		conns: newConnRegistry(connShards, 0),
+	}
+	s.server.Handler = limitBodies(s, router)
+	s.server.ErrorLog = log.New(errorLogWriter{s}, "", 0)
+	s.cfg.Store(&settings{
+		port:      port,
+		epoch:     strconv.FormatInt(time.Now().UnixNano(), 36),
+		clock:     SystemClock,
 		statusLog: log.New(os.Stdout, logPrefix, 0),
+		errorLog:  log.New(os.Stderr, logPrefix, 0),
 		liveCSS:   true,
-	}
-
-	// Handle JS
-	router.HandleFunc("/livereload.js", jsHandler(s))
-
-	// Handle reload requests
-	router.HandleFunc("/livereload", webSocketHandler(s))
 
+		clientVersion: clientVersion,
+
+		pollInterval: DefaultPollInterval,
+		windowsPaths: runtime.GOOS == "windows",
+
+		maxQueuedMessages: DefaultMaxQueuedMessages,
+		maxQueuedBytes:    DefaultMaxQueuedBytes,
+		httpLimits:        DefaultHTTPLimits,
+		writeTimeout:      DefaultWriteTimeout,
+		debounceMaxWait:   DefaultDebounceMaxWait,
+		pingInterval:      DefaultPingInterval,
+		pongTimeout:       DefaultPongTimeout,
+	})
+
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.halted = make(chan struct{})
+	s.endpoints.paths = builtinEndpoints(s)
+	mount(router, s)
+
+	// Handle probes and status requests
+	router.HandleFunc(prefix+"/livereload/readyz", readyHandler(s))
+	router.HandleFunc(prefix+"/livereload/healthz", healthHandler(s))
+	router.HandleFunc(prefix+"/livereload/status", statusHandler(s))
+
+	for _, setup := range o.setup {
+		if err := setup(s); err != nil {
+			return nil, err
+		}
+	}
 	return s, nil
 }
 
 func (s *Server) ListenAndServe() error {
-	// Create listener
-	l, err := net.Listen("tcp", s.Addr())
+	l, err := s.listen()
 	if err != nil {
 		return err
 	}
+	return s.Serve(l)
+}
 
-	// Set assigned port if necessary
-	if s.port == 0 {
-		addr := strings.Split(l.Addr().String(), ":")
-		port, _ := strconv.ParseUint(addr[1], 10, 16)
-		s.host, s.port = addr[0], uint16(port)
+// Serve serves on an existing listener, such as one passed in by systemd
+// socket activation. If l isn't a TCP listener, e.g. a Unix socket behind
+// a reverse proxy, its address means nothing to browsers, so the served
+// client targets the page's own host and port as if attached to a mux.
+// It serves HTTPS if the server was created with WithTLS.
+func (s *Server) Serve(l net.Listener) error {
+	if s.certFile != "" || s.keyFile != "" {
+		return s.serve(l, true, s.certFile, s.keyFile)
 	}
-	s.js = fmt.Sprintf(js, s.host, s.port)
+	return s.serve(l, false, "", "")
+}
+
+// ListenAndServeTLS is like ListenAndServe, but serves HTTPS using the
+// certificate and key in certFile and keyFile. The client script then
+// connects with wss:// unless told otherwise by the public URL.
+func (s *Server) ListenAndServeTLS(certFile, keyFile string) error {
+	l, err := s.listen()
+	if err != nil {
+		return err
+	}
+	return s.serve(l, true, certFile, keyFile)
+}
 
-	s.logStatus("listening on " + s.server.Addr)
+// ServeTLS is like Serve, but serves HTTPS using the certificate and key
+// in certFile and keyFile.
+func (s *Server) ServeTLS(l net.Listener, certFile, keyFile string) error {
+	return s.serve(l, true, certFile, keyFile)
+}
+
+// serve serves on l until the server is shut down
+func (s *Server) serve(l net.Listener, tls bool, certFile, keyFile string) error {
+	addr := l.Addr().String()
+	if _, ok := l.Addr().(*net.TCPAddr); !ok {
+		s.update(func(cfg *settings) { cfg.sameOrigin = true })
+	} else {
+		if err := s.assignPort(l); err != nil {
+			l.Close()
+			return err
+		}
+		addr = s.Addr()
+	}
+
+	s.logStatus("listening on " + addr)
+	s.emit(Event{Type: EventListening, Addr: addr})
+	s.setListening(&listenerInfo{tls: tls, since: s.now()})
+	defer s.setListening(nil)
+	s.announceServing()
+	defer s.stopAnnouncing()
+	s.openBrowser()
+	atomic.StoreInt32(&s.ready, 1)
+	defer atomic.StoreInt32(&s.ready, 0)
+	if tls {
+		return s.server.ServeTLS(l, certFile, keyFile)
+	}
 	return s.server.Serve(l)
 }
 
-// Reload sends a reload message to the client
+// assignPort sets the port to the one l was assigned, if it was zero
+func (s *Server) assignPort(l net.Listener) error {
+	if s.Port() != 0 {
+		return nil
+	}
+	port, err := makePort(l.Addr().String())
+	if err != nil {
+		return err
+	}
+	s.update(func(cfg *settings) { cfg.port = port })
+	return nil
+}
+
+// Reload sends a reload message to the client, after the debounce
+// window if one is set. It's safe to call from any goroutine.
 func (s *Server) Reload(file string) {
+	if d := s.Debounce(); d > 0 {
+		s.debounceReload(d, file)
+		return
+	}
+	s.ReloadWithOptions(file, ReloadOptions{})
+}
+
+// ReloadOptions sets the optional fields of a single reload message
+type ReloadOptions struct {
+	// NoLiveCSS reloads the page even for a stylesheet,
+	// whatever the server's LiveCSS setting
+	NoLiveCSS bool
+
+	// NoLiveImg reloads the page even for an image, rather than
+	// refreshing the image in place
+	NoLiveImg bool
+
+	// OriginalPath is the source file behind the reloaded path, such
+	// as the .less file a stylesheet was built from
+	OriginalPath string
+
+	// OverrideURL is fetched in place of reloaded stylesheets and
+	// images, with their original URL in its url query parameter
+	OverrideURL string
+
+	// Delay waits before sending the reload, e.g. for a site generator
+	// still writing files after the change was seen. Zero uses the
+	// server's reload delay, and a negative Delay sends it straight away.
+	Delay time.Duration
+
+	// liveCSS and liveImg refresh stylesheets and images in place
+	// whatever the server's settings
+	liveCSS, liveImg bool
+
+	// asCSS broadcasts other files as the stylesheets built from them
+	asCSS bool
+}
+
+// ReloadWithOptions is like Reload, but sets the message's optional
+// fields from opts, e.g. to refresh an image without reloading the page
+// or to map a build output to the URL the browser actually loaded.
+func (s *Server) ReloadWithOptions(file string, opts ReloadOptions) {
+	s.reloadTo(nil, file, opts)
+}
+
+// ReloadCSS refreshes the stylesheet file in place, without reloading
+// the page, even if LiveCSS is off
+func (s *Server) ReloadCSS(file string) {
+	s.reloadTo(nil, file, ReloadOptions{liveCSS: true})
+}
+
+// ReloadImage refreshes the image file in place, without reloading the
+// page
+func (s *Server) ReloadImage(file string) {
+	s.reloadTo(nil, file, ReloadOptions{liveImg: true})
+}
+
+// ReloadPage reloads the whole page for file, even if it's a stylesheet
+// or an image that could be refreshed in place
+func (s *Server) ReloadPage(file string) {
+	s.reloadTo(nil, file, ReloadOptions{NoLiveCSS: true, NoLiveImg: true})
+}
+
+// reloadTo reloads file for the connections selected by match,
+// or all of them if match is nil
+func (s *Server) reloadTo(match func(*conn) bool, file string, opts ReloadOptions) {
+	if s.reloadKind(file) == NoReload {
+		return
+	}
+	if match == nil && s.holdPaused(file) || match != nil && s.holdMatched(match, file, opts) {
+		return
+	}
+	if opts.Delay == 0 {
+		opts.Delay = s.ReloadDelay()
+	}
+	if opts.Delay > 0 {
+		d := opts.Delay
+		opts.Delay = -1
+		s.Clock().AfterFunc(d, func() { s.reloadTo(match, file, opts) })
+		return
+	}
+	if !s.runBuildHook([]string{file}) {
+		return
+	}
+	if !s.runCommands(file) {
+		return
+	}
+	for _, target := range s.reloadTargets(file) {
+		s.reload(match, target, opts)
+	}
+}
+
+func (s *Server) reload(match func(*conn) bool, file string, opts ReloadOptions) {
+	kind := s.reloadKind(file)
+	if kind == NoReload {
+		return
+	}
+	kind.apply(&opts)
+	file = s.broadcastPath(file)
+	if opts.asCSS && !isStylesheet(file) {
+		if opts.OriginalPath == "" {
+			opts.OriginalPath = file
+		}
+		file = strings.TrimSuffix(file, path.Ext(file)) + ".css"
+	}
+	resp := makeServerReload(file, (s.LiveCSS() || opts.liveCSS) && !opts.NoLiveCSS)
+	switch {
+	case opts.NoLiveImg:
+		resp.LiveImg = new(bool)
+	case opts.liveImg:
+		liveImg := true
+		resp.LiveImg = &liveImg
+	}
+	resp.OriginalPath, resp.OverrideURL = opts.OriginalPath, opts.OverrideURL
+
 	s.logStatus("requesting reload: " + file)
-	for conn := range s.conns {
-		conn.reloadChan <- file
+	delivered := Event{Command: "reload", Path: file}
+	if match != nil || !s.holdReload(resp, delivered) {
+		s.broadcastTo(match, resp, delivered)
+	} else if data, err := json.Marshal(resp); err == nil {
+		// Held for this server's clients, but others may have some
+		s.publish(data, delivered)
 	}
+	s.lastReload.Store(&reloadInfo{path: file, at: s.now()})
+	s.emit(Event{Type: EventReload, Path: file})
+	s.notify("reload", file, "")
 }
 
-// Alert sends an alert message to the client
+// Alert sends an alert message to the client.
+// It's safe to call from any goroutine.
 func (s *Server) Alert(msg string) {
-	s.logStatus("requesting alert: " + msg)
-	for conn := range s.conns {
-		conn.alertChan <- msg
+	s.AlertWithOptions(msg, AlertOptions{})
+}
+
+// AlertLevel is the severity of an alert
+type AlertLevel string
+
+// Alert levels
+const (
+	AlertInfo    AlertLevel = "info"
+	AlertWarning AlertLevel = "warning"
+	AlertError   AlertLevel = "error"
+)
+
+// AlertOptions sets how the bundled client shows an alert. It renders
+// alerts as toasts over the page rather than modal dialogs; stock
+// clients ignore the options and call alert().
+type AlertOptions struct {
+	// Level colors the toast, AlertInfo by default
+	Level AlertLevel
+
+	// Duration is how long the toast is shown, five seconds by default
+	Duration time.Duration
+
+	// Sticky keeps the toast until it's clicked
+	Sticky bool
+}
+
+// AlertWithOptions sends an alert message to the client, shown as set
+// by opts. It's safe to call from any goroutine.
+func (s *Server) AlertWithOptions(msg string, opts AlertOptions) {
+	if s.AlertsDisabled() {
+		s.logStatus("ignoring alert (alerts disabled): " + msg)
+		return
 	}
+	s.logStatus("requesting alert: " + msg)
+	resp := makeServerAlert(msg)
+	resp.Level, resp.Duration, resp.Sticky = string(opts.Level), opts.Duration.Milliseconds(), opts.Sticky
+	s.broadcast(resp, Event{Command: "alert", Message: msg})
+	s.emit(Event{Type: EventAlert, Message: msg})
+	s.notify("alert", "", msg)
 }
 
 // Name gets the server name
@@ -95,7 +412,7 @@ func (s *Server) Name() string {
 
 // Addr get the host:port that the server is listening on
 func (s *Server) Addr() string {
-	return fmt.Sprintf("%s:%d", s.Host(), s.Port())
+	return net.JoinHostPort(s.Host(), strconv.Itoa(int(s.Port())))
 }
 
 // Host gets the host that the server is listening on
@@ -105,68 +422,309 @@ func (s *Server) Host() string {
 
 // Port gets the port that the server is listening on
 func (s *Server) Port() uint16 {
-	return s.port
+	return s.settings().port
 }
 
 // LiveCSS gets the live CSS preference
 func (s *Server) LiveCSS() bool {
-	return s.liveCSS
+	return s.settings().liveCSS
+}
+
+// StrictCSP gets the strict CSP preference
+func (s *Server) StrictCSP() bool {
+	return s.settings().strictCSP
+}
+
+// JSDisabled reports whether the client JavaScript endpoints are disabled
+func (s *Server) JSDisabled() bool {
+	return s.settings().jsDisabled
+}
+
+// AlertsDisabled reports whether alerts are disabled
+func (s *Server) AlertsDisabled() bool {
+	return s.settings().alertsDisabled
+}
+
+// AlertStaleScripts reports whether pages running a stale client
+// script are alerted
+func (s *Server) AlertStaleScripts() bool {
+	return s.settings().alertStaleScripts
+}
+
+// ReadOnly gets the read-only protocol preference
+func (s *Server) ReadOnly() bool {
+	return s.settings().readOnly
+}
+
+// PublicURL gets the URL browsers use to reach the server,
+// or an empty string if not set
+func (s *Server) PublicURL() string {
+	u := s.settings().publicURL
+	if u == nil {
+		return ""
+	}
+	return u.String()
+}
+
+// Epoch gets the ID identifying this run of the server to clients
+func (s *Server) Epoch() string {
+	return s.settings().epoch
+}
+
+// WindowsPaths gets the Windows path normalization preference
+func (s *Server) WindowsPaths() bool {
+	return s.settings().windowsPaths
 }
 
 // StatusLog gets the server's status logger,
 // which writes to os.Stdout by default
 func (s *Server) StatusLog() *log.Logger {
-	return s.statusLog
+	return s.settings().statusLog
 }
 
 // ErrorLog gets the server's error logger,
 // which writes to os.Stderr by default
 func (s *Server) ErrorLog() *log.Logger {
-	return s.server.ErrorLog
+	return s.settings().errorLog
 }
 
 // SetLiveCSS sets the live CSS preference
 func (s *Server) SetLiveCSS(n bool) {
-	s.liveCSS = n
+	s.update(func(cfg *settings) { cfg.liveCSS = n })
+}
+
+// SetStrictCSP sets the strict CSP preference. When enabled, the client
+// JavaScript is only served if it needs neither inline script nor eval.
+func (s *Server) SetStrictCSP(n bool) {
+	s.update(func(cfg *settings) { cfg.strictCSP = n })
+}
+
+// DisableJS stops serving the client JavaScript, for users relying
+// exclusively on the LiveReload browser extensions. Requests for
+// the script get a 404 response.
+func (s *Server) DisableJS() {
+	s.update(func(cfg *settings) { cfg.jsDisabled = true })
+}
+
+// SetJSDisabledMessage sets the explanation sent along with the 404
+// response when the client JavaScript is disabled
+func (s *Server) SetJSDisabledMessage(msg string) {
+	s.update(func(cfg *settings) { cfg.jsDisabledMsg = msg })
+}
+
+// DisableAlerts makes Alert a logged no-op, and stops advertising
+// remote control support to clients that connect afterwards
+func (s *Server) DisableAlerts() {
+	s.update(func(cfg *settings) { cfg.alertsDisabled = true })
+}
+
+// SetAlertStaleScripts sets whether pages running a different version of
+// the client script than the one served, usually a stale cached copy, are
+// sent an alert. The mismatch is logged either way.
+func (s *Server) SetAlertStaleScripts(n bool) {
+	s.update(func(cfg *settings) { cfg.alertStaleScripts = n })
+}
+
+// SetReadOnly sets the read-only protocol preference. When enabled,
+// every client message after the handshake is discarded unread,
+// making the server a pure broadcaster.
+func (s *Server) SetReadOnly(n bool) {
+	s.update(func(cfg *settings) { cfg.readOnly = n })
+}
+
+// SetPublicURL sets the URL browsers use to reach the server, when that
+// differs from the address it listens on. Both http(s):// and ws(s)://
+// URLs are accepted; only the scheme, host and port are used.
+func (s *Server) SetPublicURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "ws", "wss":
+	default:
+		return fmt.Errorf("lrserver: unsupported public URL scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("lrserver: public URL %q has no host", rawURL)
+	}
+	s.update(func(cfg *settings) { cfg.publicURL = u })
+	return nil
+}
+
+// SetEpoch sets the ID identifying this run of the server, such as a
+// build ID. It defaults to the time the server was created. Clients
+// reconnecting with a different epoch, like pages left open across a
+// restart, are told to reload the whole page.
+func (s *Server) SetEpoch(id string) {
+	s.update(func(cfg *settings) { cfg.epoch = id })
+}
+
+// SetWindowsPaths sets the Windows path normalization preference,
+// which is on by default on Windows. When enabled, reloaded paths have
+// their drive letter or UNC share stripped and backslashes converted
+// to slashes, so livereload.js can match them against URLs.
+func (s *Server) SetWindowsPaths(n bool) {
+	s.update(func(cfg *settings) { cfg.windowsPaths = n })
 }
 
 // SetStatusLog sets the server's status logger,
 // which can be set to nil
 func (s *Server) SetStatusLog(l *log.Logger) {
-	s.statusLog = l
+	s.update(func(cfg *settings) { cfg.statusLog, cfg.logger = l, nil })
 }
 
 // SetErrorLog sets the server's error logger,
 // which can be set to nil
 func (s *Server) SetErrorLog(l *log.Logger) {
-	s.server.ErrorLog = l
+	s.update(func(cfg *settings) { cfg.errorLog, cfg.logger = l, nil })
+}
+
+// SetOutput redirects both the status and error logs to w, keeping
+// their prefix and flags. It's safe to call while serving, e.g. when
+// an application's UI takes over the terminal.
+func (s *Server) SetOutput(w io.Writer) {
+	s.update(func(cfg *settings) {
+		cfg.statusLog = redirectLog(cfg.statusLog, w, s.name)
+		cfg.errorLog = redirectLog(cfg.errorLog, w, s.name)
+		cfg.logger = nil
+	})
+}
+
+// redirectLog creates a logger like l writing to w, with the default
+// prefix if l is nil
+func redirectLog(l *log.Logger, w io.Writer, name string) *log.Logger {
+	if l == nil {
+		return log.New(w, "["+name+"] ", 0)
+	}
+	return log.New(w, l.Prefix(), l.Flags())
 }
 
-func (s *Server) newConn(wsConn *websocket.Conn) {
+func (s *Server) newConn(wsConn *websocket.Conn, remoteAddr, userAgent string) {
+	// Turn away clients arriving through an attached mux mid-shutdown
+	if atomic.LoadInt32(&s.shuttingDown) == 1 {
+		closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, "")
+		wsConn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second))
+		wsConn.Close()
+		return
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
 	c := &conn{
-		conn: wsConn,
+		id:         nextConnID(),
+		conn:       wsConn,
+		remoteAddr: remoteAddr,
+		userAgent:  userAgent,
 
-		server:    s,
-		handshake: false,
+		server: s,
 
-		reloadChan: make(chan string),
-		alertChan:  make(chan string),
-		closeChan:  make(chan closeSignal),
+		queue:  newSendQueue(),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	if !s.admit(c) {
+		cancel()
+		rejectWebSocket(wsConn)
+		return
+	}
+	s.spawn(c.start)
+}
+
+// broadcast queues resp for every connection, describing it by delivered
+// once it has been sent
+func (s *Server) broadcast(resp interface{}, delivered Event) {
+	s.broadcastTo(nil, resp, delivered)
+}
+
+// broadcastTo queues resp for the connections selected by match,
+// or all of them if match is nil
+func (s *Server) broadcastTo(match func(*conn) bool, resp interface{}, delivered Event) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		s.logError(err)
+		return
 	}
-	s.conns.add(c)
-	go c.start()
+	if match == nil {
+		s.publish(data, delivered)
+	}
+	s.sendAll(match, data, delivered)
+}
+
+// sendAll queues encoded data for the connections selected by match,
+// or all of them if match is nil
+func (s *Server) sendAll(match func(*conn) bool, data []byte, delivered Event) {
+	start := s.now()
+	atomic.StoreInt64(&s.lastBroadcast, start.UnixNano())
+	s.conns.broadcast(func(c *conn) {
+		if match == nil || match(c) {
+			c.send(data, delivered)
+		}
+	})
+	s.timing("broadcast", start)
 }
 
-func (s *Server) logStatus(msg ...interface{}) {
-	if s.statusLog != nil {
-		s.statusLog.Println(msg...)
+// protocols lists the protocols advertised in the server hello
+func (s *Server) protocols() []string {
+	if !s.AlertsDisabled() {
+		return protocols
 	}
+	p := make([]string, 0, len(protocols))
+	for _, proto := range protocols {
+		if proto != remoteControlProtocol {
+			p = append(p, proto)
+		}
+	}
+	return p
 }
 
-func (s *Server) logError(msg ...interface{}) {
-	if s.server.ErrorLog != nil {
-		s.server.ErrorLog.Println(msg...)
+// renderScript fills in the client script template for req
+func (s *Server) renderScript(tmpl string, req *http.Request) string {
+	secure, host, port := s.scriptTarget(req)
+	return fmt.Sprintf(tmpl, secure, jsString(host), port, jsString(s.prefix), jsString(s.scriptToken(req)))
+}
+
+// scriptTarget gets the address the client script served to req
+// connects to
+func (s *Server) scriptTarget(req *http.Request) (secure bool, host string, port uint16) {
+	cfg := s.settings()
+	secure = s.requestIsSecure(req)
+	host, port = s.clientHost(req), cfg.port
+
+	// Target the public URL if set, otherwise the page's own origin when
+	// attached to an application's mux
+	switch {
+	case cfg.publicURL != nil:
+		secure = cfg.publicURL.Scheme == "https" || cfg.publicURL.Scheme == "wss"
+		host, port = publicHostPort(cfg.publicURL)
+	case cfg.sameOrigin:
+		host, port = s.requestHostPort(req, secure)
 	}
+	return secure, host, port
+}
+
+// jsString encodes str as a JavaScript string literal, which can't end
+// the script element it's in, since JSON escapes < and >
+func jsString(str string) string {
+	b, _ := json.Marshal(str)
+	return string(b)
+}
+
+// closeConns closes every connection with closeCode
+func (s *Server) closeConns(closeCode int) {
+	s.conns.each(func(c *conn) {
+		c.close(closeCode, nil)
+	})
+}
+
+// errorLogWriter passes the http.Server's errors on to whichever
+// logger is current
+type errorLogWriter struct {
+	s *Server
+}
+
+func (w errorLogWriter) Write(p []byte) (int, error) {
+	w.s.Logger().Error(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
 }
 
 // makeAddr converts uint16(x) to ":x"
@@ -174,6 +732,17 @@ func makeAddr(port uint16) string {
 	return fmt.Sprintf(":%d", port)
 }
 
+// publicHostPort gets the host and port of a public URL
+func publicHostPort(u *url.URL) (string, uint16) {
+	if port, err := strconv.ParseUint(u.Port(), 10, 16); err == nil {
+		return u.Hostname(), uint16(port)
+	}
+	if u.Scheme == "https" || u.Scheme == "wss" {
+		return u.Hostname(), 443
+	}
+	return u.Hostname(), 80
+}
+
 // makePort converts ":x" to uint16(x)
 func makePort(addr string) (uint16, error) {
 	_, portString, err := net.SplitHostPort(addr)
@@ -0,0 +1,96 @@
+package lrserver
+
+import (
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// settings holds the server's runtime-tunable configuration. A published
+// settings value is never modified; setters swap in an updated copy, so
+// connection and handler goroutines always read a consistent snapshot.
+type settings struct {
+	port      uint16
+	lastPort  uint16
+	epoch     string
+	clock     Clock
+	statusLog *log.Logger
+	errorLog  *log.Logger
+	logger    *slog.Logger
+	liveCSS   bool
+	strictCSP bool
+
+	jsDisabled    bool
+	jsDisabledMsg string
+	clientJS      string
+	clientVersion string
+
+	alertsDisabled    bool
+	alertStaleScripts bool
+	readOnly          bool
+
+	trustedProxies []*net.IPNet
+	allowedHosts   []string
+	allowedOrigins []string
+	checkOrigin    func(*http.Request) bool
+	sameOrigin     bool
+	publicURL      *url.URL
+	advertisedHost string
+	authToken      string
+	openBrowser    string
+	pollInterval   time.Duration
+	polling        bool
+	windowsPaths   bool
+	pathPolicy     PathPolicy
+	webRoot        string
+	webPathFunc    WebPathFunc
+	pathMappers    []PathMapper
+	reloadPolicy   map[string]ReloadKind
+	depGraph       *DepGraph
+	sourceMappers  []SourceMapper
+	commands       []*compiledCommand
+	buildHook      BuildHook
+	notifiers      []Notifier
+	metricsSinks   []MetricsSink
+	eventWriter    *eventWriter
+	broker         *brokerLink
+
+	connectHandler    func(ConnInfo)
+	disconnectHandler func(ConnInfo)
+
+	maxQueuedMessages int
+	maxQueuedBytes    int
+	slowClientPolicy  SlowClientPolicy
+	rateLimit         RateLimit
+	maxConnections    int
+	idleShutdown      time.Duration
+	onIdle            func()
+	pendingReloads    int
+	debounce          time.Duration
+	debounceMaxWait   time.Duration
+	reloadDelay       time.Duration
+	httpLimits        HTTPLimits
+	writeTimeout      time.Duration
+	upgrader          UpgraderConfig
+	pingInterval      time.Duration
+	pongTimeout       time.Duration
+}
+
+// settings gets the current settings snapshot
+func (s *Server) settings() *settings {
+	return s.cfg.Load().(*settings)
+}
+
+// update publishes a copy of the settings as modified by f. Slices must
+// be copied rather than appended to in place, since older snapshots may
+// still be in use.
+func (s *Server) update(f func(*settings)) {
+	s.cfgMu.Lock()
+	defer s.cfgMu.Unlock()
+	cfg := *s.settings()
+	f(&cfg)
+	s.cfg.Store(&cfg)
+}
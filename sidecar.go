@@ -0,0 +1,154 @@
+package lrserver
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Environment variables read by SidecarConfigFromEnv
+const (
+	EnvWatchDir     = "LRSERVER_WATCH_DIR"
+	EnvPublicURL    = "LRSERVER_PUBLIC_URL"
+	EnvPollInterval = "LRSERVER_POLL_INTERVAL"
+	EnvDrainDelay   = "LRSERVER_DRAIN_DELAY"
+	EnvGracePeriod  = "LRSERVER_GRACE_PERIOD"
+)
+
+// DefaultGracePeriod is the default sidecar shutdown grace period
+const DefaultGracePeriod = 25 * time.Second
+
+// SidecarConfig configures RunSidecar
+type SidecarConfig struct {
+	// WatchDir is the shared volume to watch. Changed paths are
+	// broadcast relative to it.
+	WatchDir string
+
+	// PublicURL is the URL browsers use to reach the server, such as
+	// an ingress or port-forward address. Optional.
+	PublicURL string
+
+	// PollInterval is how often WatchDir is scanned. Shared volumes don't
+	// reliably deliver inotify events across containers, so they're polled.
+	PollInterval time.Duration
+
+	// DrainDelay is how long to keep serving, while reporting not ready,
+	// after SIGTERM, giving endpoints time to stop routing to the pod
+	DrainDelay time.Duration
+
+	// GracePeriod bounds the shutdown after the drain delay. It should be
+	// shorter than the pod's terminationGracePeriodSeconds.
+	GracePeriod time.Duration
+}
+
+// SidecarConfigFromEnv reads a SidecarConfig from LRSERVER_* environment
+// variables, which can be populated from the downward API
+func SidecarConfigFromEnv() (SidecarConfig, error) {
+	cfg := SidecarConfig{
+		WatchDir:     os.Getenv(EnvWatchDir),
+		PublicURL:    os.Getenv(EnvPublicURL),
+		PollInterval: DefaultPollInterval,
+		GracePeriod:  DefaultGracePeriod,
+	}
+
+	durations := map[string]*time.Duration{
+		EnvPollInterval: &cfg.PollInterval,
+		EnvDrainDelay:   &cfg.DrainDelay,
+		EnvGracePeriod:  &cfg.GracePeriod,
+	}
+	for env, d := range durations {
+		v := os.Getenv(env)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, err
+		}
+		*d = parsed
+	}
+	return cfg, nil
+}
+
+// RunSidecar serves s as a dev-cluster sidecar until ctx is cancelled or
+// the process receives SIGTERM or an interrupt. It reloads browsers when
+// files under cfg.WatchDir change, and reports readiness at
+// /livereload/readyz.
+func (s *Server) RunSidecar(ctx context.Context, cfg SidecarConfig) error {
+	if cfg.PublicURL != "" {
+		err := s.SetPublicURL(cfg.PublicURL)
+		if err != nil {
+			return err
+		}
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	if cfg.GracePeriod <= 0 {
+		cfg.GracePeriod = DefaultGracePeriod
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// Serve
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- s.ListenAndServe()
+	}()
+
+	// Watch
+	if cfg.WatchDir != "" {
+		src := newPollingSource(cfg.WatchDir, cfg.PollInterval, s.Clock(), nil, true)
+		defer src.Close()
+		s.AddEventSource(src)
+	}
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Stop reporting ready, and keep serving while endpoints catch up
+	s.logStatus("terminating")
+	atomic.StoreInt32(&s.draining, 1)
+	defer atomic.StoreInt32(&s.draining, 0)
+	if cfg.DrainDelay > 0 {
+		<-s.Clock().After(cfg.DrainDelay)
+	}
+
+	shutdownCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer s.Clock().AfterFunc(cfg.GracePeriod, cancel).Stop()
+	err := s.Shutdown(shutdownCtx)
+	if err != nil {
+		return err
+	}
+	if err = <-errChan; err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Ready reports whether the server is listening and not shutting down
+func (s *Server) Ready() bool {
+	return atomic.LoadInt32(&s.ready) == 1 && atomic.LoadInt32(&s.draining) == 0
+}
+
+func readyHandler(s *Server) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if !s.Ready() {
+			http.Error(rw, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		_, err := rw.Write([]byte("ok\n"))
+		if err != nil {
+			s.logError(err)
+		}
+	}
+}
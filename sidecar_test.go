@@ -0,0 +1,127 @@
+package lrserver_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSidecar(t *testing.T) {
+	Convey("Given sidecar environment variables", t, func() {
+		os.Setenv(lrserver.EnvWatchDir, "/shared")
+		os.Setenv(lrserver.EnvPublicURL, "wss://preview.example.com/")
+		os.Setenv(lrserver.EnvGracePeriod, "5s")
+		defer os.Unsetenv(lrserver.EnvWatchDir)
+		defer os.Unsetenv(lrserver.EnvPublicURL)
+		defer os.Unsetenv(lrserver.EnvGracePeriod)
+
+		Convey("they should be read into a config", func() {
+			cfg, err := lrserver.SidecarConfigFromEnv()
+			So(err, ShouldBeNil)
+			So(cfg.WatchDir, ShouldEqual, "/shared")
+			So(cfg.PublicURL, ShouldEqual, "wss://preview.example.com/")
+			So(cfg.GracePeriod, ShouldEqual, 5*time.Second)
+			So(cfg.PollInterval, ShouldEqual, lrserver.DefaultPollInterval)
+		})
+
+		Convey("invalid durations should be rejected", func() {
+			os.Setenv(lrserver.EnvPollInterval, "often")
+			defer os.Unsetenv(lrserver.EnvPollInterval)
+
+			_, err := lrserver.SidecarConfigFromEnv()
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given a server running as a sidecar", t, func() {
+		dir, err := ioutil.TempDir("", "lrserver")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		srv, err := lrserver.New(lrserver.DefaultName, lrserver.DefaultHost, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.SetStatusLog(nil)
+		srv.SetErrorLog(nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			done <- srv.RunSidecar(ctx, lrserver.SidecarConfig{
+				WatchDir:     dir,
+				PublicURL:    "https://preview.example.com",
+				PollInterval: 10 * time.Millisecond,
+			})
+		}()
+		defer cancel()
+		time.Sleep(10 * time.Millisecond)
+
+		Convey("it should report ready", func() {
+			resp, err := http.Get(
+				fmt.Sprintf("http%s:%d/livereload/readyz", localhost, srv.Port()),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			So(srv.Ready(), ShouldBeTrue)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("the client should target the public URL", func() {
+			So(srv.ScriptURL(), ShouldEqual, "https://preview.example.com/livereload.js")
+		})
+
+		Convey("changes in the watched dir should be reloaded", func() {
+			conn, _ := dial(t, srv, nil)
+			defer conn.Close()
+			err := conn.WriteJSON(clientHello)
+			if err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(10 * time.Millisecond)
+
+			err = os.MkdirAll(filepath.Join(dir, "css"), 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = ioutil.WriteFile(filepath.Join(dir, "css", "main.css"), []byte("body {}"), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sr := new(serverReload)
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			err = conn.ReadJSON(sr)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "css/main.css")
+		})
+
+		Convey("cancelling should shut it down", func() {
+			conn, _ := dial(t, srv, nil)
+			defer conn.Close()
+			cancel()
+
+			select {
+			case err := <-done:
+				So(err, ShouldBeNil)
+			case <-time.After(time.Second):
+				t.Fatal("sidecar did not shut down")
+			}
+			So(srv.Ready(), ShouldBeFalse)
+			So(srv.ActiveGoroutines(), ShouldEqual, 0)
+		})
+	})
+}
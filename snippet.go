@@ -0,0 +1,120 @@
+package lrserver
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// cspHostile lists constructs that a strict Content-Security-Policy
+// (no 'unsafe-inline', no 'unsafe-eval') would block
+var cspHostile = []struct {
+	name string
+	re   *regexp.Regexp
+}{
+	{"eval", regexp.MustCompile(`\beval\s*\(`)},
+	{"Function constructor", regexp.MustCompile(`\bFunction\s*\(`)},
+	{"string timer", regexp.MustCompile(`\bset(Timeout|Interval)\s*\(\s*['"]`)},
+	{"document.write", regexp.MustCompile(`\bdocument\.write(ln)?\s*\(`)},
+	{"javascript URL", regexp.MustCompile(`(?i)javascript:`)},
+	{"inline handler", regexp.MustCompile(`(?i)<[^>]+\son[a-z]+\s*=`)},
+	{"inline script", regexp.MustCompile(`(?is)<script\b[^>]*>\s*[^<\s][^<]*</script`)},
+}
+
+// cspViolations lists the CSP-hostile constructs found in src
+func cspViolations(src string) []string {
+	var found []string
+	for _, c := range cspHostile {
+		if c.re.MatchString(src) {
+			found = append(found, c.name)
+		}
+	}
+	return found
+}
+
+// ScriptURL gets the URL of the client JavaScript. It is based on the
+// public URL if set, and when attached to an application's mux it is
+// relative to the page's own origin. Otherwise it uses https:// while the
+// server serves TLS.
+func (s *Server) ScriptURL() string {
+	return s.scriptURL(nil)
+}
+
+// scriptURL gets the ScriptURL for a page served in response to req,
+// which when listening on all interfaces is the page's own host rather
+// than localhost, unless an advertised host is set. req may be nil.
+func (s *Server) scriptURL(req *http.Request) string {
+	return s.scriptLocation(req) + s.tokenQuery("?")
+}
+
+// scriptLocation gets the scriptURL without the auth token
+func (s *Server) scriptLocation(req *http.Request) string {
+	cfg := s.settings()
+	if cfg.publicURL != nil {
+		scheme := "http"
+		if cfg.publicURL.Scheme == "https" || cfg.publicURL.Scheme == "wss" {
+			scheme = "https"
+		}
+		return scheme + "://" + cfg.publicURL.Host + s.scriptPath
+	}
+	if cfg.sameOrigin {
+		return s.scriptPath
+	}
+
+	host := s.clientHost(req)
+	if unspecifiedHost(host) {
+		host = "localhost"
+	}
+	scheme := "http"
+	if s.servingTLS() {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(host, strconv.Itoa(int(cfg.port))), s.scriptPath)
+}
+
+// ScriptTag gets an HTML script tag that loads the client JavaScript
+// from ScriptURL. It contains no inline script, so it is safe to use
+// in strict CSP mode.
+func (s *Server) ScriptTag() string {
+	return s.scriptTag(nil)
+}
+
+// scriptTag gets the ScriptTag for a page served in response to req,
+// which may be nil
+func (s *Server) scriptTag(req *http.Request) string {
+	return scriptElement(s.scriptURL(req))
+}
+
+// scriptElement gets a script tag loading src
+func scriptElement(src string) string {
+	return `<script src="` + html.EscapeString(src) + `"></script>`
+}
+
+// Snippet gets the HTML that loads the client into a page, using the
+// port actually listened on. While listening on all interfaces it's the
+// inline snippet recommended by the LiveReload docs, which loads the
+// script from whichever host the page was opened on, so it also works
+// from other devices. With a public URL, when attached to an
+// application's mux, in strict CSP mode, or on a specific host, it's
+// ScriptTag.
+func (s *Server) Snippet() string {
+	cfg := s.settings()
+	if cfg.publicURL != nil || cfg.sameOrigin || cfg.strictCSP || s.host != "" {
+		return s.ScriptTag()
+	}
+	scheme := "http"
+	if s.servingTLS() {
+		scheme = "https"
+	}
+	return fmt.Sprintf(`<script>document.write('<script src="%s://' + (location.hostname || 'localhost') + ':%d%s?snipver=1%s"></' + 'script>')</script>`, scheme, cfg.port, html.EscapeString(s.scriptPath), html.EscapeString(s.tokenQuery("&")))
+}
+
+// SnippetHTML gets Snippet as template.HTML, to include in html/template
+// pages unescaped
+func (s *Server) SnippetHTML() template.HTML {
+	return template.HTML(s.Snippet())
+}
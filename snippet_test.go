@@ -0,0 +1,58 @@
+package lrserver_test
+
+import (
+	"fmt"
+	"html/template"
+	"testing"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSnippet(t *testing.T) {
+	Convey("Given a server listening on an assigned port on all interfaces", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		So(srv.Port(), ShouldNotEqual, 0)
+
+		Convey("Snippet should load the script from the page's host and the actual port", func() {
+			So(srv.Snippet(), ShouldEqual, fmt.Sprintf(
+				`<script>document.write('<script src="http://' + (location.hostname || 'localhost') + ':%d/livereload.js?snipver=1"></' + 'script>')</script>`,
+				srv.Port(),
+			))
+			So(srv.SnippetHTML(), ShouldEqual, template.HTML(srv.Snippet()))
+		})
+
+		Convey("in strict CSP mode Snippet should be the external script tag", func() {
+			srv.SetStrictCSP(true)
+			So(srv.Snippet(), ShouldEqual, srv.ScriptTag())
+		})
+	})
+}
+
+func TestCSPViolations(t *testing.T) {
+	Convey("Constructs a strict CSP blocks should be found", t, func() {
+		for src, want := range map[string]string{
+			`eval("1 + 1")`:                     "eval",
+			`new Function ("return 1")`:         "Function constructor",
+			`setTimeout('reload()', 10)`:        "string timer",
+			`document.writeln("<p>")`:           "document.write",
+			`<a href="JavaScript:void(0)">`:     "javascript URL",
+			`<body onload="start()">`:           "inline handler",
+			`<script>window.lr = true</script>`: "inline script",
+		} {
+			So(lrserver.CSPViolations(src), ShouldResemble, []string{want})
+		}
+	})
+
+	Convey("Constructs a strict CSP allows should not be", t, func() {
+		for _, src := range []string{
+			`setTimeout(reload, 10)`,
+			`evaluate(x)`,
+			`<script src="/livereload.js"></script>`,
+			`element.addEventListener("load", start)`,
+		} {
+			So(lrserver.CSPViolations(src), ShouldBeEmpty)
+		}
+	})
+}
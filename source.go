@@ -0,0 +1,223 @@
+package lrserver
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ChangeOp describes what happened to a changed file
+type ChangeOp uint8
+
+const (
+	ChangeCreate ChangeOp = iota + 1
+	ChangeWrite
+	ChangeRemove
+)
+
+// ChangeEvent describes a changed file
+type ChangeEvent struct {
+	// Path is the filesystem path of the changed file
+	Path string
+
+	// Root is the watched directory Path belongs to, if any.
+	// Paths are broadcast relative to it.
+	Root string
+
+	Op ChangeOp
+}
+
+// EventSource is a feed of file changes, such as a file watcher or a
+// build system's change notifications. Its channel is closed when the
+// source is closed, which may happen more than once, since servers close
+// their sources when they stop.
+type EventSource interface {
+	Events() <-chan ChangeEvent
+	Close() error
+}
+
+// AddEventSource reloads every path reported by src,
+// until src's event channel is closed, or the server is closed or shut
+// down, which closes src. Paths are broadcast relative to the web root
+// if set, otherwise relative to the event's root.
+func (s *Server) AddEventSource(src EventSource) {
+	s.spawn(func() {
+		events := src.Events()
+		for {
+			var event ChangeEvent
+			var ok bool
+			select {
+			case event, ok = <-events:
+			case <-s.halted:
+				src.Close()
+				return
+			}
+			if !ok {
+				return
+			}
+
+			// Let the web root derive the path if there is one
+			if s.WebRoot() != "" {
+				if abs, err := filepath.Abs(event.Path); err == nil {
+					s.Reload(abs)
+					continue
+				}
+			}
+			s.Reload(event.urlPath())
+		}
+	})
+}
+
+// urlPath gets the slash-separated path to broadcast for the event
+func (e ChangeEvent) urlPath() string {
+	if e.Root == "" {
+		return filepath.ToSlash(e.Path)
+	}
+	rel, err := filepath.Rel(e.Root, e.Path)
+	if err != nil {
+		return filepath.ToSlash(e.Path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Polling intervals
+const (
+	// DefaultPollInterval is the default interval for polling directories
+	DefaultPollInterval = time.Second
+
+	// MinPollInterval is the shortest interval directories are polled at,
+	// so a tiny one can't keep a core busy scanning
+	MinPollInterval = 10 * time.Millisecond
+)
+
+// pollInterval gets the interval polling actually uses for d: the
+// default if it's zero or less, and at least MinPollInterval
+func pollInterval(d time.Duration) time.Duration {
+	switch {
+	case d <= 0:
+		return DefaultPollInterval
+	case d < MinPollInterval:
+		return MinPollInterval
+	}
+	return d
+}
+
+// PollingSource is an EventSource that periodically scans a directory
+// tree for changed modification times and sizes. It works where
+// filesystem notifications don't, such as on shared volumes and
+// network filesystems.
+type PollingSource struct {
+	root      string
+	ignore    *ignoreRules
+	recursive bool
+	interval  time.Duration
+	clock     Clock
+	events    chan ChangeEvent
+	done      chan struct{}
+	once      sync.Once
+}
+
+// NewPollingSource starts polling root every interval, or every
+// DefaultPollInterval if it's zero or less. Intervals shorter than
+// MinPollInterval are raised to it.
+func NewPollingSource(root string, interval time.Duration) *PollingSource {
+	return newPollingSource(root, interval, SystemClock, nil, true)
+}
+
+func newPollingSource(root string, interval time.Duration, clock Clock, ignore *ignoreRules, recursive bool) *PollingSource {
+	p := &PollingSource{
+		root:      root,
+		ignore:    ignore,
+		recursive: recursive,
+		interval:  pollInterval(interval),
+		clock:     clock,
+		events:    make(chan ChangeEvent),
+		done:      make(chan struct{}),
+	}
+	go p.poll(p.scan())
+	return p
+}
+
+// Events gets the channel of changes
+func (p *PollingSource) Events() <-chan ChangeEvent {
+	return p.events
+}
+
+// Close stops polling
+func (p *PollingSource) Close() error {
+	p.once.Do(func() {
+		close(p.done)
+	})
+	return nil
+}
+
+func (p *PollingSource) poll(prev map[string]fileStamp) {
+	defer close(p.events)
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-p.clock.After(p.interval):
+		}
+
+		next := p.scan()
+		for path, stamp := range next {
+			old, ok := prev[path]
+			switch {
+			case !ok:
+				p.send(ChangeEvent{path, p.root, ChangeCreate})
+			case old != stamp:
+				p.send(ChangeEvent{path, p.root, ChangeWrite})
+			}
+		}
+		for path := range prev {
+			if _, ok := next[path]; !ok {
+				p.send(ChangeEvent{path, p.root, ChangeRemove})
+			}
+		}
+		prev = next
+	}
+}
+
+func (p *PollingSource) send(event ChangeEvent) {
+	select {
+	case p.events <- event:
+	case <-p.done:
+	}
+}
+
+// fileStamp identifies a version of a file
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// scan stamps every regular file under the root that isn't ignored,
+// or only those directly in it unless the source is recursive
+func (p *PollingSource) scan() map[string]fileStamp {
+	root, ignore := p.root, p.ignore
+	stamps := make(map[string]fileStamp)
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !p.recursive && info.IsDir() && path != root {
+			return filepath.SkipDir
+		}
+		if ignore != nil && path != root {
+			if rel, err := filepath.Rel(root, path); err == nil && ignore.ignored(rel, info.IsDir()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if info.Mode().IsRegular() {
+			stamps[path] = fileStamp{info.ModTime(), info.Size()}
+		}
+		return nil
+	})
+	return stamps
+}
@@ -0,0 +1,281 @@
+package lrserver_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// chanSource is an EventSource fed by the test
+type chanSource struct {
+	events chan lrserver.ChangeEvent
+	once   sync.Once
+}
+
+func newChanSource() *chanSource {
+	return &chanSource{events: make(chan lrserver.ChangeEvent)}
+}
+
+func (c *chanSource) Events() <-chan lrserver.ChangeEvent { return c.events }
+func (c *chanSource) Close() error {
+	c.once.Do(func() { close(c.events) })
+	return nil
+}
+
+func TestEventSources(t *testing.T) {
+	Convey("Given a running server and a connected websocket", t, func() {
+		srv := startServer(t)
+		conn := connect(t, srv)
+		defer conn.Close()
+
+		Convey("a custom event source should drive reloads", func() {
+			src := newChanSource()
+			srv.AddEventSource(src)
+			defer src.Close()
+
+			src.events <- lrserver.ChangeEvent{
+				Path: filepath.Join("/srv", "www", "css", "main.css"),
+				Root: filepath.Join("/srv", "www"),
+				Op:   lrserver.ChangeWrite,
+			}
+
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "css/main.css")
+		})
+
+		Convey("and a watched directory", func() {
+			dir, err := ioutil.TempDir("", "lrserver")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			Convey("the polling source should report changes", func() {
+				src := lrserver.NewPollingSource(dir, 10*time.Millisecond)
+				defer src.Close()
+
+				err := ioutil.WriteFile(filepath.Join(dir, "index.html"), nil, 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				select {
+				case event := <-src.Events():
+					So(event.Op, ShouldEqual, lrserver.ChangeCreate)
+					So(event.Path, ShouldEqual, filepath.Join(dir, "index.html"))
+				case <-time.After(time.Second):
+					t.Fatal("no event received")
+				}
+			})
+
+			Convey("exhausted watch limits should fall back to polling", func() {
+				restore := lrserver.SetNewFSNotifySource(func(string) (*lrserver.FSNotifySource, error) {
+					return nil, fmt.Errorf("watching: %w", syscall.ENOSPC)
+				})
+				defer restore()
+
+				statusBuf, errorBuf := new(syncBuffer), new(syncBuffer)
+				srv.SetStatusLog(log.New(statusBuf, "", 0))
+				srv.SetErrorLog(log.New(errorBuf, "", 0))
+				srv.SetPollInterval(10 * time.Millisecond)
+
+				err := srv.WatchDir(dir)
+				So(err, ShouldBeNil)
+				So(errorBuf.String(), ShouldContainSubstring, "fs.inotify.max_user_watches")
+				So(statusBuf.String(), ShouldContainSubstring, "falling back to polling")
+
+				err = ioutil.WriteFile(filepath.Join(dir, "index.html"), nil, 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sr, err := readReload(conn)
+				So(err, ShouldBeNil)
+				So(sr.Path, ShouldEqual, "index.html")
+			})
+
+			Convey("missing directories should be refused", func() {
+				So(srv.WatchDir(filepath.Join(dir, "missing")), ShouldNotBeNil)
+				srv.SetPolling(true)
+				So(srv.WatchDir(filepath.Join(dir, "missing")), ShouldNotBeNil)
+			})
+
+			Convey("other watch errors should fall back to polling", func() {
+				restore := lrserver.SetNewFSNotifySource(func(string) (*lrserver.FSNotifySource, error) {
+					return nil, fmt.Errorf("watching: %w", syscall.EINVAL)
+				})
+				defer restore()
+
+				statusBuf := new(syncBuffer)
+				srv.SetStatusLog(log.New(statusBuf, "", 0))
+				srv.SetPollInterval(10 * time.Millisecond)
+				So(srv.WatchDir(dir), ShouldBeNil)
+				So(statusBuf.String(), ShouldContainSubstring, "falling back to polling")
+			})
+
+			Convey("polling should replace notifications when set", func() {
+				restore := lrserver.SetNewFSNotifySource(func(string) (*lrserver.FSNotifySource, error) {
+					t.Error("notifications used while polling")
+					return nil, syscall.EINVAL
+				})
+				defer restore()
+
+				srv.SetPolling(true)
+				srv.SetPollInterval(10 * time.Millisecond)
+				So(srv.Polling(), ShouldBeTrue)
+				So(srv.WatchDir(dir), ShouldBeNil)
+
+				err := ioutil.WriteFile(filepath.Join(dir, "index.html"), nil, 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sr, err := readReload(conn)
+				So(err, ShouldBeNil)
+				So(sr.Path, ShouldEqual, "index.html")
+			})
+
+			Convey("the fsnotify source should report changes in new subdirectories", func() {
+				src, err := lrserver.NewFSNotifySource(dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer src.Close()
+				srv.AddEventSource(src)
+
+				err = os.Mkdir(filepath.Join(dir, "js"), 0755)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sr, err := readReload(conn)
+				So(err, ShouldBeNil)
+				So(sr.Path, ShouldEqual, "js")
+
+				time.Sleep(10 * time.Millisecond)
+				err = ioutil.WriteFile(filepath.Join(dir, "js", "app.js"), nil, 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sr, err = readReload(conn)
+				So(err, ShouldBeNil)
+				So(sr.Path, ShouldEqual, "js/app.js")
+			})
+		})
+	})
+
+	Convey("Event sources should be tracked, and closed when the server shuts down", t, func() {
+		srv := startServer(t)
+		src := newChanSource()
+		srv.AddEventSource(src)
+		So(srv.ActiveGoroutines(), ShouldEqual, 1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		So(srv.Shutdown(ctx), ShouldBeNil)
+		So(srv.ActiveGoroutines(), ShouldEqual, 0)
+		_, ok := <-src.Events()
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("Poll intervals should be kept from busy-spinning", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		srv.SetPollInterval(0)
+		So(srv.PollInterval(), ShouldEqual, lrserver.DefaultPollInterval)
+		srv.SetPollInterval(-time.Second)
+		So(srv.PollInterval(), ShouldEqual, lrserver.DefaultPollInterval)
+		srv.SetPollInterval(time.Nanosecond)
+		So(srv.PollInterval(), ShouldEqual, lrserver.MinPollInterval)
+	})
+}
+
+// fakeWatchman answers the commands a WatchmanSource sends,
+// then pushes a fresh instance result followed by a change
+func fakeWatchman(t *testing.T, l net.Listener, root string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+	for _, resp := range []map[string]interface{}{
+		{"watch": root, "version": "2023.01.01.00"},
+		{"subscribe": "lrserver"},
+	} {
+		var cmd []interface{}
+		if err := dec.Decode(&cmd); err != nil {
+			t.Error(err)
+			return
+		}
+		enc.Encode(map[string]interface{}{"log": "noise", "unilateral": true})
+		enc.Encode(resp)
+	}
+
+	enc.Encode(map[string]interface{}{
+		"subscription":      "lrserver",
+		"is_fresh_instance": true,
+		"files":             []map[string]interface{}{{"name": "old.css", "exists": true}},
+	})
+	enc.Encode(map[string]interface{}{
+		"subscription": "lrserver",
+		"files": []map[string]interface{}{
+			{"name": "css/main.css", "exists": true, "new": false},
+			{"name": "gone.js", "exists": false},
+		},
+	})
+
+	// Hold the connection open until the source closes it
+	dec.Decode(new(interface{}))
+}
+
+func TestWatchmanSource(t *testing.T) {
+	Convey("Given a Watchman service", t, func() {
+		dir, err := ioutil.TempDir("", "lrserver")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		sock := filepath.Join(dir, "sock")
+		l, err := net.Listen("unix", sock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		go fakeWatchman(t, l, dir)
+
+		Convey("the watchman source should report changes after the fresh instance", func() {
+			src, err := lrserver.NewWatchmanSourceSocket(sock, dir)
+			So(err, ShouldBeNil)
+			defer src.Close()
+
+			var events []lrserver.ChangeEvent
+			for len(events) < 2 {
+				select {
+				case event := <-src.Events():
+					events = append(events, event)
+				case <-time.After(time.Second):
+					t.Fatal("no event received")
+				}
+			}
+
+			So(events[0].Path, ShouldEqual, filepath.Join(dir, "css", "main.css"))
+			So(events[0].Op, ShouldEqual, lrserver.ChangeWrite)
+			So(events[1].Path, ShouldEqual, filepath.Join(dir, "gone.js"))
+			So(events[1].Op, ShouldEqual, lrserver.ChangeRemove)
+		})
+	})
+}
@@ -0,0 +1,122 @@
+package lrserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// sseProtocol is the protocol SSE clients are served, the newest
+// official one the bundled livereload.js speaks
+const sseProtocol = officialProtocolPrefix + "7"
+
+// sseStream writes messages to an SSE client as events
+type sseStream struct {
+	rw      http.ResponseWriter
+	flusher http.Flusher
+}
+
+// write sends data as an event, by deadline where the response writer
+// supports one
+func (st *sseStream) write(data []byte, deadline time.Time) error {
+	http.NewResponseController(st.rw).SetWriteDeadline(deadline)
+	_, err := fmt.Fprintf(st.rw, "data: %s\n\n", data)
+	if err != nil {
+		return err
+	}
+	st.flusher.Flush()
+	return nil
+}
+
+// sseHandler streams the same reload and alert commands as the websocket
+// endpoint as Server-Sent Events, for browsers behind proxies that block
+// websockets. The client can't talk back, so it passes the page's URL
+// and the epoch it last saw in the query string.
+func sseHandler(s *Server) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if !s.checkHost(rw, req) {
+			return
+		}
+		if req.Method != http.MethodGet {
+			rw.Header().Set("Allow", http.MethodGet)
+			http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if !s.originAllowed(req) {
+			http.Error(rw, "pages from "+req.Header.Get("Origin")+" may not connect", http.StatusForbidden)
+			return
+		}
+		if !s.authorized(req) {
+			s.logError("refusing event stream from " + s.clientAddr(req) + ": bad token")
+			http.Error(rw, "missing or wrong auth token", http.StatusUnauthorized)
+			return
+		}
+		flusher, ok := rw.(http.Flusher)
+		if !ok {
+			http.Error(rw, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		if atomic.LoadInt32(&s.shuttingDown) == 1 {
+			http.Error(rw, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		s.goroutines.add()
+		defer s.goroutines.done()
+
+		ctx, cancel := context.WithCancel(s.ctx)
+		defer context.AfterFunc(req.Context(), cancel)()
+		c := &conn{
+			id:         nextConnID(),
+			stream:     &sseStream{rw, flusher},
+			remoteAddr: s.clientAddr(req),
+			userAgent:  req.UserAgent(),
+
+			server:      s,
+			protocol:    sseProtocol,
+			connectedAt: s.now(),
+
+			queue:  newSendQueue(),
+			ctx:    ctx,
+			cancel: cancel,
+		}
+		pageURL := req.URL.Query().Get("url")
+		if pageURL == "" {
+			pageURL = req.Referer()
+		}
+		c.url.Store(pageURL)
+		if !s.admit(c) {
+			cancel()
+			rejectStream(rw)
+			return
+		}
+
+		// Say hello, which the client takes as the handshake
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.Header().Set("Cache-Control", "no-cache")
+		rw.Header().Set("X-Accel-Buffering", "no")
+		rw.WriteHeader(http.StatusOK)
+		hello, err := json.Marshal(makeServerHello(s.Name(), []string{sseProtocol}, s.Epoch()))
+		if err == nil {
+			err = c.write(hello)
+		}
+		if err != nil {
+			s.logError(err)
+			cancel()
+			s.conns.remove(c)
+			s.checkIdle()
+			return
+		}
+
+		c.connected()
+		c.checkEpoch(req.URL.Query().Get("epoch"))
+
+		// Stream until the client goes away, or the server stops
+		c.transmit()
+		c.close(websocket.CloseGoingAway, nil)
+	}
+}
@@ -0,0 +1,94 @@
+package lrserver_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// readEvent reads the data of the next event from an SSE stream
+func readEvent(r *bufio.Reader, v interface{}) error {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if data := strings.TrimPrefix(line, "data: "); data != line {
+			return json.Unmarshal([]byte(data), v)
+		}
+	}
+}
+
+func TestSSE(t *testing.T) {
+	Convey("Given a running server and an SSE client", t, func() {
+		srv := startServer(t)
+		resp, err := http.Get(fmt.Sprintf("http%s:%d/livereload/sse?url=http%%3A%%2F%%2Fexample.com%%2Fpage", localhost, srv.Port()))
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		So(resp.Header.Get("Content-Type"), ShouldEqual, "text/event-stream")
+		events := bufio.NewReader(resp.Body)
+
+		Convey("the stream should open with a hello", func() {
+			hello := new(serverHello)
+			So(readEvent(events, hello), ShouldBeNil)
+			So(hello.Command, ShouldEqual, "hello")
+			So(hello.Protocols, ShouldResemble, []string{"http://livereload.com/protocols/official-7"})
+
+			Convey("and the client should count as connected", func() {
+				conns := srv.Connections()
+				So(conns, ShouldHaveLength, 1)
+				So(conns[0].URL, ShouldEqual, "http://example.com/page")
+			})
+
+			Convey("and reloads should be streamed", func() {
+				srv.Reload("css/main.css")
+				sr := new(serverReload)
+				So(readEvent(events, sr), ShouldBeNil)
+				So(sr.Command, ShouldEqual, "reload")
+				So(sr.Path, ShouldEqual, "css/main.css")
+			})
+
+			Convey("and a shutdown should end the stream", func() {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+				So(srv.Shutdown(ctx), ShouldBeNil)
+				_, err := io.ReadAll(events)
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Reset(func() { srv.Close() })
+	})
+
+	Convey("Given a running server", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+
+		Convey("the client script should fall back to SSE", func() {
+			resp, err := http.Get(fmt.Sprintf("http%s:%d/livereload.js", localhost, srv.Port()))
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			So(string(body), ShouldContainSubstring, "/livereload/sse")
+			So(string(body), ShouldContainSubstring, "new EventSource(")
+		})
+
+		Convey("pages from other origins should be refused", func() {
+			req, _ := http.NewRequest("GET", fmt.Sprintf("http%s:%d/livereload/sse", localhost, srv.Port()), nil)
+			req.Header.Set("Origin", "http://evil.example")
+			resp, err := http.DefaultClient.Do(req)
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+		})
+	})
+}
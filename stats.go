@@ -0,0 +1,95 @@
+package lrserver
+
+import (
+	"expvar"
+	"fmt"
+	"sync/atomic"
+)
+
+// DefaultExpvarPrefix is the expvar name stats are published under by default
+const DefaultExpvarPrefix = "lrserver"
+
+// stats counts what the server has done since it was created
+type stats struct {
+	connections    int64
+	disconnections int64
+	reloads        int64
+	alerts         int64
+	delivered      int64
+	errors         int64
+	evictions      int64
+	dropped        int64
+	reloadsSent    int64
+	alertsSent     int64
+	upgradeFails   int64
+	rejected       int64
+}
+
+// count records an event
+func (st *stats) count(e Event) {
+	switch e.Type {
+	case EventConnected:
+		atomic.AddInt64(&st.connections, 1)
+	case EventDisconnected:
+		atomic.AddInt64(&st.disconnections, 1)
+	case EventReload:
+		atomic.AddInt64(&st.reloads, 1)
+	case EventAlert:
+		atomic.AddInt64(&st.alerts, 1)
+	case EventDelivered:
+		atomic.AddInt64(&st.delivered, 1)
+		switch e.Command {
+		case "reload":
+			atomic.AddInt64(&st.reloadsSent, 1)
+		case "alert":
+			atomic.AddInt64(&st.alertsSent, 1)
+		}
+	case EventError:
+		atomic.AddInt64(&st.errors, 1)
+	}
+}
+
+// vars gets the counters by name, along with the current client count.
+// Connections are counted once the client completes the handshake.
+func (s *Server) vars() map[string]int64 {
+	return map[string]int64{
+		"clients":         int64(s.conns.len()),
+		"connections":     atomic.LoadInt64(&s.stats.connections),
+		"disconnections":  atomic.LoadInt64(&s.stats.disconnections),
+		"reloads":         atomic.LoadInt64(&s.stats.reloads),
+		"alerts":          atomic.LoadInt64(&s.stats.alerts),
+		"delivered":       atomic.LoadInt64(&s.stats.delivered),
+		"errors":          atomic.LoadInt64(&s.stats.errors),
+		"evictions":       atomic.LoadInt64(&s.stats.evictions),
+		"dropped":         atomic.LoadInt64(&s.stats.dropped),
+		"reloadsSent":     atomic.LoadInt64(&s.stats.reloadsSent),
+		"alertsSent":      atomic.LoadInt64(&s.stats.alertsSent),
+		"upgradeFailures": atomic.LoadInt64(&s.stats.upgradeFails),
+		"rejected":        atomic.LoadInt64(&s.stats.rejected),
+	}
+}
+
+// PublishExpvar publishes the server's counters through expvar as a map
+// named prefix, or DefaultExpvarPrefix if prefix is empty, so they show
+// up at /debug/vars. Each name can only be published once per process.
+func (s *Server) PublishExpvar(prefix string) error {
+	if prefix == "" {
+		prefix = DefaultExpvarPrefix
+	}
+	if expvar.Get(prefix) != nil {
+		return fmt.Errorf("lrserver: expvar %q is already published", prefix)
+	}
+	expvar.Publish(prefix, expvar.Func(func() interface{} {
+		return s.vars()
+	}))
+	return nil
+}
+
+// dropped counts n messages discarded for the slow client at remote
+func (s *Server) dropped(remote string, n int) {
+	atomic.AddInt64(&s.stats.dropped, int64(n))
+	for i := 0; i < n; i++ {
+		s.increment("dropped")
+	}
+	s.emit(Event{Type: EventDropped, Remote: remote, Count: n})
+}
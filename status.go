@@ -0,0 +1,137 @@
+package lrserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Status is a snapshot of the server's state, for tools wrapping it
+type Status struct {
+	Name          string        `json:"name"`
+	Addr          string        `json:"addr"`
+	Listening     bool          `json:"listening"`
+	TLS           bool          `json:"tls"`
+	Uptime        time.Duration `json:"uptime"`
+	Clients       int           `json:"clients"`
+	Rejected      int64         `json:"rejected"`
+	LastBroadcast time.Time     `json:"lastBroadcast"`
+	LastReload    time.Time     `json:"lastReload"`
+	LastPath      string        `json:"lastPath,omitempty"`
+
+	// Protocols counts the connected clients by the protocol they speak
+	Protocols map[string]int `json:"protocols"`
+
+	Config StatusConfig `json:"config"`
+}
+
+// StatusConfig summarizes the server's settings
+type StatusConfig struct {
+	LiveCSS           bool          `json:"liveCSS"`
+	StrictCSP         bool          `json:"strictCSP"`
+	JSDisabled        bool          `json:"jsDisabled"`
+	AlertsDisabled    bool          `json:"alertsDisabled"`
+	ReadOnly          bool          `json:"readOnly"`
+	PublicURL         string        `json:"publicURL,omitempty"`
+	WebRoot           string        `json:"webRoot,omitempty"`
+	WindowsPaths      bool          `json:"windowsPaths"`
+	PollInterval      time.Duration `json:"pollInterval"`
+	MaxQueuedMessages int           `json:"maxQueuedMessages"`
+	MaxQueuedBytes    int           `json:"maxQueuedBytes"`
+	MaxConnections    int           `json:"maxConnections,omitempty"`
+}
+
+// reloadInfo describes the last reload
+type reloadInfo struct {
+	path string
+	at   time.Time
+}
+
+// listenerInfo describes the listener the server is serving on
+type listenerInfo struct {
+	tls   bool
+	since time.Time
+}
+
+// Status gets a snapshot of the server's state
+func (s *Server) Status() Status {
+	cfg := s.settings()
+	st := Status{
+		Name:      s.name,
+		Addr:      s.Addr(),
+		Clients:   s.conns.len(),
+		Rejected:  atomic.LoadInt64(&s.stats.rejected),
+		Protocols: make(map[string]int),
+		Config: StatusConfig{
+			LiveCSS:           cfg.liveCSS,
+			StrictCSP:         cfg.strictCSP,
+			JSDisabled:        cfg.jsDisabled,
+			AlertsDisabled:    cfg.alertsDisabled,
+			ReadOnly:          cfg.readOnly,
+			PublicURL:         s.PublicURL(),
+			WebRoot:           cfg.webRoot,
+			WindowsPaths:      cfg.windowsPaths,
+			PollInterval:      cfg.pollInterval,
+			MaxQueuedMessages: cfg.maxQueuedMessages,
+			MaxQueuedBytes:    cfg.maxQueuedBytes,
+			MaxConnections:    cfg.maxConnections,
+		},
+	}
+	if l, ok := s.listener.Load().(*listenerInfo); ok && l != nil {
+		st.Listening = true
+		st.TLS = l.tls
+		st.Uptime = s.now().Sub(l.since)
+	}
+	if t := atomic.LoadInt64(&s.lastBroadcast); t != 0 {
+		st.LastBroadcast = time.Unix(0, t)
+	}
+	if r, ok := s.lastReload.Load().(*reloadInfo); ok {
+		st.LastReload, st.LastPath = r.at, r.path
+	}
+	s.conns.each(func(c *conn) {
+		if c.shookHands() {
+			st.Protocols[c.protocol]++
+		}
+	})
+	return st
+}
+
+// statusHandler serves the server's Status as JSON, so tools can cheaply
+// detect and inspect a running server
+func statusHandler(s *Server) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if !s.checkHost(rw, req) {
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Cache-Control", "no-store")
+		err := json.NewEncoder(rw).Encode(s.Status())
+		if err != nil {
+			s.logError(err)
+		}
+	}
+}
+
+// healthHandler answers liveness probes for as long as the server is
+// serving at all, unlike readyHandler
+func healthHandler(s *Server) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		_, err := rw.Write([]byte("ok\n"))
+		if err != nil {
+			s.logError(err)
+		}
+	}
+}
+
+// servingTLS reports whether the server is serving HTTPS itself
+func (s *Server) servingTLS() bool {
+	l, ok := s.listener.Load().(*listenerInfo)
+	return ok && l != nil && l.tls
+}
+
+// setListening records whether the server is serving, and how
+func (s *Server) setListening(l *listenerInfo) {
+	s.listener.Store(l)
+	s.checkIdle()
+}
@@ -0,0 +1,47 @@
+package lrserver_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStatusEndpoints(t *testing.T) {
+	Convey("Given a running server with a client", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		conn := connect(t, srv)
+		defer conn.Close()
+		base := fmt.Sprintf("http%s:%d/livereload", localhost, srv.Port())
+
+		Convey("healthz should report it alive", func() {
+			So(getBody(t, base+"/healthz"), ShouldEqual, "ok\n")
+		})
+
+		Convey("status should describe it as JSON", func() {
+			srv.Reload("css/main.css")
+			_, err := readReload(conn)
+			So(err, ShouldBeNil)
+
+			resp, err := http.Get(base + "/status")
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			So(resp.Header.Get("Content-Type"), ShouldEqual, "application/json")
+
+			var st lrserver.Status
+			So(json.NewDecoder(resp.Body).Decode(&st), ShouldBeNil)
+			So(st.Addr, ShouldEqual, srv.Addr())
+			So(st.Listening, ShouldBeTrue)
+			So(st.Uptime, ShouldBeGreaterThan, 0)
+			So(st.Clients, ShouldEqual, 1)
+			So(st.LastPath, ShouldEqual, "css/main.css")
+			So(st.LastReload, ShouldHappenWithin, time.Second, time.Now())
+			So(st.Protocols, ShouldResemble, map[string]int{"http://livereload.com/protocols/official-8": 1})
+		})
+	})
+}
@@ -0,0 +1,141 @@
+package lrserver
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SourceMapper maps a changed source file to the built
+// files the browser loads in its place
+type SourceMapper interface {
+	// MapSource gets the filesystem paths built from the source file at
+	// path, or false if path isn't one of its sources
+	MapSource(path string) ([]string, bool)
+}
+
+// DefaultStyleExts are the stylesheet source extensions StyleMapper
+// handles by default
+var DefaultStyleExts = []string{".scss", ".sass", ".less", ".styl", ".pcss", ".postcss"}
+
+// StyleMapper is a SourceMapper for stylesheets compiled by Sass, Less,
+// Stylus or PostCSS. A source maps to the file of the same relative path
+// and name in OutputDir, with the output extension: scss/main.scss
+// becomes css/main.css. Partials, whose names start with an underscore,
+// map to every compiled stylesheet, unless a DepGraph has already traced
+// them to the sources importing them.
+type StyleMapper struct {
+	// SourceDir contains the stylesheet sources
+	SourceDir string
+
+	// OutputDir receives the compiled stylesheets
+	OutputDir string
+
+	// Exts are the source extensions, DefaultStyleExts if empty
+	Exts []string
+
+	// OutputExt is the compiled extension, ".css" if empty
+	OutputExt string
+}
+
+// MapSource implements SourceMapper
+func (m *StyleMapper) MapSource(path string) ([]string, bool) {
+	srcDir, err := filepath.Abs(m.SourceDir)
+	if err != nil {
+		return nil, false
+	}
+	path, err = filepath.Abs(path)
+	if err != nil || !m.isSource(path) {
+		return nil, false
+	}
+	rel, err := filepath.Rel(srcDir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil, false
+	}
+
+	if !isPartial(path) {
+		return []string{m.output(rel)}, true
+	}
+
+	// Partials affect every compiled stylesheet
+	var outputs []string
+	filepath.Walk(srcDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() || !m.isSource(p) || isPartial(p) {
+			return nil
+		}
+		if rel, err := filepath.Rel(srcDir, p); err == nil {
+			outputs = append(outputs, m.output(rel))
+		}
+		return nil
+	})
+	sort.Strings(outputs)
+	return outputs, true
+}
+
+func (m *StyleMapper) isSource(path string) bool {
+	exts := m.Exts
+	if len(exts) == 0 {
+		exts = DefaultStyleExts
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range exts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// output gets the compiled path for the source at rel in SourceDir
+func (m *StyleMapper) output(rel string) string {
+	outExt := m.OutputExt
+	if outExt == "" {
+		outExt = ".css"
+	}
+	outDir, err := filepath.Abs(m.OutputDir)
+	if err != nil {
+		outDir = m.OutputDir
+	}
+	return filepath.Join(outDir, strings.TrimSuffix(rel, filepath.Ext(rel))+outExt)
+}
+
+func isPartial(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), "_")
+}
+
+// AddSourceMapper adds a mapper from changed source files to the built
+// files reloaded in their place. Mappers are consulted in the order they
+// were added, after the dependency graph if there is one.
+func (s *Server) AddSourceMapper(m SourceMapper) {
+	s.update(func(cfg *settings) {
+		cfg.sourceMappers = append(cfg.sourceMappers[:len(cfg.sourceMappers):len(cfg.sourceMappers)], m)
+	})
+}
+
+// mapSources replaces paths by the built files mapped to them
+func (s *Server) mapSources(paths []string) []string {
+	mappers := s.settings().sourceMappers
+	if len(mappers) == 0 {
+		return paths
+	}
+
+	var mapped []string
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		outputs := []string{p}
+		for _, m := range mappers {
+			if o, ok := m.MapSource(p); ok {
+				outputs = o
+				break
+			}
+		}
+		for _, o := range outputs {
+			if !seen[o] {
+				seen[o] = true
+				mapped = append(mapped, o)
+			}
+		}
+	}
+	return mapped
+}
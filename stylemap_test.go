@@ -0,0 +1,81 @@
+package lrserver_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStyleMapper(t *testing.T) {
+	Convey("Given stylesheet sources and a style mapper", t, func() {
+		dir, err := ioutil.TempDir("", "lrserver")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		writeFiles(t, dir, map[string]string{
+			"scss/main.scss":          `@use "buttons";`,
+			"scss/print.scss":         ``,
+			"scss/_buttons.scss":      ``,
+			"scss/pages/about.scss":   ``,
+			"scss/pages/_shared.scss": ``,
+		})
+		p := func(name string) string {
+			return filepath.Join(dir, filepath.FromSlash(name))
+		}
+
+		m := &lrserver.StyleMapper{
+			SourceDir: p("scss"),
+			OutputDir: p("public/css"),
+		}
+
+		Convey("sources should map to their compiled stylesheet", func() {
+			out, ok := m.MapSource(p("scss/pages/about.scss"))
+			So(ok, ShouldBeTrue)
+			So(out, ShouldResemble, []string{p("public/css/pages/about.css")})
+		})
+
+		Convey("partials should map to every compiled stylesheet", func() {
+			out, ok := m.MapSource(p("scss/_buttons.scss"))
+			So(ok, ShouldBeTrue)
+			So(out, ShouldResemble, []string{
+				p("public/css/main.css"),
+				p("public/css/pages/about.css"),
+				p("public/css/print.css"),
+			})
+		})
+
+		Convey("other files should not be mapped", func() {
+			_, ok := m.MapSource(p("public/index.html"))
+			So(ok, ShouldBeFalse)
+			_, ok = m.MapSource(p("other/main.scss"))
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("a server using it should broadcast the compiled stylesheet", func() {
+			srv := startServer(t)
+			srv.SetWebRoot(p("public"))
+			conn := connect(t, srv)
+			defer conn.Close()
+
+			g := lrserver.NewDepGraph()
+			err := g.Scan(p("scss"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv.SetDepGraph(g)
+			srv.AddSourceMapper(m)
+
+			srv.Reload(p("scss/_buttons.scss"))
+
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "/css/main.css")
+		})
+	})
+}
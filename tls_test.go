@@ -0,0 +1,131 @@
+package lrserver_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// writeCert writes a self-signed certificate for 127.0.0.1 into dir
+func writeCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{Organization: []string{"lrserver"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestTLS(t *testing.T) {
+	Convey("Given a server serving TLS", t, func() {
+		dir, err := ioutil.TempDir("", "lrserver")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		certFile, keyFile := writeCert(t, dir)
+
+		srv, err := lrserver.New(lrserver.DefaultName, "127.0.0.1", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.SetStatusLog(nil)
+		srv.SetErrorLog(nil)
+		go srv.ListenAndServeTLS(certFile, keyFile)
+		time.Sleep(10 * time.Millisecond)
+		defer srv.Close()
+
+		tlsConfig := &tls.Config{InsecureSkipVerify: true}
+
+		Convey("the client should connect securely", func() {
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+			resp, err := client.Get(fmt.Sprintf("https://127.0.0.1:%d/livereload.js", srv.Port()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			So(string(body), ShouldContainSubstring, "this.https = true ||")
+			So(srv.ScriptURL(), ShouldEqual, fmt.Sprintf("https://127.0.0.1:%d/livereload.js", srv.Port()))
+			So(srv.Status().TLS, ShouldBeTrue)
+		})
+
+		Convey("servers created WithTLS should serve HTTPS too", func() {
+			srv, err := lrserver.NewServer(
+				lrserver.WithHost("127.0.0.1"),
+				lrserver.WithPort(0),
+				lrserver.WithLogger(nil),
+				lrserver.WithTLS(certFile, keyFile),
+			)
+			So(err, ShouldBeNil)
+			go srv.ListenAndServe()
+			time.Sleep(10 * time.Millisecond)
+			defer srv.Close()
+
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+			resp, err := client.Get(fmt.Sprintf("https://127.0.0.1:%d/livereload.js", srv.Port()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			So(srv.Status().TLS, ShouldBeTrue)
+		})
+
+		Convey("websockets should connect with wss://", func() {
+			dialer := &websocket.Dialer{TLSClientConfig: tlsConfig}
+			conn, _, err := dialer.Dial(fmt.Sprintf("wss://127.0.0.1:%d/livereload", srv.Port()), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			So(conn.ReadJSON(new(serverHello)), ShouldBeNil)
+		})
+	})
+}
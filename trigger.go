@@ -0,0 +1,86 @@
+package lrserver
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// TriggerPath is where EnableTrigger serves the HTTP control API
+const TriggerPath = "/livereload/trigger"
+
+// TriggerRequest is the JSON body of a request to the HTTP control API.
+// It reloads Path and Paths, and sends Message as an alert:
+//
+//	{"path": "css/main.css"}
+//	{"paths": ["index.html", "js/app.js"]}
+//	{"message": "Build failed"}
+type TriggerRequest struct {
+	Path    string   `json:"path,omitempty"`
+	Paths   []string `json:"paths,omitempty"`
+	Message string   `json:"message,omitempty"`
+}
+
+// EnableTrigger serves an HTTP control API at TriggerPath, under any
+// path prefix, so build systems and scripts can trigger reloads without
+// linking this package:
+//
+//	curl -X POST -H "Authorization: Bearer $TOKEN" \
+//		-d '{"path": "css/main.css"}' http://localhost:35729/livereload/trigger
+//
+// Requests must carry token as a bearer token. An empty token lets any
+// client that can reach the server trigger reloads.
+func (s *Server) EnableTrigger(token string) error {
+	return s.alias(s.prefix+TriggerPath, triggerHandler(s, token))
+}
+
+func triggerHandler(s *Server, token string) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if !s.checkHost(rw, req) {
+			return
+		}
+		if req.Method != http.MethodPost {
+			rw.Header().Set("Allow", http.MethodPost)
+			http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if token != "" && !bearerTokenMatches(req, token) {
+			s.logError("refusing trigger from " + s.clientAddr(req) + ": bad token")
+			rw.Header().Set("WWW-Authenticate", `Bearer realm="lrserver"`)
+			http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		tr := new(TriggerRequest)
+		err := json.NewDecoder(req.Body).Decode(tr)
+		if err != nil {
+			http.Error(rw, "invalid trigger: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if tr.Path == "" && len(tr.Paths) == 0 && tr.Message == "" {
+			http.Error(rw, "invalid trigger: no path or message", http.StatusBadRequest)
+			return
+		}
+
+		if tr.Path != "" || len(tr.Paths) > 0 {
+			s.runStdinCommand(&StdinCommand{Cmd: "reload", Path: tr.Path, Paths: tr.Paths})
+		}
+		if tr.Message != "" {
+			s.runStdinCommand(&StdinCommand{Cmd: "alert", Message: tr.Message})
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// bearerTokenMatches reports whether req's Authorization header holds
+// token as a bearer token
+func bearerTokenMatches(req *http.Request, token string) bool {
+	const prefix = "bearer "
+	auth := req.Header.Get("Authorization")
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return false
+	}
+	given := strings.TrimSpace(auth[len(prefix):])
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
@@ -0,0 +1,57 @@
+package lrserver_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTrigger(t *testing.T) {
+	Convey("Given a running server with the trigger API and a connected websocket", t, func() {
+		srv := startServer(t)
+		So(srv.EnableTrigger("s3cret"), ShouldBeNil)
+		conn := connect(t, srv)
+		defer conn.Close()
+
+		trigger := func(method, token, body string) int {
+			req, err := http.NewRequest(method, fmt.Sprintf("http%s:%d/livereload/trigger", localhost, srv.Port()), strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+
+		Convey("an authorized trigger should reload", func() {
+			So(trigger("POST", "s3cret", `{"path": "css/main.css"}`), ShouldEqual, http.StatusNoContent)
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "css/main.css")
+		})
+
+		Convey("unauthorized triggers should be refused", func() {
+			So(trigger("POST", "", `{"path": "css/main.css"}`), ShouldEqual, http.StatusUnauthorized)
+			So(trigger("POST", "guess", `{"path": "css/main.css"}`), ShouldEqual, http.StatusUnauthorized)
+		})
+
+		Convey("invalid triggers should be rejected", func() {
+			So(trigger("GET", "s3cret", ""), ShouldEqual, http.StatusMethodNotAllowed)
+			So(trigger("POST", "s3cret", "not json"), ShouldEqual, http.StatusBadRequest)
+			So(trigger("POST", "s3cret", "{}"), ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("the API should only be enabled once", func() {
+			So(srv.EnableTrigger("other"), ShouldNotBeNil)
+		})
+	})
+}
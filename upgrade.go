@@ -0,0 +1,152 @@
+package lrserver
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// UpgraderConfig tunes how websocket connections are set up. Zero values
+// leave Gorilla's defaults.
+type UpgraderConfig struct {
+	// ReadBufferSize and WriteBufferSize size each connection's I/O
+	// buffers, in bytes
+	ReadBufferSize  int
+	WriteBufferSize int
+
+	// HandshakeTimeout bounds the upgrade handshake
+	HandshakeTimeout time.Duration
+
+	// EnableCompression negotiates permessage-deflate with clients that
+	// support it
+	EnableCompression bool
+
+	// Subprotocols lists the subprotocols accepted, in order of
+	// preference, for clients that ask for one
+	Subprotocols []string
+}
+
+// UpgraderConfig gets the websocket upgrade settings
+func (s *Server) UpgraderConfig() UpgraderConfig {
+	return s.settings().upgrader
+}
+
+// SetUpgraderConfig sets the websocket upgrade settings, which apply to
+// connections made from then on
+func (s *Server) SetUpgraderConfig(c UpgraderConfig) {
+	c.Subprotocols = append([]string(nil), c.Subprotocols...)
+	s.update(func(cfg *settings) { cfg.upgrader = c })
+}
+
+// upgrader gets a websocket.Upgrader using the current upgrade settings.
+// It doesn't check the origin, and leaves failures to writeUpgradeFailure.
+func (s *Server) upgrader() *websocket.Upgrader {
+	c := s.settings().upgrader
+	return &websocket.Upgrader{
+		ReadBufferSize:    c.ReadBufferSize,
+		WriteBufferSize:   c.WriteBufferSize,
+		HandshakeTimeout:  c.HandshakeTimeout,
+		EnableCompression: c.EnableCompression,
+		Subprotocols:      c.Subprotocols,
+		CheckOrigin:       func(r *http.Request) bool { return true },
+		Error:             func(http.ResponseWriter, *http.Request, int, error) {},
+	}
+}
+
+// upgradeFailure explains why a websocket upgrade request can't succeed
+type upgradeFailure struct {
+	status int
+	cause  string
+	remedy string
+}
+
+func (f *upgradeFailure) Error() string {
+	return f.cause + "; " + f.remedy
+}
+
+// diagnoseUpgrade checks req for the problems that make websocket
+// upgrades fail, which are mostly caused by proxies in between
+func diagnoseUpgrade(req *http.Request) *upgradeFailure {
+	switch {
+	case req.Method != http.MethodGet:
+		return &upgradeFailure{
+			http.StatusMethodNotAllowed,
+			"the upgrade request used " + req.Method + " instead of GET",
+			"connect with a websocket client, not a plain HTTP request",
+		}
+	case !req.ProtoAtLeast(1, 1):
+		return &upgradeFailure{
+			http.StatusBadRequest,
+			"the upgrade request arrived over " + req.Proto + ", which can't carry websockets",
+			"a proxy is forwarding with HTTP/1.0; configure it to use HTTP/1.1 (for nginx, proxy_http_version 1.1)",
+		}
+	case !headerHasToken(req.Header, "Upgrade", "websocket"):
+		return &upgradeFailure{
+			http.StatusBadRequest,
+			"the Upgrade: websocket header is missing",
+			"a proxy is stripping it; configure it to forward Upgrade (for nginx, proxy_set_header Upgrade $http_upgrade)",
+		}
+	case !headerHasToken(req.Header, "Connection", "upgrade"):
+		return &upgradeFailure{
+			http.StatusBadRequest,
+			"the Connection: upgrade header is missing",
+			"a proxy is stripping it; configure it to forward Connection (for nginx, proxy_set_header Connection \"upgrade\")",
+		}
+	case req.Header.Get("Sec-Websocket-Version") != "13":
+		return &upgradeFailure{
+			http.StatusUpgradeRequired,
+			"the client requested websocket version " + quoteOrNone(req.Header.Get("Sec-Websocket-Version")),
+			"only version 13 (RFC 6455) is supported; update the browser or client library",
+		}
+	case req.Header.Get("Sec-Websocket-Key") == "":
+		return &upgradeFailure{
+			http.StatusBadRequest,
+			"the Sec-WebSocket-Key header is missing",
+			"a proxy or client is dropping websocket handshake headers; forward Sec-WebSocket-* headers unchanged",
+		}
+	}
+	return nil
+}
+
+// wantsUpgrade reports whether req is trying to open a websocket at all,
+// as opposed to a browser navigating to the endpoint
+func wantsUpgrade(req *http.Request) bool {
+	return req.Header.Get("Upgrade") != "" ||
+		headerHasToken(req.Header, "Connection", "upgrade") ||
+		req.Header.Get("Sec-Websocket-Key") != ""
+}
+
+// writeUpgradeFailure responds to a failed upgrade with its cause and
+// remedy, logging them both
+func writeUpgradeFailure(s *Server, rw http.ResponseWriter, req *http.Request, f *upgradeFailure) {
+	atomic.AddInt64(&s.stats.upgradeFails, 1)
+	s.increment("upgradeFailures")
+	s.logError("websocket upgrade from " + s.clientAddr(req) + " failed: " + f.Error())
+	s.emit(Event{Type: EventHandshakeFailed, Remote: s.clientAddr(req), Error: f.Error()})
+	if f.status == http.StatusUpgradeRequired {
+		rw.Header().Set("Sec-Websocket-Version", "13")
+	}
+	http.Error(rw, "websocket upgrade failed: "+f.Error(), f.status)
+}
+
+// headerHasToken reports whether a comma-separated header contains token
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func quoteOrNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return `"` + s + `"`
+}
@@ -0,0 +1,36 @@
+package lrserver_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUpgraderConfig(t *testing.T) {
+	Convey("Given a server configured to compress and accept a subprotocol", t, func() {
+		srv := startServer(t)
+		defer srv.Close()
+		srv.SetUpgraderConfig(lrserver.UpgraderConfig{
+			ReadBufferSize:    512,
+			EnableCompression: true,
+			Subprotocols:      []string{"livereload"},
+		})
+		So(srv.UpgraderConfig().Subprotocols, ShouldResemble, []string{"livereload"})
+
+		Convey("connections should negotiate both", func() {
+			dialer := websocket.Dialer{EnableCompression: true, Subprotocols: []string{"livereload"}}
+			conn, resp, err := dialer.Dial(fmt.Sprintf("ws%s:%d/livereload", localhost, srv.Port()), nil)
+			So(err, ShouldBeNil)
+			defer conn.Close()
+			So(conn.Subprotocol(), ShouldEqual, "livereload")
+			So(resp.Header.Get("Sec-Websocket-Extensions"), ShouldContainSubstring, "permessage-deflate")
+
+			hello := new(serverHello)
+			So(conn.ReadJSON(hello), ShouldBeNil)
+			So(hello.Command, ShouldEqual, "hello")
+		})
+	})
+}
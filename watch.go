@@ -0,0 +1,208 @@
+package lrserver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// WatchOption configures a directory watch started by Watch
+type WatchOption func(*watchOptions)
+
+type watchOptions struct {
+	recursive   bool
+	include     []string
+	exclude     []string
+	ignore      []string
+	gitIgnore   bool
+	ignoreFiles []string
+}
+
+// Recursive watches every directory below the watched one too,
+// including directories created later
+var Recursive WatchOption = func(o *watchOptions) { o.recursive = true }
+
+// Include only reloads changed files matching at least one of the glob
+// patterns, matched against paths relative to the watched directory
+func Include(patterns ...string) WatchOption {
+	return func(o *watchOptions) { o.include = append(o.include, patterns...) }
+}
+
+// Exclude ignores changed files matching any of the glob patterns,
+// e.g. "*.tmp" or "node_modules/**". It takes precedence over Include.
+func Exclude(patterns ...string) WatchOption {
+	return func(o *watchOptions) { o.exclude = append(o.exclude, patterns...) }
+}
+
+// watchSet tracks the directories watched by Watch
+type watchSet struct {
+	mu   sync.Mutex
+	srcs map[string]EventSource
+}
+
+// Watch reloads files changed in dir until Unwatch is called. Only the
+// files directly in dir are watched unless Recursive is given. Changed
+// paths are reloaded relative to dir, or to the web root if set. Like
+// WatchDir, it falls back to polling at the system's watch limits.
+//
+//	err := lr.Watch("./public", lrserver.Recursive, lrserver.Exclude("*.map"))
+func (s *Server) Watch(dir string, opts ...WatchOption) error {
+	var o watchOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	include, err := compileGlobs(o.include)
+	if err != nil {
+		return err
+	}
+	exclude, err := compileGlobs(o.exclude)
+	if err != nil {
+		return err
+	}
+
+	ignore, err := newIgnoreRules(dir, &o)
+	if err != nil {
+		return err
+	}
+
+	key, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	s.watches.mu.Lock()
+	defer s.watches.mu.Unlock()
+	if _, ok := s.watches.srcs[key]; ok {
+		return fmt.Errorf("lrserver: %s is already watched", dir)
+	}
+
+	src, err := s.newDirSource(dir, ignore, o.recursive)
+	if err != nil {
+		return err
+	}
+	f := newFilterSource(src, o.recursive, include, exclude, ignore)
+	s.spawn(f.filter)
+	if s.watches.srcs == nil {
+		s.watches.srcs = make(map[string]EventSource)
+	}
+	s.watches.srcs[key] = f
+	s.AddEventSource(f)
+	return nil
+}
+
+// Unwatch stops watching a directory watched by Watch
+func (s *Server) Unwatch(dir string) error {
+	key, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	s.watches.mu.Lock()
+	src, ok := s.watches.srcs[key]
+	delete(s.watches.srcs, key)
+	s.watches.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("lrserver: %s is not watched", dir)
+	}
+	return src.Close()
+}
+
+// compileGlobs compiles each of patterns
+func compileGlobs(patterns []string) ([]*globMatcher, error) {
+	globs := make([]*globMatcher, 0, len(patterns))
+	for _, pattern := range patterns {
+		glob, err := newGlobMatcher(pattern)
+		if err != nil {
+			return nil, err
+		}
+		globs = append(globs, glob)
+	}
+	return globs, nil
+}
+
+// filterSource passes on the events of another source that are
+// selected by a watch's options
+type filterSource struct {
+	src       EventSource
+	recursive bool
+	include   []*globMatcher
+	exclude   []*globMatcher
+	ignore    *ignoreRules
+	events    chan ChangeEvent
+	done      chan struct{}
+	once      sync.Once
+}
+
+func newFilterSource(src EventSource, recursive bool, include, exclude []*globMatcher, ignore *ignoreRules) *filterSource {
+	f := &filterSource{
+		src:       src,
+		recursive: recursive,
+		include:   include,
+		exclude:   exclude,
+		ignore:    ignore,
+		events:    make(chan ChangeEvent),
+		done:      make(chan struct{}),
+	}
+	return f
+}
+
+// Events gets the channel of selected changes
+func (f *filterSource) Events() <-chan ChangeEvent {
+	return f.events
+}
+
+// Close stops the underlying source, and passing on its events
+func (f *filterSource) Close() error {
+	f.once.Do(func() {
+		close(f.done)
+	})
+	return f.src.Close()
+}
+
+// filter passes on the selected events until the underlying source
+// runs dry or f is closed
+func (f *filterSource) filter() {
+	defer close(f.events)
+	for event := range f.src.Events() {
+		if !f.selects(event.urlPath()) || f.ignores(event) {
+			continue
+		}
+		select {
+		case f.events <- event:
+		case <-f.done:
+			return
+		}
+	}
+}
+
+// ignores reports whether the event's path is ignored by the watch's
+// ignore rules
+func (f *filterSource) ignores(event ChangeEvent) bool {
+	if f.ignore == nil {
+		return false
+	}
+	info, err := os.Stat(event.Path)
+	return f.ignore.ignored(event.urlPath(), err == nil && info.IsDir())
+}
+
+// selects reports whether the changed path, relative to the watched
+// directory, should be reloaded
+func (f *filterSource) selects(path string) bool {
+	if !f.recursive && strings.Contains(path, "/") {
+		return false
+	}
+	for _, glob := range f.exclude {
+		if glob.match(path) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, glob := range f.include {
+		if glob.match(path) {
+			return true
+		}
+	}
+	return false
+}
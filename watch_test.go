@@ -0,0 +1,159 @@
+package lrserver_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaschaephraim/lrserver"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWatch(t *testing.T) {
+	Convey("Given a running server, a connected websocket and a directory", t, func() {
+		srv := startServer(t)
+		conn := connect(t, srv)
+		defer conn.Close()
+
+		dir, err := ioutil.TempDir("", "lrserver")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		writeFiles(t, dir, map[string]string{"css/main.css": ""})
+
+		write := func(name string) {
+			err := ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(name), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		Convey("recursive watches should reload included files below it", func() {
+			err := srv.Watch(dir, lrserver.Recursive, lrserver.Include("*.css", "*.html"), lrserver.Exclude("vendor/**"))
+			So(err, ShouldBeNil)
+			defer srv.Unwatch(dir)
+
+			write("notes.txt")
+			os.Mkdir(filepath.Join(dir, "vendor"), 0755)
+			write("vendor/lib.css")
+			write("css/main.css")
+
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "css/main.css")
+		})
+
+		Convey("other watches should only reload files directly in it", func() {
+			So(srv.Watch(dir), ShouldBeNil)
+			defer srv.Unwatch(dir)
+
+			write("css/main.css")
+			write("index.html")
+
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "index.html")
+		})
+
+		Convey("only recursive watches should watch the directories below", func() {
+			So(srv.Watch(dir), ShouldBeNil)
+			So(lrserver.WatchesRecursively(srv, dir), ShouldBeFalse)
+			So(srv.Unwatch(dir), ShouldBeNil)
+			So(srv.Watch(dir, lrserver.Recursive), ShouldBeNil)
+			defer srv.Unwatch(dir)
+			So(lrserver.WatchesRecursively(srv, dir), ShouldBeTrue)
+		})
+
+		Convey("watches stopped with the server shouldn't leak", func() {
+			So(srv.Watch(dir), ShouldBeNil)
+			So(srv.ActiveGoroutines(), ShouldBeGreaterThanOrEqualTo, 2)
+			write("index.html")
+			srv.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			So(srv.WaitIdle(ctx), ShouldBeNil)
+		})
+
+		Convey("directories should be watched at most once", func() {
+			So(srv.Watch(dir), ShouldBeNil)
+			So(srv.Watch(dir), ShouldNotBeNil)
+			So(srv.Unwatch(dir), ShouldBeNil)
+			So(srv.Unwatch(dir), ShouldNotBeNil)
+		})
+
+		Convey("gitignored paths should be skipped", func() {
+			writeFiles(t, dir, map[string]string{
+				".gitignore":             "node_modules/\n*.log\n!keep.log\n",
+				"node_modules/lib/a.css": "",
+				"sub/.gitignore":         "/local.css\n",
+				"sub/local.css":          "",
+				"debug.log":              "",
+				"keep.log":               "",
+			})
+			So(srv.Watch(dir, lrserver.Recursive, lrserver.GitIgnore), ShouldBeNil)
+			defer srv.Unwatch(dir)
+
+			write("node_modules/lib/a.css")
+			write("sub/local.css")
+			write("debug.log")
+			write("keep.log")
+
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "keep.log")
+		})
+
+		Convey("paths matching an ignore file should be skipped", func() {
+			rules := filepath.Join(dir, ".lrignore")
+			So(ioutil.WriteFile(rules, []byte("# build output\ndist/\n"), 0644), ShouldBeNil)
+			writeFiles(t, dir, map[string]string{"dist/app.js": ""})
+			So(srv.Watch(dir, lrserver.Recursive, lrserver.IgnoreFile(rules)), ShouldBeNil)
+			defer srv.Unwatch(dir)
+
+			write("dist/app.js")
+			write("css/main.css")
+
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "css/main.css")
+		})
+
+		Convey("paths matching Ignore patterns should be skipped", func() {
+			writeFiles(t, dir, map[string]string{"node_modules/lib/a.css": ""})
+			So(srv.Watch(dir, lrserver.Recursive, lrserver.Ignore("node_modules/**", "*.map")), ShouldBeNil)
+			defer srv.Unwatch(dir)
+
+			write("node_modules/lib/a.css")
+			write("main.css.map")
+			write("css/main.css")
+
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "css/main.css")
+
+			Convey("without watching the directories they cover", func() {
+				for _, path := range []string{"node_modules", "node_modules/lib"} {
+					ignored, err := lrserver.WatchIgnores(dir, path, true, lrserver.Ignore("node_modules/**"))
+					So(err, ShouldBeNil)
+					So(ignored, ShouldBeTrue)
+				}
+				ignored, err := lrserver.WatchIgnores(dir, "css", true, lrserver.Ignore("node_modules/**"))
+				So(err, ShouldBeNil)
+				So(ignored, ShouldBeFalse)
+			})
+		})
+
+		Convey("missing ignore files should be rejected", func() {
+			So(srv.Watch(dir, lrserver.IgnoreFile(filepath.Join(dir, "missing"))), ShouldNotBeNil)
+		})
+
+		Convey("invalid patterns should be rejected", func() {
+			So(srv.Watch(dir, lrserver.Include("[")), ShouldNotBeNil)
+			So(srv.Watch(dir, lrserver.Ignore("[")), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,109 @@
+package lrserver
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"time"
+)
+
+// newFSNotifySource is swapped out by tests that need watch registration to fail
+var newFSNotifySource = newIgnoringFSNotifySource
+
+// WatchDir reloads files changed under root, using filesystem
+// notifications. It polls root every PollInterval instead if polling is
+// on, or root is on a network or FUSE filesystem that may not notify
+// changes. If watching fails for other reasons than root being missing
+// or unreadable, such as the system's notification limits being
+// exhausted (ENOSPC or EMFILE from inotify), it logs why, and how to
+// raise the limits, and falls back to polling rather than missing changes.
+func (s *Server) WatchDir(root string) error {
+	src, err := s.newDirSource(root, nil, true)
+	if err != nil {
+		return err
+	}
+	s.AddEventSource(src)
+	return nil
+}
+
+// newDirSource watches root, and the directories below it if recursive,
+// skipping ignored paths, and polling where
+// notifications can't be relied on
+func (s *Server) newDirSource(root string, ignore *ignoreRules, recursive bool) (EventSource, error) {
+	if s.Polling() {
+		return s.pollDir(root, ignore, recursive)
+	}
+	if fs := remoteFilesystem(root); fs != "" {
+		s.logStatus(root + " is on " + fs + ", which may not notify changes; polling every " + s.PollInterval().String())
+		return s.pollDir(root, ignore, recursive)
+	}
+
+	src, err := newFSNotifySource(root, ignore, recursive)
+	if err == nil {
+		return src, nil
+	}
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission) {
+		return nil, err
+	}
+	s.logError("watching "+root+":", err)
+	if guidance := watchLimitGuidance(err); guidance != "" {
+		s.logError(guidance)
+	}
+	s.logStatus("falling back to polling " + root + " every " + s.PollInterval().String())
+	return s.pollDir(root, ignore, recursive)
+}
+
+// pollDir polls root every PollInterval, failing like watching it
+// would if it isn't a readable directory
+func (s *Server) pollDir(root string, ignore *ignoreRules, recursive bool) (EventSource, error) {
+	if _, err := ioutil.ReadDir(root); err != nil {
+		return nil, err
+	}
+	return newPollingSource(root, s.PollInterval(), s.Clock(), ignore, recursive), nil
+}
+
+// watchLimitGuidance explains how to lift the watch limit behind err,
+// or returns an empty string if err isn't caused by one
+func watchLimitGuidance(err error) string {
+	switch {
+	case errors.Is(err, syscall.ENOSPC):
+		return "the inotify watch limit is exhausted; raise it with " +
+			"`sysctl fs.inotify.max_user_watches=524288` " +
+			"(persist it in /etc/sysctl.d/), or watch fewer directories"
+	case errors.Is(err, syscall.EMFILE):
+		return "the inotify instance limit is exhausted; raise it with " +
+			"`sysctl fs.inotify.max_user_instances=512` " +
+			"(persist it in /etc/sysctl.d/), or close other watchers"
+	}
+	return ""
+}
+
+// PollInterval gets the interval for polling directories that
+// can't be watched with filesystem notifications
+func (s *Server) PollInterval() time.Duration {
+	return s.settings().pollInterval
+}
+
+// SetPollInterval sets the interval for polling directories that
+// can't be watched with filesystem notifications, DefaultPollInterval by
+// default. Zero or less restores the default, and intervals shorter than
+// MinPollInterval are raised to it.
+func (s *Server) SetPollInterval(d time.Duration) {
+	d = pollInterval(d)
+	s.update(func(cfg *settings) { cfg.pollInterval = d })
+}
+
+// Polling reports whether directories are always polled
+func (s *Server) Polling() bool {
+	return s.settings().polling
+}
+
+// SetPolling sets whether directories watched from then on are polled
+// every PollInterval, comparing modification times and sizes, rather
+// than watched with filesystem notifications. Notifications often don't
+// cross Docker bind mounts or network filesystems, and not every one is
+// detected as such.
+func (s *Server) SetPolling(on bool) {
+	s.update(func(cfg *settings) { cfg.polling = on })
+}
@@ -0,0 +1,196 @@
+package lrserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+)
+
+// WatchmanSource is an EventSource backed by Watchman
+// (https://facebook.github.io/watchman/), for repositories too large
+// for inotify-based watching
+type WatchmanSource struct {
+	root   string
+	conn   net.Conn
+	events chan ChangeEvent
+	errors chan error
+	done   chan struct{}
+	once   sync.Once
+}
+
+// watchmanPDU is a response or unilateral message from Watchman
+type watchmanPDU struct {
+	Error           string         `json:"error"`
+	Log             string         `json:"log"`
+	Subscription    string         `json:"subscription"`
+	Watch           string         `json:"watch"`
+	RelativePath    string         `json:"relative_path"`
+	IsFreshInstance bool           `json:"is_fresh_instance"`
+	Files           []watchmanFile `json:"files"`
+}
+
+type watchmanFile struct {
+	Name   string `json:"name"`
+	Exists bool   `json:"exists"`
+	New    bool   `json:"new"`
+}
+
+const watchmanSubscription = "lrserver"
+
+// NewWatchmanSource subscribes to changes under root through the local
+// Watchman service, found via $WATCHMAN_SOCK or `watchman get-sockname`
+func NewWatchmanSource(root string) (*WatchmanSource, error) {
+	sock := os.Getenv("WATCHMAN_SOCK")
+	if sock == "" {
+		out, err := exec.Command("watchman", "--output-encoding=json", "get-sockname").Output()
+		if err != nil {
+			return nil, err
+		}
+		var resp struct {
+			Sockname string `json:"sockname"`
+			Error    string `json:"error"`
+		}
+		err = json.Unmarshal(out, &resp)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Error != "" {
+			return nil, errors.New("watchman: " + resp.Error)
+		}
+		sock = resp.Sockname
+	}
+	return NewWatchmanSourceSocket(sock, root)
+}
+
+// NewWatchmanSourceSocket subscribes to changes under root through the
+// Watchman service listening on the unix socket sock
+func NewWatchmanSourceSocket(sock string, root string) (*WatchmanSource, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil, err
+	}
+	w := &WatchmanSource{
+		root:   root,
+		conn:   conn,
+		events: make(chan ChangeEvent),
+		errors: make(chan error),
+		done:   make(chan struct{}),
+	}
+
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+
+	// Watch the project containing root
+	project, err := w.command(enc, dec, []interface{}{"watch-project", root})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// Subscribe to file changes
+	query := map[string]interface{}{
+		"expression": []string{"type", "f"},
+		"fields":     []string{"name", "exists", "new"},
+	}
+	if project.RelativePath != "" {
+		query["relative_root"] = project.RelativePath
+	}
+	_, err = w.command(enc, dec, []interface{}{"subscribe", project.Watch, watchmanSubscription, query})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	go w.receive(dec)
+	return w, nil
+}
+
+// command sends cmd and waits for its response, skipping unilateral PDUs
+func (w *WatchmanSource) command(enc *json.Encoder, dec *json.Decoder, cmd []interface{}) (*watchmanPDU, error) {
+	err := enc.Encode(cmd)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		pdu := new(watchmanPDU)
+		err = dec.Decode(pdu)
+		if err != nil {
+			return nil, err
+		}
+		if pdu.Error != "" {
+			return nil, errors.New("watchman: " + pdu.Error)
+		}
+		if pdu.Log == "" && pdu.Files == nil {
+			return pdu, nil
+		}
+	}
+}
+
+// Events gets the channel of changes
+func (w *WatchmanSource) Events() <-chan ChangeEvent {
+	return w.events
+}
+
+// Errors gets the channel of errors reported by Watchman
+func (w *WatchmanSource) Errors() <-chan error {
+	return w.errors
+}
+
+// Close ends the subscription
+func (w *WatchmanSource) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		err = w.conn.Close()
+	})
+	return err
+}
+
+func (w *WatchmanSource) receive(dec *json.Decoder) {
+	defer close(w.events)
+	for {
+		pdu := new(watchmanPDU)
+		err := dec.Decode(pdu)
+		if err != nil {
+			return
+		}
+
+		if pdu.Error != "" {
+			select {
+			case w.errors <- errors.New("watchman: " + pdu.Error):
+			default:
+			}
+			continue
+		}
+
+		// The first result lists every file, not changes
+		if pdu.Subscription != watchmanSubscription || pdu.IsFreshInstance {
+			continue
+		}
+
+		for _, f := range pdu.Files {
+			op := ChangeWrite
+			switch {
+			case !f.Exists:
+				op = ChangeRemove
+			case f.New:
+				op = ChangeCreate
+			}
+			event := ChangeEvent{filepath.Join(w.root, filepath.FromSlash(f.Name)), w.root, op}
+			select {
+			case w.events <- event:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
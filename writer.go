@@ -0,0 +1,46 @@
+package lrserver
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+)
+
+// lineWriter calls fn with every newline-terminated line written to it
+type lineWriter struct {
+	mu  sync.Mutex
+	buf []byte
+	fn  func(line string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimSpace(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+		if line != "" {
+			w.fn(line)
+		}
+	}
+	return len(p), nil
+}
+
+// ReloadWriter gets a writer treating each newline-terminated line
+// written to it as a path to Reload, so that an external process's
+// output can be piped straight into the server:
+//
+//	cmd.Stdout = s.ReloadWriter()
+//
+// Blank lines are ignored, and an unterminated last line is kept
+// until its newline arrives.
+func (s *Server) ReloadWriter() io.Writer {
+	return &lineWriter{fn: s.Reload}
+}
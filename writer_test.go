@@ -0,0 +1,40 @@
+package lrserver_test
+
+import (
+	"fmt"
+	"os/exec"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReloadWriter(t *testing.T) {
+	Convey("Given a running server with a connected websocket", t, func() {
+		srv := startServer(t)
+		conn := connect(t, srv)
+		defer conn.Close()
+
+		Convey("each line written to the reload writer should be reloaded", func() {
+			w := srv.ReloadWriter()
+			fmt.Fprint(w, "css/main.css\n\njs/")
+			fmt.Fprint(w, "app.js\r\nunterminated")
+
+			for _, path := range []string{"css/main.css", "js/app.js"} {
+				sr, err := readReload(conn)
+				So(err, ShouldBeNil)
+				So(sr.Path, ShouldEqual, path)
+			}
+		})
+
+		Convey("an external process should be able to write to it", func() {
+			cmd := exec.Command("sh", "-c", "echo index.html")
+			cmd.Stdout = srv.ReloadWriter()
+			err := cmd.Run()
+			So(err, ShouldBeNil)
+
+			sr, err := readReload(conn)
+			So(err, ShouldBeNil)
+			So(sr.Path, ShouldEqual, "index.html")
+		})
+	})
+}